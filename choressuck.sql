@@ -50,7 +50,7 @@ create table chore_assignments (
 );
 
 create table sessions (
-    uuid  not null primary key,
+    uuid varchar(64) not null primary key,
     values varchar,
     created timestamp not null,
     user_id integer references users(id) ON DELETE CASCADE
@@ -0,0 +1,10 @@
+create table audit_log (
+    id serial primary key,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    actor_id integer references users(id) ON DELETE SET NULL,
+    action varchar(64) not null,
+    target varchar(255),
+    before text,
+    after text,
+    created_at timestamp not null
+);
@@ -0,0 +1,7 @@
+create table chore_constraints (
+    id serial primary key,
+    chore_id integer references chores(id) ON DELETE CASCADE,
+    kind integer not null,
+    user_id integer references users(id) ON DELETE CASCADE,
+    role_id integer references roles(id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+alter table memberships add column debt integer not null default 0;
+
+create table absences (
+    id serial primary key,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    user_id integer references users(id) ON DELETE CASCADE,
+    start_at timestamp not null,
+    end_at timestamp not null,
+    compensate boolean not null default false
+);
@@ -0,0 +1,11 @@
+create table swap_requests (
+    id serial primary key,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    from_id integer references users(id) ON DELETE CASCADE,
+    to_id integer references users(id) ON DELETE CASCADE,
+    chore_id integer references chores(id) ON DELETE CASCADE,
+    counter_id integer references chores(id) ON DELETE CASCADE,
+    status integer not null,
+    created_at timestamp not null,
+    resolved_at timestamp
+);
@@ -0,0 +1,15 @@
+alter table chores add column points integer not null default 0;
+
+create table chore_history (
+    id serial primary key,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    chore_id integer references chores(id) ON DELETE SET NULL,
+    chore_name varchar(255) not null,
+    user_id integer references users(id) ON DELETE CASCADE,
+    date_assigned timestamp not null,
+    date_due timestamp,
+    date_complete timestamp,
+    points integer not null,
+    on_time boolean not null,
+    missed boolean not null
+);
@@ -0,0 +1,5 @@
+alter table groups add column missed_policy varchar(16) not null default 'count';
+alter table groups add column remind_after integer not null default 0;
+alter table groups add column escalate_after integer not null default 24;
+alter table chore_assignments add column overdue boolean not null default false;
+alter table chore_assignments add column escalation integer not null default 0;
@@ -0,0 +1,9 @@
+alter table chore_assignments add column reminded boolean not null default false;
+
+create table notification_channels (
+    user_id integer references users(id) ON DELETE CASCADE,
+    channel varchar(16) not null,
+    enabled boolean not null,
+    target varchar(2048) not null default '',
+    PRIMARY KEY (user_id, channel)
+);
@@ -0,0 +1,11 @@
+create table notifications (
+    id serial primary key,
+    user_id integer references users(id) ON DELETE CASCADE,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    type varchar(64) not null,
+    message text not null,
+    created_at timestamp not null,
+    read_at timestamp
+);
+
+create index notifications_user_idx on notifications (user_id, created_at);
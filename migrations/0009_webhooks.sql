@@ -0,0 +1,18 @@
+create table webhooks (
+    id serial primary key,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    url varchar(2048) not null,
+    secret varchar(64) not null,
+    events varchar(512) not null,
+    created_at timestamp not null
+);
+
+create table webhook_deliveries (
+    id serial primary key,
+    webhook_id integer references webhooks(id) ON DELETE CASCADE,
+    event varchar(64) not null,
+    attempt integer not null,
+    status_code integer not null,
+    error text not null,
+    created_at timestamp not null
+);
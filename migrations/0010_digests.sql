@@ -0,0 +1,8 @@
+create table digest_prefs (
+    user_id integer primary key references users(id) ON DELETE CASCADE,
+    frequency varchar(16) not null,
+    hour integer not null,
+    weekday integer not null,
+    time_zone varchar(64) not null,
+    last_sent timestamp not null
+);
@@ -0,0 +1,8 @@
+alter table chores add column recurrence varchar(16) not null default '';
+
+create table feed_tokens (
+    token varchar(64) primary key,
+    user_id integer references users(id) ON DELETE CASCADE,
+    group_id integer references groups(id) ON DELETE CASCADE,
+    created_at timestamp not null
+);
@@ -0,0 +1,8 @@
+create table group_templates (
+    id serial primary key,
+    user_id integer references users(id) ON DELETE CASCADE,
+    name varchar(255) not null,
+    description text not null,
+    body text not null,
+    created_at timestamp not null
+);
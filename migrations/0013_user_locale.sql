@@ -0,0 +1 @@
+alter table users add column locale varchar(35) not null default '';
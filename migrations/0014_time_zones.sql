@@ -0,0 +1,2 @@
+alter table users add column time_zone varchar(64) not null default '';
+alter table groups add column time_zone varchar(64) not null default '';
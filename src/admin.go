@@ -0,0 +1,284 @@
+package main
+
+import (
+	"chores-suck/config"
+	"chores-suck/core"
+	"chores-suck/core/storage/postgres"
+	"chores-suck/web"
+	"chores-suck/web/sessions"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// runMigrate creates the database tables from the schema file and then applies the files in the
+// migrations directory in the order of their names. Files that have been applied before are
+// skipped, so the command is safe to run on every deploy. The schema file is never changed once
+// released; changes to the tables go in a new migration.
+func runMigrate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	schema := fs.String("schema", cfg.SchemaPath, "SQL file with the database schema")
+	dir := fs.String("migrations", cfg.MigrationsPath, "directory of SQL files applied after the schema")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	files, e := filepath.Glob(filepath.Join(*dir, "*.sql"))
+	if e != nil {
+		return fmt.Errorf("migrate: %w", e)
+	}
+	sort.Strings(files)
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	// Databases set up by hand from the schema before migrations were tracked already have its
+	// tables, so the schema is recorded as applied instead of run
+	base := filepath.Base(*schema)
+	adopted, e := repo.Baseline(base, "users")
+	if e != nil {
+		return fmt.Errorf("migrate: %s: %w", base, e)
+	}
+	if adopted {
+		fmt.Printf("Recorded %s as applied to the existing tables\n", base)
+	}
+	for _, path := range append([]string{*schema}, files...) {
+		script, e := ioutil.ReadFile(path)
+		if e != nil {
+			return fmt.Errorf("migrate: %w", e)
+		}
+		name := filepath.Base(path)
+		ran, e := repo.Migrate(name, string(script))
+		if e != nil {
+			return fmt.Errorf("migrate: %s: %w", name, e)
+		}
+		if ran {
+			fmt.Printf("Applied %s\n", name)
+		} else {
+			fmt.Printf("%s is already applied\n", name)
+		}
+	}
+	return nil
+}
+
+func runCreateUser(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	name := fs.String("name", "", "username")
+	email := fs.String("email", "", "email address")
+	password := fs.String("password", "", "password, read from standard input when not given")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	pass, e := readPassword(*password)
+	if e != nil {
+		return e
+	}
+	if e := web.ValidateAccount(*name, *email, pass); e != nil {
+		return fmt.Errorf("create-user: %w", e)
+	}
+	user := core.User{Username: *name, Email: *email}
+	if user.Password, e = web.HashPassword(pass); e != nil {
+		return e
+	}
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	if e := core.NewUserService(repo).CreateUser(&user); e != nil {
+		return fmt.Errorf("create-user: %w", e)
+	}
+	fmt.Printf("Created user %s (id %d)\n", user.Username, user.ID)
+	return nil
+}
+
+func runResetPassword(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	name := fs.String("name", "", "username")
+	password := fs.String("password", "", "new password, read from standard input when not given")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *name == "" {
+		return errors.New("reset-password: -name is required")
+	}
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	users := core.NewUserService(repo)
+	user := core.User{Username: *name}
+	if e := users.GetUserByName(&user); e != nil {
+		return fmt.Errorf("reset-password: %s: %w", *name, e)
+	}
+	pass, e := readPassword(*password)
+	if e != nil {
+		return e
+	}
+	if strings.TrimSpace(pass) == "" {
+		return errors.New("reset-password: Password cannot be empty")
+	}
+	if user.Password, e = web.HashPassword(pass); e != nil {
+		return e
+	}
+	if e := users.UpdatePassword(&user); e != nil {
+		return fmt.Errorf("reset-password: %w", e)
+	}
+	fmt.Printf("Password of %s changed\n", user.Username)
+	return nil
+}
+
+func runGroups(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	groups, e := core.NewGroupService(repo, core.NewAuditService(repo, logger(cfg, "core.audit")), core.NewEvents(),
+		logger(cfg, "core.groups")).GetGroups()
+	if e != nil {
+		return fmt.Errorf("groups: %w", e)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tMEMBERS\tCHORES")
+	for i := range groups {
+		g := &groups[i]
+		if e := repo.GetMemberships(g); e != nil {
+			return fmt.Errorf("groups: %w", e)
+		}
+		if e := repo.GetChores(g); e != nil {
+			return fmt.Errorf("groups: %w", e)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", g.ID, g.Name, len(g.Memberships), len(g.Chores))
+	}
+	return tw.Flush()
+}
+
+func runGroup(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("group", flag.ContinueOnError)
+	groupID := fs.Uint64("group", 0, "id of the group to show")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	g, e := loadGroup(repo, *groupID)
+	if e != nil {
+		return fmt.Errorf("group: %w", e)
+	}
+	if e := repo.GetRoles(g); e != nil {
+		return fmt.Errorf("group: %w", e)
+	}
+	if e := repo.GetChores(g); e != nil {
+		return fmt.Errorf("group: %w", e)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Group %d: %s\n", g.ID, g.Name)
+	fmt.Fprintf(tw, "Missed chores: %s, remind after %dh, notify admins after %dh\n",
+		g.Settings.MissedPolicy.Text(), g.Settings.RemindAfter, g.Settings.EscalateAfter)
+	fmt.Fprintf(tw, "Time zone: %s\n\n", g.Location())
+	fmt.Fprintln(tw, "MEMBER\tJOINED\tDEBT\tROLES")
+	for i := range g.Memberships {
+		m := &g.Memberships[i]
+		if e := repo.GetRoles(m); e != nil {
+			return fmt.Errorf("group: %w", e)
+		}
+		roles := make([]string, 0, len(m.Roles))
+		for _, r := range m.Roles {
+			roles = append(roles, r.Name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", m.User.Username, m.JoinedAt.Format("2006-01-02"), m.Debt,
+			strings.Join(roles, ", "))
+	}
+	fmt.Fprintln(tw, "\nROLE\tGETS CHORES\tPERMISSIONS")
+	for i := range g.Roles {
+		r := &g.Roles[i]
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", r.Name, r.GetsChores, strings.Join(r.PermissionNames(), ", "))
+	}
+	fmt.Fprintln(tw, "\nCHORE\tASSIGNEE\tDUE\tSTATE")
+	for _, c := range g.Chores {
+		assignee, due, state := "-", "-", "-"
+		if ca := c.Assignment; ca != nil {
+			assignee = ca.User.Username
+			due = ca.DateDue.In(g.Location()).Format("2006-01-02 15:04 MST")
+			switch {
+			case ca.Complete:
+				state = "complete"
+			case ca.Overdue:
+				state = "overdue"
+			default:
+				state = "open"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, assignee, due, state)
+	}
+	return tw.Flush()
+}
+
+// runRotate rotates a group's chores as one of its members. Nobody is notified because the
+// notifiers only run inside the server.
+func runRotate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	groupID := fs.Uint64("group", 0, "id of the group to rotate")
+	as := fs.String("as", "", "member the rotation is recorded under")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	g, e := loadGroup(repo, *groupID)
+	if e != nil {
+		return fmt.Errorf("rotate: %w", e)
+	}
+	user, e := loadMember(g, *as)
+	if e != nil {
+		return fmt.Errorf("rotate: %w", e)
+	}
+	events := core.NewEvents()
+	audit := core.NewAuditService(repo, logger(cfg, "core.audit"))
+	groups := core.NewGroupService(repo, audit, events, logger(cfg, "core.groups"))
+	if e := groups.GetChores(g); e != nil {
+		return fmt.Errorf("rotate: %w", e)
+	}
+	if e := core.NewChoreService(repo, groups, audit, events, logger(cfg, "core.chores")).Rotate(g, user); e != nil {
+		return fmt.Errorf("rotate: %w", e)
+	}
+	fmt.Printf("Rotated %d chores in %s\n", len(g.Chores), g.Name)
+	return nil
+}
+
+func runPurgeSessions(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("purge-sessions", flag.ContinueOnError)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	n, e := sessions.NewStore(repo).Purge()
+	if e != nil {
+		return fmt.Errorf("purge-sessions: %w", e)
+	}
+	fmt.Printf("Deleted %d expired sessions\n", n)
+	return nil
+}
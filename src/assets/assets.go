@@ -0,0 +1,33 @@
+// Package assets holds the page templates and the files served under /public. They are embedded
+// in the binary so the server does not depend on the directory it is started from.
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed html
+var html embed.FS
+
+//go:embed public
+var public embed.FS
+
+// Templates returns the page templates, named like "index.html"
+func Templates() fs.FS {
+	return sub(html, "html")
+}
+
+// Public returns the static files, named like "css/main.css"
+func Public() fs.FS {
+	return sub(public, "public")
+}
+
+func sub(fsys fs.FS, dir string) fs.FS {
+	s, e := fs.Sub(fsys, dir)
+	if e != nil {
+		// The directories are embedded above, so this only fails if they are renamed
+		panic(e)
+	}
+	return s
+}
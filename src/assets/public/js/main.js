@@ -0,0 +1,98 @@
+function sideClick(sel,show) {
+    var rs = getComputedStyle(document.querySelector(':root'));
+    var c1 = rs.getPropertyValue('--clr-primary-600');
+    var c2 = rs.getPropertyValue('--clr-primary-200');
+    //find each header. Change to default style
+    headers = document.getElementsByClassName('s-head');
+    for (h of headers) {
+        h.style.backgroundColor = c1;
+    }
+    //find the selected header. Change to selected style.
+    document.getElementById(sel).style.backgroundColor = c2;
+    //find each content div and hide it
+    divs = document.getElementsByClassName('v-content');
+    for (d of divs) {
+        d.style.display = 'none';
+    }
+    //show the selected content
+    document.getElementById(show).style.display = 'block';
+}
+
+function hamburger(nav) {
+    document.getElementById(nav).classList.toggle('flex')
+}
+
+// Pages mark the groups they show with data-live-group and the parts of the page that change with
+// data-live and an id. When a group's stream reports a change the marked parts are reloaded.
+var liveTimer;
+
+function liveConnect() {
+    if (!window.EventSource) {
+        return;
+    }
+    var groups = new Set();
+    for (el of document.querySelectorAll('[data-live-group]')) {
+        groups.add(el.dataset.liveGroup);
+    }
+    groups.forEach(function(id) {
+        var source = new EventSource('/groups/events/' + id);
+        source.onmessage = function(msg) {
+            liveToast(JSON.parse(msg.data).message);
+            //several events often arrive together, such as a rotation, so wait for them to settle
+            clearTimeout(liveTimer);
+            liveTimer = setTimeout(liveRefresh, 300);
+        };
+    });
+}
+
+function liveRefresh() {
+    fetch(window.location.href, {credentials: 'same-origin'})
+        .then(function(res) {
+            if (!res.ok) {
+                throw new Error(res.statusText);
+            }
+            return res.text();
+        })
+        .then(function(html) {
+            var doc = new DOMParser().parseFromString(html, 'text/html');
+            for (el of document.querySelectorAll('[data-live]')) {
+                var fresh = doc.getElementById(el.id);
+                if (fresh) {
+                    el.innerHTML = fresh.innerHTML;
+                }
+            }
+        })
+        .catch(function(e) {
+            console.log('live refresh failed: ' + e);
+        });
+}
+
+function liveToast(text) {
+    if (!text) {
+        return;
+    }
+    var toast = document.createElement('div');
+    toast.className = 'toast bg-dark';
+    toast.textContent = text;
+    document.body.appendChild(toast);
+    setTimeout(function() {
+        toast.remove();
+    }, 5000);
+}
+
+document.addEventListener('DOMContentLoaded', liveConnect);
+
+// The server shows times in the zone a user chose in their settings, or else in the zone the
+// browser reports here. The cookie is only rewritten when the zone changes.
+function zoneCookie() {
+    if (!window.Intl) {
+        return;
+    }
+    var zone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+    if (!zone || document.cookie.split('; ').indexOf('tz=' + encodeURIComponent(zone)) >= 0) {
+        return;
+    }
+    document.cookie = 'tz=' + encodeURIComponent(zone) + '; path=/; max-age=31536000; samesite=lax';
+}
+
+zoneCookie();
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"chores-suck/config"
+	"chores-suck/core"
+	"chores-suck/core/storage/postgres"
+	"chores-suck/logging"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of the chores-suck binary
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(cfg *config.Config, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "start the web server (the default)", runServe},
+		{"migrate", "[-schema file] [-migrations dir]", "create and update the database tables", runMigrate},
+		{"create-user", "-name name -email email [-password pass]", "register a new user", runCreateUser},
+		{"reset-password", "-name name [-password pass]", "set a user's password", runResetPassword},
+		{"groups", "", "list every group", runGroups},
+		{"group", "-group id", "show a group's members, roles and chores", runGroup},
+		{"rotate", "-group id -as member", "rotate a group's chores now", runRotate},
+		{"purge-sessions", "", "delete expired login sessions", runPurgeSessions},
+		{"export", "-group id [-format json|chores|history] [-o file]", "write a group's data", runExport},
+		{"import", "-group id -as member [-format csv|json] [-apply] file",
+			"add chores and members to a group from a file", runImport},
+	}
+}
+
+// runCommand loads the config from the flags before the subcommand and runs the subcommand. With
+// no subcommand the server starts.
+func runCommand(args []string) error {
+	cfg, args, e := config.Load(args)
+	if e != nil {
+		return e
+	}
+	if len(args) == 0 {
+		return runServe(cfg, nil)
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(cfg, args[1:])
+		}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout)
+		return nil
+	}
+	usage(os.Stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: chores-suck [-config file] [settings] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-15s %s\n", c.name, c.summary)
+		if c.args != "" {
+			fmt.Fprintf(w, "  %-15s   %s %s\n", "", c.name, c.args)
+		}
+	}
+}
+
+// logger returns a logger for a component that writes to standard error at the configured level
+func logger(cfg *config.Config, component string) *logging.Logger {
+	return logging.New(os.Stderr, cfg.LogLevel).Component(component)
+}
+
+// describe explains why a command failed. Internal errors only say something went wrong, so
+// their cause is added for whoever is running the command.
+func describe(e error) string {
+	var ie *core.InternalError
+	if errors.As(e, &ie) {
+		return fmt.Sprintf("%s (%s)", e.Error(), ie.Detail())
+	}
+	return e.Error()
+}
+
+// loadGroup fetches the group with its memberships
+func loadGroup(repo *postgres.Storage, id uint64) (*core.Group, error) {
+	if id == 0 {
+		return nil, errors.New("-group is required")
+	}
+	g := core.Group{ID: id}
+	if e := repo.GetGroupByID(&g); e != nil {
+		return nil, fmt.Errorf("group %d: %w", id, e)
+	}
+	if e := repo.GetMemberships(&g); e != nil {
+		return nil, fmt.Errorf("group %d: %w", id, e)
+	}
+	return &g, nil
+}
+
+// loadMember finds the member of the group with the username. Commands that change a group act as
+// one of its members so the change is checked and recorded the way it is on the web.
+func loadMember(g *core.Group, name string) (*core.User, error) {
+	if name == "" {
+		return nil, errors.New("-as is required")
+	}
+	for _, m := range g.Memberships {
+		if m.User.Username == name {
+			return m.User, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a member of %s", name, g.Name)
+}
+
+// readPassword returns the password given as a flag or otherwise reads a line from standard input
+func readPassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, e := bufio.NewReader(os.Stdin).ReadString('\n')
+	if e != nil && e != io.EOF {
+		return "", e
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
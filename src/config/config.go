@@ -0,0 +1,327 @@
+package config
+
+import (
+	"chores-suck/logging"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting of the server. Settings are read, each overriding the last, from the
+// defaults, a JSON file, environment variables and command line flags.
+type Config struct {
+	// Addr is the address the web server listens on
+	Addr         string
+	PostgresConn string
+	// SessionKey signs the session cookies. The server refuses to start without it.
+	SessionKey  string
+	SessionName string
+	// Dev serves the page templates and static files from TemplatePath and StaticPath instead of
+	// the copies built into the binary, and picks up changes to them without a restart
+	Dev bool
+	// StaticPath is the directory served under /public in development
+	StaticPath string
+	// TemplatePath is the directory holding the page templates in development
+	TemplatePath string
+	// SchemaPath is the SQL file the migrate command applies first
+	SchemaPath string
+	// MigrationsPath is the directory of numbered SQL files the migrate command applies after the
+	// schema, in the order of their names
+	MigrationsPath string
+	Mail           Mail
+	// ReadTimeout, WriteTimeout and IdleTimeout are the timeouts of the web server. Zero means no
+	// timeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// DrainPeriod is how long the server keeps serving after readiness starts failing on shutdown,
+	// so load balancers stop sending it requests before it stops accepting them
+	DrainPeriod time.Duration
+	// ShutdownTimeout is how long the server waits for open requests and for queued notifications
+	// and webhook deliveries when it is asked to stop
+	ShutdownTimeout time.Duration
+	// LogLevel is the least severe level logged
+	LogLevel logging.Level
+	// MetricsToken is the bearer token scrapers must send to read /metrics. The metrics are not
+	// served when it is empty.
+	MetricsToken string
+}
+
+// Mail holds the settings of outgoing email. Mail is sent through SMTP when SMTPHost is set and
+// otherwise written to files in Dir.
+type Mail struct {
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	Dir          string
+}
+
+// setting ties a field of the config to its key in the config file, which is also its flag name,
+// and to its environment variable
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value flag.Value
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+func (v stringValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+// durationValue is a duration written the way time.ParseDuration reads it, such as "30s"
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) Set(s string) error {
+	d, e := time.ParseDuration(s)
+	if e != nil {
+		return e
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+// levelValue is a log level written the way logging.ParseLevel reads it, such as "debug"
+type levelValue struct{ p *logging.Level }
+
+func (v levelValue) Set(s string) error {
+	l, e := logging.ParseLevel(s)
+	if e != nil {
+		return e
+	}
+	*v.p = l
+	return nil
+}
+
+func (v levelValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+// boolValue is a switch written the way strconv.ParseBool reads it, such as "true"
+type boolValue struct{ p *bool }
+
+func (v boolValue) Set(s string) error {
+	b, e := strconv.ParseBool(s)
+	if e != nil {
+		return e
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strconv.FormatBool(*v.p)
+}
+
+// IsBoolFlag lets the switch be given as a bare flag, such as -dev
+func (v boolValue) IsBoolFlag() bool {
+	return true
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "CS_ADDR", "address to listen on", stringValue{&c.Addr}},
+		{"postgres-conn", "POSTGRES_CONN", "PostgreSQL connection string", stringValue{&c.PostgresConn}},
+		{"session-key", "SESSION_KEY", "key used to sign session cookies", stringValue{&c.SessionKey}},
+		{"session-name", "SESSION_NAME", "name of the session cookie", stringValue{&c.SessionName}},
+		{"dev", "CS_DEV", "serve templates and static files from disk and reload them when they change",
+			boolValue{&c.Dev}},
+		{"static-path", "CS_STATIC_PATH", "directory of the files served under /public in development",
+			stringValue{&c.StaticPath}},
+		{"template-path", "CS_TEMPLATE_PATH", "directory of the page templates in development",
+			stringValue{&c.TemplatePath}},
+		{"schema-path", "CS_SCHEMA_PATH", "SQL file applied by the migrate command", stringValue{&c.SchemaPath}},
+		{"migrations-path", "CS_MIGRATIONS_PATH", "directory of SQL files applied by the migrate command after the schema",
+			stringValue{&c.MigrationsPath}},
+		{"mail-from", "MAIL_FROM", "sender address of email", stringValue{&c.Mail.From}},
+		{"smtp-host", "SMTP_HOST", "SMTP server, mail is written to files when empty", stringValue{&c.Mail.SMTPHost}},
+		{"smtp-port", "SMTP_PORT", "SMTP server port", stringValue{&c.Mail.SMTPPort}},
+		{"smtp-user", "SMTP_USER", "SMTP user name", stringValue{&c.Mail.SMTPUser}},
+		{"smtp-password", "SMTP_PASSWORD", "SMTP password", stringValue{&c.Mail.SMTPPassword}},
+		{"mail-dir", "MAIL_DIR", "directory mail is written to when there is no SMTP server", stringValue{&c.Mail.Dir}},
+		{"read-timeout", "CS_READ_TIMEOUT", "longest time to read a request", durationValue{&c.ReadTimeout}},
+		{"write-timeout", "CS_WRITE_TIMEOUT", "longest time to write a response", durationValue{&c.WriteTimeout}},
+		{"idle-timeout", "CS_IDLE_TIMEOUT", "how long idle connections are kept open", durationValue{&c.IdleTimeout}},
+		{"drain-period", "CS_DRAIN_PERIOD", "how long to keep serving after readiness fails on shutdown",
+			durationValue{&c.DrainPeriod}},
+		{"shutdown-timeout", "CS_SHUTDOWN_TIMEOUT",
+			"how long open requests and queued deliveries may take to finish on shutdown",
+			durationValue{&c.ShutdownTimeout}},
+		{"log-level", "CS_LOG_LEVEL", "least severe level logged: debug, info, warn or error", levelValue{&c.LogLevel}},
+		{"metrics-token", "CS_METRICS_TOKEN", "bearer token required to read /metrics, which is not served when empty",
+			stringValue{&c.MetricsToken}},
+	}
+}
+
+// Default returns the config used when nothing is set. The development paths are relative to the
+// src directory the server has always been run from.
+func Default() *Config {
+	return &Config{
+		Addr:           ":8080",
+		SessionName:    "chores-suck",
+		StaticPath:     "assets/public",
+		TemplatePath:   "assets/html",
+		SchemaPath:     "../choressuck.sql",
+		MigrationsPath: "../migrations",
+		Mail: Mail{
+			From:     "chores-suck@localhost",
+			SMTPPort: "587",
+		},
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    60 * time.Second,
+		IdleTimeout:     2 * time.Minute,
+		DrainPeriod:     5 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+		LogLevel:        logging.LevelInfo,
+	}
+}
+
+// Load reads the config from the file named by -config or CS_CONFIG, the environment and the
+// flags at the start of args. It returns the arguments left after the flags.
+func Load(args []string) (*Config, []string, error) {
+	c := Default()
+	settings := c.settings()
+	fs := flag.NewFlagSet("chores-suck", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CS_CONFIG"), "JSON file of settings")
+	// The flags are only collected here and applied after the file and environment. Switches are
+	// registered as bools so they can be given without a value.
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (%s)", s.usage, s.env)
+		if b, ok := s.value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			fs.Bool(s.key, false, usage)
+		} else {
+			fs.String(s.key, "", usage)
+		}
+	}
+	if e := fs.Parse(args); e != nil {
+		return nil, nil, e
+	}
+
+	if *file != "" {
+		if e := c.readFile(*file); e != nil {
+			return nil, nil, e
+		}
+	}
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if e := s.value.Set(v); e != nil {
+				return nil, nil, fmt.Errorf("config: %s: %w", s.env, e)
+			}
+		}
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.key == f.Name && err == nil {
+				if e := s.value.Set(f.Value.String()); e != nil {
+					err = fmt.Errorf("config: -%s: %w", s.key, e)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// readFile sets the settings found in a JSON object of keys and string values. Durations are
+// strings such as "30s".
+func (c *Config) readFile(path string) error {
+	data, e := ioutil.ReadFile(path)
+	if e != nil {
+		return fmt.Errorf("config: %w", e)
+	}
+	values := make(map[string]string)
+	if e := json.Unmarshal(data, &values); e != nil {
+		return fmt.Errorf("config: %s: %w", path, e)
+	}
+	settings := c.settings()
+	for key, v := range values {
+		found := false
+		for _, s := range settings {
+			if s.key == key {
+				if e := s.value.Set(v); e != nil {
+					return fmt.Errorf("config: %s: %s: %w", path, key, e)
+				}
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("config: %s: unknown setting %q", path, key)
+		}
+	}
+	return nil
+}
+
+// Validate checks the settings the web server needs and reports every problem at once
+func (c *Config) Validate() error {
+	var problems []string
+	if strings.TrimSpace(c.Addr) == "" {
+		problems = append(problems, "addr must not be empty")
+	}
+	if strings.TrimSpace(c.PostgresConn) == "" {
+		problems = append(problems, "postgres-conn (POSTGRES_CONN) must not be empty")
+	}
+	if c.SessionKey == "" {
+		problems = append(problems, "session-key (SESSION_KEY) must not be empty")
+	}
+	if strings.TrimSpace(c.SessionName) == "" {
+		problems = append(problems, "session-name must not be empty")
+	}
+	if c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 {
+		problems = append(problems, "timeouts must not be negative")
+	}
+	if c.DrainPeriod < 0 {
+		problems = append(problems, "drain-period must not be negative")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown-timeout must be positive")
+	}
+	// The paths are only read in development, otherwise the copies built into the binary are used
+	if c.Dev {
+		for _, dir := range []struct{ key, path string }{
+			{"static-path", c.StaticPath},
+			{"template-path", c.TemplatePath},
+		} {
+			if info, e := os.Stat(dir.path); e != nil || !info.IsDir() {
+				problems = append(problems, fmt.Sprintf("%s %q is not a directory", dir.key, dir.path))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
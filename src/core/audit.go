@@ -0,0 +1,121 @@
+package core
+
+import (
+	"chores-suck/logging"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audit actions recorded by the group, role and chore services
+const (
+	ActionGroupCreate      = "group.create"
+	ActionGroupRename      = "group.rename"
+	ActionGroupSettings    = "group.settings"
+	ActionMemberAdd        = "member.add"
+	ActionMemberRemove     = "member.remove"
+	ActionRoleCreate       = "role.create"
+	ActionRoleUpdate       = "role.update"
+	ActionRoleDelete       = "role.delete"
+	ActionRoleAssign       = "role.assign"
+	ActionRoleUnassign     = "role.unassign"
+	ActionChoreCreate      = "chore.create"
+	ActionChoreUpdate      = "chore.update"
+	ActionChoreDelete      = "chore.delete"
+	ActionChoreRandomize   = "chore.randomize"
+	ActionChoreRotate      = "chore.rotate"
+	ActionChoreConstrain   = "chore.constrain"
+	ActionChoreUnconstrain = "chore.unconstrain"
+	ActionChoreSwap        = "chore.swap"
+	ActionChoreHandoff     = "chore.handoff"
+	ActionWebhookCreate    = "webhook.create"
+	ActionWebhookDelete    = "webhook.delete"
+)
+
+type AuditRepository interface {
+	CreateAuditEntry(entry *AuditEntry) error
+	GetAuditLog(group *Group) error
+}
+
+type AuditService interface {
+	// Record saves an entry to the group's audit log. Failures are logged and otherwise ignored so
+	// that a broken audit log never blocks the action being audited.
+	Record(group *Group, actor *User, action string, target string, before string, after string)
+	GetAuditLog(group *Group) error
+}
+
+type auditService struct {
+	repo AuditRepository
+	log  *logging.Logger
+}
+
+func NewAuditService(r AuditRepository, l *logging.Logger) AuditService {
+	return &auditService{
+		repo: r,
+		log:  l,
+	}
+}
+
+func (s *auditService) Record(group *Group, actor *User, action string, target string, before string, after string) {
+	entry := AuditEntry{
+		Group:     group,
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Before:    before,
+		After:     after,
+		CreatedAt: time.Now().UTC(),
+	}
+	if e := s.repo.CreateAuditEntry(&entry); e != nil {
+		s.log.Error("Failed to record audit entry", "action", action, "group", group.ID, "err", e)
+	}
+}
+
+func (s *auditService) GetAuditLog(group *Group) error {
+	return s.repo.GetAuditLog(group)
+}
+
+func describeSettings(s *GroupSettings) string {
+	return fmt.Sprintf("missed_policy=%s remind_after=%d escalate_after=%d time_zone=%s", s.MissedPolicy,
+		s.RemindAfter, s.EscalateAfter, s.TimeZone)
+}
+
+func describeWebhook(w *Webhook) string {
+	events := make([]string, 0, len(w.Events))
+	for _, e := range w.Events {
+		events = append(events, string(e))
+	}
+	return fmt.Sprintf("url=%s events=%s", w.URL, strings.Join(events, ","))
+}
+
+func describeRole(r *Role) string {
+	return fmt.Sprintf("name=%s permissions=%d gets_chores=%v", r.Name, r.Permissions, r.GetsChores)
+}
+
+func describeChore(c *Chore) string {
+	return fmt.Sprintf("name=%s duration=%d points=%d recurrence=%s description=%s", c.Name, c.Duration, c.Points,
+		c.Recurrence, c.Description)
+}
+
+func describeAssignments(ca []ChoreAssignment) string {
+	desc := make([]string, 0, len(ca))
+	for i := range ca {
+		if ca[i].Chore == nil || ca[i].User == nil {
+			continue
+		}
+		desc = append(desc, fmt.Sprintf("%s=%s", ca[i].Chore.Name, ca[i].User.Username))
+	}
+	return strings.Join(desc, ", ")
+}
+
+func describeConstraint(c *ChoreConstraint) string {
+	switch c.Kind {
+	case ConstraintPin:
+		return fmt.Sprintf("pinned=%s", c.User.Username)
+	case ConstraintExclude:
+		return fmt.Sprintf("excluded=%s", c.User.Username)
+	case ConstraintRole:
+		return fmt.Sprintf("role=%s", c.Role.Name)
+	}
+	return ""
+}
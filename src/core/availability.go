@@ -0,0 +1,90 @@
+package core
+
+type AvailabilityRepository interface {
+	CreateAbsence(a *Absence) error
+	DeleteAbsence(a *Absence) error
+	GetAbsences(t interface{}) error
+}
+
+type AvailabilityService interface {
+	// AddAbsence records a period the member is away. Members may record their own absences,
+	// members who can edit members may record absences for anyone in the group.
+	AddAbsence(a *Absence, user *User) error
+	RemoveAbsence(a *Absence, user *User) error
+	GetAbsences(t interface{}) error
+}
+
+type availabilityService struct {
+	repo AvailabilityRepository
+	gs   GroupService
+}
+
+func NewAvailabilityService(r AvailabilityRepository, g GroupService) AvailabilityService {
+	return &availabilityService{
+		repo: r,
+		gs:   g,
+	}
+}
+
+func (s *availabilityService) AddAbsence(a *Absence, user *User) error {
+	if e := s.authorize(a, user); e != nil {
+		return e
+	}
+	if !a.End.After(a.Start) {
+		return invalid("end", "The end of an absence must be after its start")
+	}
+	if e := s.repo.CreateAbsence(a); e != nil {
+		return internal("AvailabilityService.AddAbsence", e)
+	}
+	return nil
+}
+
+func (s *availabilityService) RemoveAbsence(a *Absence, user *User) error {
+	mem := a.Group.FindMember(a.User.ID)
+	if mem == nil {
+		return notFound("Member not found")
+	}
+	if e := s.repo.GetAbsences(mem); e != nil {
+		return internal("AvailabilityService.RemoveAbsence", e)
+	}
+	var found *Absence
+	for i := range mem.Absences {
+		if mem.Absences[i].ID == a.ID {
+			found = &mem.Absences[i]
+		}
+	}
+	if found == nil {
+		return notFound("Absence not found")
+	}
+	if e := s.authorize(found, user); e != nil {
+		return e
+	}
+	if e := s.repo.DeleteAbsence(found); e != nil {
+		return internal("AvailabilityService.RemoveAbsence", e)
+	}
+	return nil
+}
+
+func (s *availabilityService) GetAbsences(t interface{}) error {
+	return s.repo.GetAbsences(t)
+}
+
+func (s *availabilityService) authorize(a *Absence, user *User) error {
+	if a.Group.FindMember(a.User.ID) == nil {
+		return notFound("Member not found")
+	}
+	if a.User.ID == user.ID {
+		return nil
+	}
+	authMem := a.Group.FindMember(user.ID)
+	if authMem == nil {
+		return forbidden("You are not a member of this group")
+	}
+	if e := s.gs.GetRoles(authMem); e != nil {
+		return internal("AvailabilityService.authorize", e)
+	}
+	if !authMem.SuperRole.Can(EditMembers) {
+		return forbidden("You do not have permission to edit other members' availability")
+	}
+	return nil
+}
@@ -1,12 +1,34 @@
 package core
 
 import (
+	"chores-suck/logging"
 	"errors"
-	"log"
+	"fmt"
 	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 )
 
+var (
+	// ErrUnsatisfiable occurs when a chore's constraints leave no member it can be assigned to
+	ErrUnsatisfiable = conflict("Chore constraints cannot be satisfied")
+	// ErrAlreadyComplete occurs when an assignment is completed a second time
+	ErrAlreadyComplete = conflict("This chore is already complete")
+)
+
+// rotationPeriod is how long members have to complete the chores they are assigned
+const rotationPeriod = 7 * 24 * time.Hour
+
+// dueDate returns when chores assigned at now fall due: the end of the day rotationPeriod later in
+// the group's time zone, so the due date is the same calendar day for everyone in the group
+func dueDate(g *Group, now time.Time) time.Time {
+	local := now.In(g.Location()).Add(rotationPeriod)
+	next := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location())
+	return next.Add(-time.Minute).UTC()
+}
+
 type ChoreRepository interface {
 	CreateChore(*Chore) error
 	GetChores(interface{}) error
@@ -15,181 +37,588 @@ type ChoreRepository interface {
 	DeleteChore(*Chore) error
 	InsertAssignments([]ChoreAssignment) error
 	DeleteAssignments([]ChoreAssignment) error
+	GetConstraints(interface{}) error
+	CreateConstraint(*ChoreConstraint) error
+	DeleteConstraint(*ChoreConstraint) error
+	GetAbsences(interface{}) error
+	UpdateDebt(*Membership) error
+	// CompleteAssignment marks the assignment complete and saves its history entry together. It
+	// returns ErrAlreadyComplete if the assignment was completed already.
+	CompleteAssignment(*ChoreAssignment, *HistoryEntry) error
+	InsertHistory([]HistoryEntry) error
 }
 
 type ChoreService interface {
-	Create(*Chore) error
-	Update(ch *Chore, new *Chore) error
-	Delete(ch *Chore) error
+	Create(ch *Chore, user *User) error
+	Update(ch *Chore, new *Chore, user *User) error
+	Delete(ch *Chore, user *User) error
 	GetChore(*Chore) error
-	Randomize(g *Group) error
-	Rotate(g *Group) error
+	Randomize(g *Group, user *User) error
+	Rotate(g *Group, user *User) error
+	AddConstraint(ch *Chore, con *ChoreConstraint, user *User) error
+	RemoveConstraint(ch *Chore, id uint64, user *User) error
+	// Complete marks the user's assignment of the chore as done and scores it
+	Complete(ch *Chore, user *User) error
 }
 
 type choreService struct {
-	repo ChoreRepository
-	gs   GroupService
+	repo   ChoreRepository
+	gs     GroupService
+	audit  AuditService
+	events EventSink
+	log    *logging.Logger
 }
 
-func NewChoreService(r ChoreRepository, g GroupService) ChoreService {
+func NewChoreService(r ChoreRepository, g GroupService, a AuditService, ev EventSink, l *logging.Logger) ChoreService {
 	return &choreService{
-		repo: r,
-		gs:   g,
+		repo:   r,
+		gs:     g,
+		audit:  a,
+		events: ev,
+		log:    l,
 	}
 }
 
-func (s *choreService) Create(ch *Chore) error {
+// choreName is the format chore names must have
+var choreName = regexp.MustCompile(`^(?:[0-9a-zA-Z]+-)*[0-9a-zA-Z]+$`)
+
+// validateChore checks the chore's own fields, the rules every new or updated chore must follow
+func validateChore(ch *Chore) error {
+	if strings.TrimSpace(ch.Name) == "" {
+		return invalid("chore_name", "Chore name cannot be empty")
+	}
+	if !choreName.MatchString(ch.Name) {
+		return invalid("chore_name", "Name must only consist of alphanumeric characters and hyphens and cannot start or end with a hyphen")
+	}
+	if ch.Duration <= 0 {
+		return invalid("chore_dur", "Duration must be a positive number of minutes")
+	}
+	if ch.Points < 0 {
+		return invalid("chore_points", "Points cannot be negative")
+	}
+	if !validRecurrence(ch.Recurrence) {
+		return invalid("chore_recurrence", "Unknown recurrence")
+	}
+	return nil
+}
+
+func (s *choreService) Create(ch *Chore, user *User) error {
+	if e := validateChore(ch); e != nil {
+		return e
+	}
 	if e := s.repo.GetChores(ch.Group); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Create", e)
 	}
 	for _, v := range ch.Group.Chores {
 		if v.Name == ch.Name {
-			return errors.New("Chore already exists")
+			return conflict("Chore already exists")
 		}
 	}
 	if e := s.repo.CreateChore(ch); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Create", e)
 	}
+	s.audit.Record(ch.Group, user, ActionChoreCreate, ch.Name, "", describeChore(ch))
+	s.changed(ch.Group, ch, user, "added")
 	return nil
 }
 
 func (s *choreService) GetChore(ch *Chore) error {
 	if e := s.repo.GetChore(ch); e != nil {
-		return e
+		return lookup("ChoreService.GetChore", "Chore not found", e)
+	}
+	if e := s.repo.GetConstraints(ch); e != nil {
+		return internal("ChoreService.GetChore", e)
 	}
 	return nil
 }
 
-func (s *choreService) Update(ch *Chore, new *Chore) error {
+func (s *choreService) Update(ch *Chore, new *Chore, user *User) error {
+	if e := validateChore(new); e != nil {
+		return e
+	}
 	if ch.Name != new.Name {
 		if e := s.gs.GetChores(ch.Group); e != nil {
-			log.Printf("ChoreService: Update: Failed to get group chores: %s", e.Error())
-			return errors.New("An unexpected error occurred")
+			return internal("ChoreService.Update", fmt.Errorf("get group chores: %w", e))
 		}
 		if c := ch.Group.FindChore(new.Name); c != nil {
-			return errors.New("Chore name already in use")
+			return conflict("Chore name already in use")
 		}
 	}
 	if e := s.repo.UpdateChore(new); e != nil {
-		log.Printf("ChoreService: Update: Failed to update: %s", e.Error())
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Update", fmt.Errorf("update: %w", e))
 	}
+	s.audit.Record(ch.Group, user, ActionChoreUpdate, ch.Name, describeChore(ch), describeChore(new))
+	s.changed(ch.Group, new, user, "updated")
 	return nil
 }
 
-func (s *choreService) Delete(ch *Chore) error {
+func (s *choreService) Delete(ch *Chore, user *User) error {
 	if e := s.repo.DeleteChore(ch); e != nil {
-		log.Printf("ChoreService: Delete: Operation Failed: %s", e.Error())
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Delete", e)
 	}
+	s.audit.Record(ch.Group, user, ActionChoreDelete, ch.Name, describeChore(ch), "")
+	s.changed(ch.Group, ch, user, "deleted")
 	return nil
 }
 
-func (s *choreService) Randomize(g *Group) error {
+func (s *choreService) Randomize(g *Group, user *User) error {
+	if e := s.loadConstraints(g); e != nil {
+		return internal("ChoreService.Randomize", fmt.Errorf("load constraints: %w", e))
+	}
+	now := time.Now().UTC()
+	due := dueDate(g, now)
 	oldCa := make([]ChoreAssignment, 0, len(g.Chores))
 	newCa := make([]ChoreAssignment, 0, len(g.Chores))
 	for i := range g.Chores {
 		if g.Chores[i].Assignment != nil {
 			oldCa = append(oldCa, *g.Chores[i].Assignment)
 		}
-		// TODO: determine the due date
 		g.Chores[i].Assignment = &ChoreAssignment{
 			Chore:        &g.Chores[i],
-			DateAssigned: time.Now().UTC(),
+			DateAssigned: now,
+			DateDue:      due,
 		}
 	}
+	debts := memberDebts(g.Memberships)
+	accrueDebts(g.Chores, g.Memberships, due)
+	fixed, misses := applyMissedPolicy(g, oldCa, now, due)
 	// TODO: only pass members that get chores
-	randomize(g.Chores, g.Memberships)
+	if e := randomize(g.Chores, g.Memberships, fixed); e != nil {
+		return e
+	}
 	for i := range g.Chores {
 		newCa = append(newCa, *g.Chores[i].Assignment)
 	}
 	if e := s.repo.DeleteAssignments(oldCa); e != nil {
-		log.Printf("Core: ChoreService: Randomize: failed to delete assignments: %s", e.Error())
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Randomize", fmt.Errorf("delete assignments: %w", e))
 	}
 	if e := s.repo.InsertAssignments(newCa); e != nil {
-		log.Printf("Core: ChoreService: Randomize: failed to insert assignments: %s", e.Error())
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Randomize", fmt.Errorf("insert assignments: %w", e))
 	}
+	s.saveDebts(g.Memberships, debts)
+	s.saveHistory(misses)
+	s.audit.Record(g, user, ActionChoreRandomize, g.Name, describeAssignments(oldCa), describeAssignments(newCa))
+	s.announce(g, user, newCa, "randomized")
 	return nil
 }
 
-func (s *choreService) Rotate(g *Group) error {
-	newCa := rotate(g.Chores, g.Memberships)
+func (s *choreService) Rotate(g *Group, user *User) error {
+	if e := s.loadConstraints(g); e != nil {
+		return internal("ChoreService.Rotate", fmt.Errorf("load constraints: %w", e))
+	}
+	now := time.Now().UTC()
+	due := dueDate(g, now)
 	oldCa := make([]ChoreAssignment, 0, len(g.Chores))
 	for i := range g.Chores {
-		oldCa = append(oldCa, *g.Chores[i].Assignment)
+		if g.Chores[i].Assignment != nil {
+			oldCa = append(oldCa, *g.Chores[i].Assignment)
+		}
+	}
+	debts := memberDebts(g.Memberships)
+	accrueDebts(g.Chores, g.Memberships, due)
+	fixed, misses := applyMissedPolicy(g, oldCa, now, due)
+	newCa, e := rotate(g.Chores, g.Memberships, due, fixed)
+	if e != nil {
+		return e
 	}
 	if e := s.repo.DeleteAssignments(oldCa); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Rotate", e)
 	}
 	if e := s.repo.InsertAssignments(newCa); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("ChoreService.Rotate", e)
+	}
+	s.saveDebts(g.Memberships, debts)
+	s.saveHistory(misses)
+	s.audit.Record(g, user, ActionChoreRotate, g.Name, describeAssignments(oldCa), describeAssignments(newCa))
+	s.announce(g, user, newCa, "rotated")
+	return nil
+}
+
+func (s *choreService) AddConstraint(ch *Chore, con *ChoreConstraint, user *User) error {
+	if e := s.repo.GetConstraints(ch); e != nil {
+		return internal("ChoreService.AddConstraint", fmt.Errorf("get constraints: %w", e))
 	}
+	switch con.Kind {
+	case ConstraintPin, ConstraintExclude:
+		mem := ch.Group.FindMember(con.User.ID)
+		if mem == nil {
+			return notFound("Member not found")
+		}
+		con.User = mem.User
+		for _, v := range ch.Constraints {
+			if v.IsPin() && con.Kind == ConstraintPin {
+				return conflict("Chore is already pinned to a member")
+			}
+			if v.User != nil && v.User.ID == con.User.ID {
+				return conflict("Chore already has a constraint for this member")
+			}
+		}
+	case ConstraintRole:
+		if e := s.gs.GetRoles(ch.Group); e != nil {
+			return internal("ChoreService.AddConstraint", e)
+		}
+		role := ch.Group.FindRole(con.Role.ID)
+		if role == nil {
+			return notFound("Role not found")
+		}
+		con.Role = role
+		for _, v := range ch.Constraints {
+			if v.IsRole() && v.Role.ID == con.Role.ID {
+				return conflict("Chore is already restricted to this role")
+			}
+		}
+	default:
+		return invalid("constraint_kind", "Invalid constraint")
+	}
+	con.Chore = ch
+	if e := s.repo.CreateConstraint(con); e != nil {
+		return internal("ChoreService.AddConstraint", e)
+	}
+	s.audit.Record(ch.Group, user, ActionChoreConstrain, ch.Name, "", describeConstraint(con))
 	return nil
 }
 
+func (s *choreService) RemoveConstraint(ch *Chore, id uint64, user *User) error {
+	if e := s.repo.GetConstraints(ch); e != nil {
+		return internal("ChoreService.RemoveConstraint", fmt.Errorf("get constraints: %w", e))
+	}
+	var con *ChoreConstraint
+	for i := range ch.Constraints {
+		if ch.Constraints[i].ID == id {
+			con = &ch.Constraints[i]
+		}
+	}
+	if con == nil {
+		return notFound("Constraint not found")
+	}
+	if e := s.repo.DeleteConstraint(con); e != nil {
+		return internal("ChoreService.RemoveConstraint", e)
+	}
+	s.audit.Record(ch.Group, user, ActionChoreUnconstrain, ch.Name, describeConstraint(con), "")
+	return nil
+}
+
+func (s *choreService) Complete(ch *Chore, user *User) error {
+	ca := ch.Assignment
+	if ca == nil || ca.User == nil || ca.User.ID != user.ID {
+		return forbidden("This chore is not assigned to you")
+	}
+	if ca.Complete {
+		return ErrAlreadyComplete
+	}
+	now := time.Now().UTC()
+	entry := scoreCompletion(ca, now)
+	ca.Complete = true
+	ca.DateComplete = now
+	if e := s.repo.CompleteAssignment(ca, &entry); e != nil {
+		if errors.Is(e, ErrAlreadyComplete) {
+			return ErrAlreadyComplete
+		}
+		return internal("ChoreService.Complete", e)
+	}
+	s.events.Publish(Event{
+		Type:    EventCompleted,
+		Group:   ch.Group,
+		Actor:   user,
+		Chore:   ch,
+		Message: fmt.Sprintf("%s completed %s", user.Username, ch.Name),
+	})
+	return nil
+}
+
+// saveHistory records the history entries of replaced assignments
+func (s *choreService) saveHistory(h []HistoryEntry) {
+	if len(h) == 0 {
+		return
+	}
+	if e := s.repo.InsertHistory(h); e != nil {
+		s.log.Error("Failed to record history", "err", e)
+	}
+}
+
+// loadConstraints fetches the constraints of every chore in the group along with the roles and
+// absences of every member so that Chore.Allows and Membership.AbsentOn can be evaluated.
+func (s *choreService) loadConstraints(g *Group) error {
+	if e := s.repo.GetConstraints(g); e != nil {
+		return e
+	}
+	if e := s.repo.GetAbsences(g); e != nil {
+		return e
+	}
+	for i := range g.Memberships {
+		if e := s.gs.GetRoles(&g.Memberships[i]); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// announce publishes the rotation and tells each member which chores they were given
+func (s *choreService) announce(g *Group, user *User, ca []ChoreAssignment, how string) {
+	s.events.Publish(Event{
+		Type:    EventRotation,
+		Group:   g,
+		Actor:   user,
+		Message: fmt.Sprintf("%s %s the chores in %s: %s", user.Username, how, g.Name, describeAssignments(ca)),
+	})
+	chores := make(map[uint64][]string)
+	users := make([]*User, 0)
+	for i := range ca {
+		id := ca[i].User.ID
+		if _, ok := chores[id]; !ok {
+			users = append(users, ca[i].User)
+		}
+		chores[id] = append(chores[id], ca[i].Chore.Name)
+	}
+	for _, u := range users {
+		s.events.Publish(Event{
+			Type:       EventAssigned,
+			Group:      g,
+			Actor:      user,
+			Recipients: []*User{u},
+			Message: fmt.Sprintf("You were assigned %s in %s, due %s", strings.Join(chores[u.ID], ", "), g.Name,
+				ca[0].DateDue.In(g.Location()).Format("Jan 2")),
+		})
+	}
+}
+
+// changed tells anyone watching the group that one of its chores was edited
+func (s *choreService) changed(g *Group, ch *Chore, user *User, how string) {
+	s.events.Publish(Event{
+		Type:    EventChoreChanged,
+		Group:   g,
+		Actor:   user,
+		Chore:   ch,
+		Message: fmt.Sprintf("%s %s %s", user.Username, how, ch.Name),
+	})
+}
+
+// saveDebts persists the debt of every member whose debt changed from the values in old
+func (s *choreService) saveDebts(m []Membership, old map[uint64]int) {
+	for i := range m {
+		if m[i].Debt == old[m[i].User.ID] {
+			continue
+		}
+		if e := s.repo.UpdateDebt(&m[i]); e != nil {
+			s.log.Error("Failed to update debt", "user", m[i].User.ID, "err", e)
+		}
+	}
+}
+
+func memberDebts(m []Membership) map[uint64]int {
+	debts := make(map[uint64]int)
+	for i := range m {
+		debts[m[i].User.ID] = m[i].Debt
+	}
+	return debts
+}
+
+func unsatisfiable(c *Chore) error {
+	return fmt.Errorf("%w: no member can be assigned %s", ErrUnsatisfiable, c.Name)
+}
+
+// applyMissedPolicy decides what happens to the assignments that were missed, incomplete past
+// their due date, when chores are handed out again. It returns the members missed chores must go
+// to in the next period and the history entries for the misses that count against the assignee.
+func applyMissedPolicy(g *Group, ca []ChoreAssignment, now time.Time, due time.Time) (map[uint64]*User, []HistoryEntry) {
+	fixed := make(map[uint64]*User)
+	misses := make([]HistoryEntry, 0)
+	for _, h := range missed(ca, now) {
+		c := g.FindChore(h.Chore.ID)
+		if c == nil {
+			continue
+		}
+		switch g.Settings.MissedPolicy {
+		case MissedCarry:
+			// Keep the chore with its assignee unless they can no longer take it
+			if m := g.FindMember(h.User.ID); m != nil && c.Allows(m) && m.AbsentOn(due) == nil {
+				fixed[c.ID] = m.User
+			}
+		case MissedReassign:
+			if u := nextMember(c, g.Memberships, memberIndex(g.Memberships, h.User), due); u != nil {
+				fixed[c.ID] = u
+			}
+		default:
+			misses = append(misses, h)
+		}
+	}
+	return fixed, misses
+}
+
 // Randomize randomly distributes a set of chores to a set of people.
 // Each person will have a minimum amount of chores to work on based
-// on the time of each chore.
-func randomize(c []Chore, p []Membership) {
-	/* Fisher-Yates Shuffle Algorithm
-	for i = n-1; i>0; i--
-		j = random number from 0 <= j <= i
-		swap c[j] with c[i]
-	*/
-	// Randomize the list of chores
+// on the time of each chore. Chores are only given to members allowed
+// by the chore's constraints and who are not away on the due date.
+// Chores in fixed go to the given member, and members who owe the group
+// time take on chores from the others to pay it back.
+func randomize(c []Chore, p []Membership, fixed map[uint64]*User) error {
 	rand.Seed(time.Now().UnixNano())
-	for i := len(c) - 1; i > 0; i-- {
-		j := rand.Intn(i)
-		c[i], c[j] = c[j], c[i]
-	}
+	order := make([]int, 0, len(c))
+	people := rand.Perm(len(p))
 
-	// Find the average score of all the chores
-	var minScore uint32
-	for i := range c {
-		minScore += uint32(c[i].Duration / 5)
+	// Find the members each chore may go to
+	eligible := make([][]int, len(c))
+	for _, i := range rand.Perm(len(c)) {
+		if _, ok := fixed[c[i].ID]; ok {
+			continue
+		}
+		order = append(order, i)
+		for _, j := range people {
+			if p[j].AbsentOn(c[i].Assignment.DateDue) != nil {
+				continue
+			}
+			if c[i].Allows(&p[j]) {
+				eligible[i] = append(eligible[i], j)
+			}
+		}
+		if len(eligible[i]) == 0 {
+			return unsatisfiable(&c[i])
+		}
 	}
-	minScore = minScore / uint32(len(p))
-
-	// Generate a map of users to scores
-	scores := make(map[uint64]int)
 
-	var rIndex int
-	allChecked := false
+	// Hand out the most constrained chores first so the few members they can
+	// go to are not already loaded up with chores anyone could have done
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(eligible[order[a]]) < len(eligible[order[b]])
+	})
 
+	// Each chore goes to the eligible member with the lowest score so far.
+	// Fixed chores count towards their member's score up front.
+	scores := make(map[uint64]int)
 	for i := range c {
-		if rIndex >= len(p) {
-			allChecked = true
-			rIndex = 0
+		if u, ok := fixed[c[i].ID]; ok {
+			c[i].Assignment.User = u
+			scores[u.ID] += c[i].Duration / 5
 		}
-		if uint32(scores[p[rIndex].User.ID]) < minScore || allChecked {
-			c[i].Assignment.User = p[rIndex].User
-			scores[p[rIndex].User.ID] += c[i].Duration / 5
+	}
+	for _, i := range order {
+		best := eligible[i][0]
+		for _, j := range eligible[i][1:] {
+			if scores[p[j].User.ID] < scores[p[best].User.ID] {
+				best = j
+			}
 		}
-		rIndex++
+		c[i].Assignment.User = p[best].User
+		scores[p[best].User.ID] += c[i].Duration / 5
+	}
+	ca := make([]*ChoreAssignment, len(c))
+	for i := range c {
+		ca[i] = c[i].Assignment
 	}
+	repayDebts(ca, p, fixed)
+	return nil
 }
 
 // Rotate rotates the assigned chores amongst the people.
-func rotate(c []Chore, m []Membership) []ChoreAssignment {
+func rotate(c []Chore, m []Membership, due time.Time, fixed map[uint64]*User) ([]ChoreAssignment, error) {
 	// Rotate the chores amongst the roommates.
-	// The roommates must already have chores assigned.
 	// The first roommate in the list gets the last roommates chores
-	// the second roommate gets the first roommates chores
+	// the second roommate gets the first roommates chores.
+	// Unassigned chores go to the first allowed roommate, chores in
+	// fixed stay where the missed chore policy put them, and members
+	// who owe the group time then take on chores to pay it back.
 	assignments := make([]ChoreAssignment, 0, len(c))
-	rmap := make(map[uint64]*User)
-	for i := range m {
-		j := i + 1
-		if j >= len(m) {
-			j = 0
+	for i := range c {
+		user, ok := fixed[c[i].ID]
+		if !ok {
+			start := -1
+			if c[i].Assignment != nil {
+				start = memberIndex(m, c[i].Assignment.User)
+			}
+			user = nextMember(&c[i], m, start, due)
+		}
+		if user == nil {
+			return nil, unsatisfiable(&c[i])
 		}
-		rmap[m[i].User.ID] = m[j].User
+		ca := ChoreAssignment{Chore: &c[i], DateAssigned: time.Now().UTC(), DateDue: due, User: user}
+		assignments = append(assignments, ca)
+	}
+	ca := make([]*ChoreAssignment, len(assignments))
+	for i := range assignments {
+		ca[i] = &assignments[i]
+	}
+	repayDebts(ca, m, fixed)
+	return assignments, nil
+}
+
+// nextMember returns the first member after start the chore can go to. Members
+// the chore's constraints don't allow or who are away on the due date are
+// skipped.
+func nextMember(c *Chore, m []Membership, start int, due time.Time) *User {
+	for k := 1; k <= len(m); k++ {
+		j := (start + k) % len(m)
+		if !c.Allows(&m[j]) {
+			continue
+		}
+		if m[j].AbsentOn(due) != nil {
+			continue
+		}
+		return m[j].User
+	}
+	return nil
+}
+
+// accrueDebts charges members away on the due date who agreed to make up for it
+// their share of the chores handed out, the minutes of all chores split evenly
+// between the members of the group
+func accrueDebts(c []Chore, m []Membership, due time.Time) {
+	if len(m) == 0 {
+		return
 	}
+	total := 0
 	for i := range c {
-		ca := ChoreAssignment{Chore: &c[i], DateAssigned: time.Now().UTC()}
-		ca.User = rmap[c[i].Assignment.User.ID]
-		assignments = append(assignments, ca)
+		total += c[i].Duration
+	}
+	for j := range m {
+		if a := m[j].AbsentOn(due); a != nil && a.Compensate {
+			m[j].Debt += total / len(m)
+		}
+	}
+}
+
+// repayDebts moves chores to members who owe the group time until they have
+// paid it back. Each debtor takes the shortest chore they are allowed that a
+// member without debt was given, and their debt goes down by its minutes.
+// Chores in fixed and members away on the chore's due date are left alone.
+func repayDebts(ca []*ChoreAssignment, m []Membership, fixed map[uint64]*User) {
+	for j := range m {
+		for m[j].Debt > 0 {
+			var best *ChoreAssignment
+			for _, a := range ca {
+				if _, ok := fixed[a.Chore.ID]; ok || a.User == nil || a.User.ID == m[j].User.ID {
+					continue
+				}
+				if a.Chore.Duration <= 0 || !a.Chore.Allows(&m[j]) || m[j].AbsentOn(a.DateDue) != nil {
+					continue
+				}
+				if from := memberIndex(m, a.User); from >= 0 && m[from].Debt > 0 {
+					continue
+				}
+				if best == nil || a.Chore.Duration < best.Chore.Duration {
+					best = a
+				}
+			}
+			if best == nil {
+				break
+			}
+			best.User = m[j].User
+			m[j].Debt -= best.Chore.Duration
+			if m[j].Debt < 0 {
+				m[j].Debt = 0
+			}
+		}
+	}
+}
+
+// memberIndex returns the position of the user in the memberships or -1
+func memberIndex(m []Membership, u *User) int {
+	if u == nil {
+		return -1
+	}
+	for i := range m {
+		if m[i].User.ID == u.ID {
+			return i
+		}
 	}
-	return assignments
+	return -1
 }
@@ -0,0 +1,422 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var due = time.Date(2024, 3, 10, 22, 59, 0, 0, time.UTC)
+
+// members returns n memberships for users with the IDs 1 to n
+func members(n int) []Membership {
+	m := make([]Membership, n)
+	for i := range m {
+		m[i].User = &User{ID: uint64(i + 1)}
+	}
+	return m
+}
+
+// away returns an absence from start to end relative to due
+func away(start, end time.Duration, compensate bool) Absence {
+	return Absence{Start: due.Add(start), End: due.Add(end), Compensate: compensate}
+}
+
+func TestAccrueDebts(t *testing.T) {
+	// 60 minutes of chores split between three members is 20 each
+	chores := []Chore{{Name: "dishes", Duration: 45}, {Name: "trash", Duration: 15}}
+	tests := []struct {
+		name     string
+		absences []Absence
+		debt     int
+		want     int
+	}{
+		{"present", nil, 0, 0},
+		{"away over the due date", []Absence{away(-48*time.Hour, 48*time.Hour, true)}, 0, 20},
+		{"away from the due date on", []Absence{away(0, 48*time.Hour, true)}, 0, 20},
+		{"back on the due date", []Absence{away(-48*time.Hour, 0, true)}, 0, 0},
+		{"away before the rotation ends", []Absence{away(-72*time.Hour, -time.Hour, true)}, 0, 0},
+		{"away after the rotation ends", []Absence{away(time.Minute, 72*time.Hour, true)}, 0, 0},
+		{"away without making up for it", []Absence{away(-time.Hour, time.Hour, false)}, 0, 0},
+		{"adds to earlier debt", []Absence{away(-time.Hour, time.Hour, true)}, 25, 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := members(3)
+			m[0].Absences = tt.absences
+			m[0].Debt = tt.debt
+			accrueDebts(chores, m, due)
+			if m[0].Debt != tt.want {
+				t.Errorf("debt = %d, want %d", m[0].Debt, tt.want)
+			}
+			for _, o := range m[1:] {
+				if o.Debt != 0 {
+					t.Errorf("present member %d was charged %d", o.User.ID, o.Debt)
+				}
+			}
+		})
+	}
+
+	t.Run("no members", func(t *testing.T) {
+		accrueDebts(chores, nil, due)
+	})
+}
+
+func TestRepayDebts(t *testing.T) {
+	tests := []struct {
+		name string
+		// debt owed by user 1
+		debt int
+		// constraints on every chore
+		constraints []ChoreConstraint
+		absences    []Absence
+		fixed       []uint64
+		// owner of each chore afterwards and the debt left
+		want     map[string]uint64
+		wantDebt int
+	}{
+		{
+			name:     "no debt",
+			want:     map[string]uint64{"dishes": 2, "trash": 2, "laundry": 3},
+			wantDebt: 0,
+		},
+		{
+			name:     "takes the shortest chore first",
+			debt:     10,
+			want:     map[string]uint64{"dishes": 2, "trash": 1, "laundry": 3},
+			wantDebt: 0,
+		},
+		{
+			name:     "keeps taking chores until paid back",
+			debt:     15,
+			want:     map[string]uint64{"dishes": 1, "trash": 1, "laundry": 3},
+			wantDebt: 0,
+		},
+		{
+			name:     "debt larger than the chores available",
+			debt:     200,
+			want:     map[string]uint64{"dishes": 1, "trash": 1, "laundry": 1},
+			wantDebt: 140,
+		},
+		{
+			name:     "fixed chores stay put",
+			debt:     15,
+			fixed:    []uint64{2},
+			want:     map[string]uint64{"dishes": 1, "trash": 2, "laundry": 3},
+			wantDebt: 0,
+		},
+		{
+			name:        "debtor who doesn't get chores",
+			debt:        15,
+			constraints: []ChoreConstraint{{Kind: ConstraintExclude, User: &User{ID: 1}}},
+			want:        map[string]uint64{"dishes": 2, "trash": 2, "laundry": 3},
+			wantDebt:    15,
+		},
+		{
+			name:        "debtor pinned away from chores",
+			debt:        15,
+			constraints: []ChoreConstraint{{Kind: ConstraintPin, User: &User{ID: 2}}},
+			want:        map[string]uint64{"dishes": 2, "trash": 2, "laundry": 3},
+			wantDebt:    15,
+		},
+		{
+			name:     "debtor still away",
+			debt:     15,
+			absences: []Absence{away(-time.Hour, time.Hour, true)},
+			want:     map[string]uint64{"dishes": 2, "trash": 2, "laundry": 3},
+			wantDebt: 15,
+		},
+		{
+			name:     "debtor back by the due date",
+			debt:     10,
+			absences: []Absence{away(-72*time.Hour, 0, true)},
+			want:     map[string]uint64{"dishes": 2, "trash": 1, "laundry": 3},
+			wantDebt: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := members(3)
+			m[0].Debt = tt.debt
+			m[0].Absences = tt.absences
+			c := []Chore{
+				{ID: 1, Name: "dishes", Duration: 20},
+				{ID: 2, Name: "trash", Duration: 10},
+				{ID: 3, Name: "laundry", Duration: 30},
+			}
+			owners := []*User{m[1].User, m[1].User, m[2].User}
+			ca := make([]*ChoreAssignment, len(c))
+			for i := range c {
+				c[i].Constraints = tt.constraints
+				ca[i] = &ChoreAssignment{Chore: &c[i], User: owners[i], DateDue: due}
+			}
+			fixed := make(map[uint64]*User)
+			for _, id := range tt.fixed {
+				fixed[id] = owners[id-1]
+			}
+
+			repayDebts(ca, m, fixed)
+			for _, a := range ca {
+				if a.User.ID != tt.want[a.Chore.Name] {
+					t.Errorf("%s went to user %d, want %d", a.Chore.Name, a.User.ID, tt.want[a.Chore.Name])
+				}
+			}
+			if m[0].Debt != tt.wantDebt {
+				t.Errorf("debt left = %d, want %d", m[0].Debt, tt.wantDebt)
+			}
+		})
+	}
+}
+
+func TestApplyMissedPolicy(t *testing.T) {
+	last := due.Add(-rotationPeriod)
+	tests := []struct {
+		name   string
+		policy MissedPolicy
+		// when the policy is applied, relative to the end of the last rotation
+		now         time.Duration
+		absences    map[int][]Absence
+		constraints []ChoreConstraint
+		// the member the missed chore is fixed to, zero when it isn't, and whether it counts as missed
+		want   uint64
+		misses int
+	}{
+		{name: "count", policy: MissedCount, now: time.Hour, misses: 1},
+		{name: "no policy counts", now: time.Hour, misses: 1},
+		{name: "not yet due", policy: MissedCount, now: -time.Hour},
+		{name: "due right now", policy: MissedCarry, now: 0},
+		{name: "carry", policy: MissedCarry, now: time.Hour, want: 1},
+		{
+			name:     "carry while the assignee is away",
+			policy:   MissedCarry,
+			now:      time.Hour,
+			absences: map[int][]Absence{0: {away(-time.Hour, time.Hour, false)}},
+		},
+		{
+			name:     "carry when the assignee is back by the due date",
+			policy:   MissedCarry,
+			now:      time.Hour,
+			absences: map[int][]Absence{0: {away(-rotationPeriod, 0, false)}},
+			want:     1,
+		},
+		{
+			name:        "carry when the assignee is now excluded",
+			policy:      MissedCarry,
+			now:         time.Hour,
+			constraints: []ChoreConstraint{{Kind: ConstraintExclude, User: &User{ID: 1}}},
+		},
+		{name: "reassign", policy: MissedReassign, now: time.Hour, want: 2},
+		{
+			name:     "reassign skips members who are away",
+			policy:   MissedReassign,
+			now:      time.Hour,
+			absences: map[int][]Absence{1: {away(-time.Hour, time.Hour, true)}},
+			want:     3,
+		},
+		{
+			name:        "reassign honors pins",
+			policy:      MissedReassign,
+			now:         time.Hour,
+			constraints: []ChoreConstraint{{Kind: ConstraintPin, User: &User{ID: 1}}},
+			want:        1,
+		},
+		{
+			name:        "reassign with nobody left",
+			policy:      MissedReassign,
+			now:         time.Hour,
+			absences:    map[int][]Absence{0: {away(-time.Hour, time.Hour, false)}},
+			constraints: []ChoreConstraint{{Kind: ConstraintPin, User: &User{ID: 1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Group{Settings: GroupSettings{MissedPolicy: tt.policy}, Memberships: members(3)}
+			for i, a := range tt.absences {
+				g.Memberships[i].Absences = a
+			}
+			g.Chores = []Chore{
+				{ID: 1, Name: "dishes", Duration: 20, Group: g, Constraints: tt.constraints},
+				{ID: 2, Name: "trash", Duration: 10, Group: g},
+			}
+			ca := []ChoreAssignment{
+				{Chore: &g.Chores[0], User: g.Memberships[0].User, DateDue: last},
+				{Chore: &g.Chores[1], User: g.Memberships[1].User, DateDue: last, Complete: true},
+			}
+
+			fixed, misses := applyMissedPolicy(g, ca, last.Add(tt.now), due)
+			if _, ok := fixed[2]; ok {
+				t.Error("the completed chore was fixed")
+			}
+			u, ok := fixed[1]
+			switch {
+			case tt.want == 0 && ok:
+				t.Errorf("missed chore fixed to user %d, want it handed out as usual", u.ID)
+			case tt.want != 0 && !ok:
+				t.Errorf("missed chore not fixed, want user %d", tt.want)
+			case ok && u.ID != tt.want:
+				t.Errorf("missed chore fixed to user %d, want %d", u.ID, tt.want)
+			}
+			if len(misses) != tt.misses {
+				t.Fatalf("%d misses recorded, want %d", len(misses), tt.misses)
+			}
+			for _, h := range misses {
+				if !h.Missed || h.User.ID != 1 || h.Chore.ID != 1 || h.Points != -g.Chores[0].Worth() {
+					t.Errorf("unexpected miss %+v", h)
+				}
+			}
+		})
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	pin := func(id uint64) ChoreConstraint { return ChoreConstraint{Kind: ConstraintPin, User: &User{ID: id}} }
+	exclude := func(id uint64) ChoreConstraint { return ChoreConstraint{Kind: ConstraintExclude, User: &User{ID: id}} }
+	role := func(id uint64) ChoreConstraint { return ChoreConstraint{Kind: ConstraintRole, Role: &Role{ID: id}} }
+	tests := []struct {
+		name        string
+		constraints []ChoreConstraint
+		// absent lists the members away on the due date
+		absent []int
+		// allowed are the members randomize may pick and next the member rotate hands the chore
+		// to after user 1, empty when the constraints can't be met
+		allowed []uint64
+		next    uint64
+	}{
+		{name: "no constraints", allowed: []uint64{1, 2, 3}, next: 2},
+		{name: "pin", constraints: []ChoreConstraint{pin(1)}, allowed: []uint64{1}, next: 1},
+		{name: "exclude", constraints: []ChoreConstraint{exclude(2)}, allowed: []uint64{1, 3}, next: 3},
+		{name: "role", constraints: []ChoreConstraint{role(7)}, allowed: []uint64{3}, next: 3},
+		{name: "exclude and role", constraints: []ChoreConstraint{exclude(1), role(5)}, allowed: []uint64{2, 3}, next: 2},
+		{name: "role and away", constraints: []ChoreConstraint{role(5)}, absent: []int{2}, allowed: []uint64{2}, next: 2},
+		{name: "skips members who are away", absent: []int{1}, allowed: []uint64{1, 3}, next: 3},
+		{name: "pinned member away", constraints: []ChoreConstraint{pin(2)}, absent: []int{1}},
+		{name: "everyone excluded", constraints: []ChoreConstraint{exclude(1), exclude(2), exclude(3)}},
+		{name: "conflicting pins", constraints: []ChoreConstraint{pin(1), pin(2)}},
+		{name: "pin and exclude", constraints: []ChoreConstraint{pin(2), exclude(2)}},
+		{name: "role nobody holds", constraints: []ChoreConstraint{role(9)}},
+		{name: "role holder excluded", constraints: []ChoreConstraint{role(7), exclude(3)}},
+	}
+	setup := func(constraints []ChoreConstraint, absent []int) ([]Chore, []Membership) {
+		m := members(3)
+		m[1].Roles = []Role{{ID: 5}}
+		m[2].Roles = []Role{{ID: 5}, {ID: 7}}
+		for _, i := range absent {
+			m[i].Absences = []Absence{away(-time.Hour, time.Hour, false)}
+		}
+		c := []Chore{
+			{ID: 1, Name: "dishes", Duration: 20, Constraints: constraints},
+			{ID: 2, Name: "trash", Duration: 10},
+		}
+		for i := range c {
+			c[i].Assignment = &ChoreAssignment{Chore: &c[i], User: m[0].User, DateDue: due}
+		}
+		return c, m
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Randomize picks differently each time, so try it repeatedly
+			for run := 0; run < 50; run++ {
+				c, m := setup(tt.constraints, tt.absent)
+				e := randomize(c, m, nil)
+				if len(tt.allowed) == 0 {
+					if !errors.Is(e, ErrUnsatisfiable) {
+						t.Fatalf("randomize error = %v, want ErrUnsatisfiable", e)
+					}
+					break
+				}
+				if e != nil {
+					t.Fatalf("randomize: %v", e)
+				}
+				if !contains(tt.allowed, c[0].Assignment.User.ID) {
+					t.Fatalf("randomize gave the chore to user %d, want one of %v", c[0].Assignment.User.ID, tt.allowed)
+				}
+			}
+
+			c, m := setup(tt.constraints, tt.absent)
+			ca, e := rotate(c, m, due, nil)
+			if tt.next == 0 {
+				if !errors.Is(e, ErrUnsatisfiable) {
+					t.Fatalf("rotate error = %v, want ErrUnsatisfiable", e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("rotate: %v", e)
+			}
+			if ca[0].User.ID != tt.next {
+				t.Errorf("rotate gave the chore to user %d, want %d", ca[0].User.ID, tt.next)
+			}
+		})
+	}
+
+	t.Run("fixed chores skip the constraint check", func(t *testing.T) {
+		c, m := setup([]ChoreConstraint{exclude(1), exclude(2), exclude(3)}, nil)
+		fixed := map[uint64]*User{1: m[1].User}
+		if e := randomize(c, m, fixed); e != nil || c[0].Assignment.User.ID != 2 {
+			t.Errorf("randomize = %v with the chore at user %d, want it fixed to user 2", e, c[0].Assignment.User.ID)
+		}
+		c, m = setup([]ChoreConstraint{exclude(1), exclude(2), exclude(3)}, nil)
+		if ca, e := rotate(c, m, due, fixed); e != nil || ca[0].User.ID != 2 {
+			t.Errorf("rotate = %v, want the chore fixed to user 2", e)
+		}
+	})
+
+	t.Run("unassigned chores go to the first allowed member", func(t *testing.T) {
+		c, m := setup([]ChoreConstraint{exclude(1)}, nil)
+		c[0].Assignment = nil
+		if ca, e := rotate(c, m, due, nil); e != nil || ca[0].User.ID != 2 {
+			t.Errorf("rotate = %v, want the chore at user 2", e)
+		}
+	})
+}
+
+func contains(ids []uint64, id uint64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDueDate(t *testing.T) {
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		zone string
+		now  time.Time
+		want time.Time
+	}{
+		{"utc", "UTC", utc(3, 3, 10, 0), utc(3, 10, 23, 59)},
+		{"no zone is utc", "", utc(3, 3, 10, 0), utc(3, 10, 23, 59)},
+		{"unknown zone is utc", "Mars/Olympus_Mons", utc(3, 3, 10, 0), utc(3, 10, 23, 59)},
+		{"winter time", "Europe/Berlin", utc(3, 3, 10, 0), utc(3, 10, 22, 59)},
+		// Clocks in Berlin go forward on March 31st, so the day ends an hour earlier in UTC
+		{"into summer time", "Europe/Berlin", utc(3, 25, 10, 0), utc(4, 1, 21, 59)},
+		{"summer time", "Europe/Berlin", utc(7, 1, 10, 0), utc(7, 8, 21, 59)},
+		// and back on October 27th
+		{"into winter time", "Europe/Berlin", utc(10, 21, 10, 0), utc(10, 28, 22, 59)},
+		// New York changes on March 10th, three weeks before Europe
+		{"west of utc into summer time", "America/New_York", utc(3, 5, 15, 0), utc(3, 13, 3, 59)},
+		{"already tomorrow in the zone", "Asia/Tokyo", utc(3, 3, 20, 0), utc(3, 11, 14, 59)},
+		{"still yesterday in the zone", "America/Los_Angeles", utc(3, 3, 5, 0), utc(3, 10, 7, 59)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Group{Settings: GroupSettings{TimeZone: tt.zone}}
+			got := dueDate(g, tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("dueDate = %s, want %s", got, tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("dueDate is in %s, want UTC", got.Location())
+			}
+			local := got.In(g.Location())
+			if local.Hour() != 23 || local.Minute() != 59 {
+				t.Errorf("due at %s in the group's zone, want the end of the day", local.Format("15:04"))
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"chores-suck/logging"
+	"time"
+)
+
+type DigestRepository interface {
+	GetDigestPrefs(user *User) error
+	SaveDigestPrefs(user *User) error
+	// GetDigestUsers returns every user that receives digests, with their email and digest preferences
+	GetDigestUsers() ([]User, error)
+	MarkDigestSent(user *User) error
+	GetChores(t interface{}) error
+	// GetUserHistory loads the user's history entries completed at or after since
+	GetUserHistory(user *User, since time.Time) error
+}
+
+type DigestService interface {
+	GetDigestPrefs(user *User) error
+	UpdateDigestPrefs(user *User, prefs DigestPrefs) error
+	// DueDigests returns the users whose digest is due at now
+	DueDigests(now time.Time) ([]User, error)
+	// Build collects the user's upcoming, overdue and recently completed chores for a digest sent at now
+	Build(user *User, now time.Time) (*Digest, error)
+	MarkSent(user *User, now time.Time)
+}
+
+type digestService struct {
+	repo DigestRepository
+	log  *logging.Logger
+}
+
+func NewDigestService(r DigestRepository, l *logging.Logger) DigestService {
+	return &digestService{
+		repo: r,
+		log:  l,
+	}
+}
+
+func (s *digestService) GetDigestPrefs(user *User) error {
+	return s.repo.GetDigestPrefs(user)
+}
+
+func (s *digestService) UpdateDigestPrefs(user *User, prefs DigestPrefs) error {
+	known := false
+	for _, f := range DigestFrequencies {
+		known = known || f == prefs.Frequency
+	}
+	if !known {
+		return invalid("frequency", "Unknown digest frequency")
+	}
+	if prefs.Hour < 0 || prefs.Hour > 23 {
+		return invalid("hour", "Digest hour must be between 0 and 23")
+	}
+	if prefs.Weekday < time.Sunday || prefs.Weekday > time.Saturday {
+		return invalid("weekday", "Unknown day of the week")
+	}
+	if !validTimeZone(prefs.TimeZone) {
+		return invalid("time_zone", "Unknown time zone")
+	}
+	// Changing the schedule should not immediately send a digest for a time that already passed
+	prefs.LastSent = time.Now().UTC()
+	user.Digest = prefs
+	if e := s.repo.SaveDigestPrefs(user); e != nil {
+		return internal("DigestService.UpdateDigestPrefs", e)
+	}
+	return nil
+}
+
+func (s *digestService) DueDigests(now time.Time) ([]User, error) {
+	users, e := s.repo.GetDigestUsers()
+	if e != nil {
+		return nil, e
+	}
+	due := make([]User, 0)
+	for _, u := range users {
+		if u.Digest.Due(now) {
+			due = append(due, u)
+		}
+	}
+	return due, nil
+}
+
+func (s *digestService) Build(user *User, now time.Time) (*Digest, error) {
+	d := Digest{User: user, Since: now.Add(-user.Digest.Period()), Until: now.Add(user.Digest.Period())}
+	user.Chores = nil
+	if e := s.repo.GetChores(user); e != nil {
+		return nil, e
+	}
+	if e := s.repo.GetUserHistory(user, d.Since); e != nil {
+		return nil, e
+	}
+	for _, c := range user.Chores {
+		ca := c.Assignment
+		if ca == nil || ca.Complete || ca.DateDue.IsZero() {
+			continue
+		}
+		if ca.DateDue.Before(now) {
+			d.Overdue = append(d.Overdue, c)
+		} else if ca.DateDue.Before(d.Until) {
+			d.Upcoming = append(d.Upcoming, c)
+		}
+	}
+	for _, h := range user.History {
+		if !h.Missed {
+			d.Completed = append(d.Completed, h)
+		}
+	}
+	return &d, nil
+}
+
+func (s *digestService) MarkSent(user *User, now time.Time) {
+	user.Digest.LastSent = now
+	if e := s.repo.MarkDigestSent(user); e != nil {
+		s.log.Error("Failed to mark digest sent", "user", user.ID, "err", e)
+	}
+}
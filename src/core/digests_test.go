@@ -0,0 +1,118 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestScheduled(t *testing.T) {
+	// March 6th 2024 is a Wednesday
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+	daily := func(hour int, zone string) DigestPrefs {
+		return DigestPrefs{Frequency: DigestDaily, Hour: hour, TimeZone: zone}
+	}
+	weekly := func(day time.Weekday, hour int, zone string) DigestPrefs {
+		return DigestPrefs{Frequency: DigestWeekly, Weekday: day, Hour: hour, TimeZone: zone}
+	}
+	tests := []struct {
+		name  string
+		prefs DigestPrefs
+		now   time.Time
+		want  time.Time
+	}{
+		{"off", DigestPrefs{Frequency: DigestOff, Hour: 8}, utc(3, 6, 10, 0), time.Time{}},
+		{"not set", DigestPrefs{Hour: 8}, utc(3, 6, 10, 0), time.Time{}},
+		{"daily after the hour", daily(8, ""), utc(3, 6, 10, 0), utc(3, 6, 8, 0)},
+		{"daily on the hour", daily(8, ""), utc(3, 6, 8, 0), utc(3, 6, 8, 0)},
+		{"daily before the hour", daily(8, ""), utc(3, 6, 7, 59), utc(3, 5, 8, 0)},
+		{"daily at midnight", daily(0, ""), utc(3, 6, 0, 0), utc(3, 6, 0, 0)},
+		{"daily across a month", daily(8, ""), utc(3, 1, 7, 0), utc(2, 29, 8, 0)},
+		{"daily in the user's zone", daily(8, "Europe/Berlin"), utc(3, 6, 7, 30), utc(3, 6, 7, 0)},
+		{"daily before the hour in the user's zone", daily(8, "Europe/Berlin"), utc(3, 6, 6, 30), utc(3, 5, 7, 0)},
+		{"daily in an unknown zone", daily(8, "Mars/Olympus_Mons"), utc(3, 6, 10, 0), utc(3, 6, 8, 0)},
+		// Clocks in Berlin go forward at 2am on March 31st
+		{"daily the morning clocks go forward", daily(8, "Europe/Berlin"), utc(3, 31, 6, 30), utc(3, 31, 6, 0)},
+		{"daily the day before clocks go forward", daily(8, "Europe/Berlin"), utc(3, 31, 5, 30), utc(3, 30, 7, 0)},
+		{"daily in the skipped hour", daily(2, "Europe/Berlin"), utc(3, 31, 12, 0), utc(3, 31, 1, 0)},
+		{"daily the morning clocks go back", daily(8, "Europe/Berlin"), utc(10, 27, 7, 30), utc(10, 27, 7, 0)},
+		{"weekly later in the week", weekly(time.Monday, 8, ""), utc(3, 6, 10, 0), utc(3, 4, 8, 0)},
+		{"weekly on the day", weekly(time.Wednesday, 8, ""), utc(3, 6, 9, 0), utc(3, 6, 8, 0)},
+		{"weekly on the day before the hour", weekly(time.Wednesday, 8, ""), utc(3, 6, 7, 0), utc(2, 28, 8, 0)},
+		{"weekly the day before", weekly(time.Thursday, 8, ""), utc(3, 6, 10, 0), utc(2, 29, 8, 0)},
+		// Sunday night in UTC is already Monday morning in Tokyo
+		{"weekly on the day in the user's zone", weekly(time.Monday, 8, "Asia/Tokyo"), utc(3, 3, 23, 30), utc(3, 3, 23, 0)},
+		{"weekly across clocks going forward", weekly(time.Sunday, 8, "Europe/Berlin"), utc(4, 2, 12, 0), utc(3, 31, 6, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.prefs.Scheduled(tt.now)
+			if !got.Equal(tt.want) {
+				t.Errorf("Scheduled = %s, want %s", got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	now := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+	scheduled := time.Date(2024, 3, 6, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		frequency DigestFrequency
+		lastSent  time.Time
+		want      bool
+	}{
+		{"never sent", DigestDaily, time.Time{}, true},
+		{"sent before the last schedule", DigestDaily, scheduled.Add(-23 * time.Hour), true},
+		{"sent on schedule", DigestDaily, scheduled, false},
+		{"sent since the last schedule", DigestDaily, scheduled.Add(time.Minute), false},
+		{"weekly sent the day before", DigestWeekly, scheduled.Add(-24 * time.Hour), true},
+		{"off", DigestOff, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DigestPrefs{Frequency: tt.frequency, Hour: 8, Weekday: time.Wednesday, LastSent: tt.lastSent}
+			if got := d.Due(now); got != tt.want {
+				t.Errorf("Due = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// digestUsers is a DigestRepository that knows a fixed set of users
+type digestUsers struct {
+	DigestRepository
+	users []User
+}
+
+func (r *digestUsers) GetDigestUsers() ([]User, error) {
+	return r.users, nil
+}
+
+func TestDueDigests(t *testing.T) {
+	now := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+	s := NewDigestService(&digestUsers{users: []User{
+		{ID: 1, Digest: DigestPrefs{Frequency: DigestDaily, Hour: 8}},
+		{ID: 2, Digest: DigestPrefs{Frequency: DigestDaily, Hour: 8, LastSent: now.Add(-time.Hour)}},
+		{ID: 3, Digest: DigestPrefs{Frequency: DigestDaily, Hour: 11}},
+		{ID: 4, Digest: DigestPrefs{Frequency: DigestDaily, Hour: 11, LastSent: now.Add(-2 * time.Hour)}},
+		{ID: 5, Digest: DigestPrefs{Frequency: DigestWeekly, Weekday: time.Thursday, Hour: 8,
+			LastSent: now.Add(-6 * 24 * time.Hour)}},
+		{ID: 6, Digest: DigestPrefs{Frequency: DigestOff}},
+	}}, nil)
+	due, e := s.DueDigests(now)
+	if e != nil {
+		t.Fatal(e)
+	}
+	want := []uint64{1, 3}
+	if len(due) != len(want) {
+		t.Fatalf("%d digests due, want %d", len(due), len(want))
+	}
+	for i := range due {
+		if due[i].ID != want[i] {
+			t.Errorf("digest %d is for user %d, want %d", i+1, due[i].ID, want[i])
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+
+	storageErr "chores-suck/core/storage/errors"
+)
+
+// The errors core services return say what kind of failure happened so the web layer can answer
+// each kind the same way wherever it comes from. Their messages are shown to the user, so the
+// cause of a failure, when there is one, is wrapped rather than written into the message.
+
+// NotFoundError is returned when something a request names does not exist, or is not visible to
+// the user making it
+type NotFoundError struct {
+	Msg string
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Msg
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError is returned when a change clashes with the current state, such as a name that is
+// already in use or a request that has already been answered
+type ConflictError struct {
+	Msg string
+	Err error
+}
+
+func (e *ConflictError) Error() string {
+	return e.Msg
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// ForbiddenError is returned when the user is not allowed to do what they asked
+type ForbiddenError struct {
+	Msg string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Msg
+}
+
+// ValidationError is returned when a value the user gave is not valid. Field names the form field
+// the value came from and is empty when the problem is not with a single field.
+type ValidationError struct {
+	Field string
+	Msg   string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
+
+// InternalError is a failure the user can do nothing about, such as a database error. Its message
+// hides the cause, which is kept for whoever logs the error.
+type InternalError struct {
+	Op  string
+	Err error
+}
+
+func (e *InternalError) Error() string {
+	return "An unexpected error occurred"
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// Detail describes the failure and its cause for the logs
+func (e *InternalError) Detail() string {
+	return e.Op + ": " + e.Err.Error()
+}
+
+func notFound(msg string) error {
+	return &NotFoundError{Msg: msg}
+}
+
+func conflict(msg string) error {
+	return &ConflictError{Msg: msg}
+}
+
+func forbidden(msg string) error {
+	return &ForbiddenError{Msg: msg}
+}
+
+func invalid(field string, msg string) error {
+	return &ValidationError{Field: field, Msg: msg}
+}
+
+func internal(op string, e error) error {
+	return &InternalError{Op: op, Err: e}
+}
+
+// lookup classifies an error from fetching something by id. A missing record is reported with
+// msg and anything else is an internal error of op.
+func lookup(op string, msg string, e error) error {
+	if errors.Is(e, storageErr.ErrNotFound) {
+		return &NotFoundError{Msg: msg, Err: e}
+	}
+	return internal(op, e)
+}
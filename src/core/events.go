@@ -0,0 +1,106 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventAssigned         EventType = "chore.assigned"
+	EventDueSoon          EventType = "chore.due_soon"
+	EventOverdue          EventType = "chore.overdue"
+	EventOverdueEscalated EventType = "chore.overdue.escalated"
+	EventInvitation       EventType = "group.invitation"
+	EventSwapRequested    EventType = "swap.requested"
+	EventSwapResolved     EventType = "swap.resolved"
+	EventRoleChanged      EventType = "role.changed"
+	EventCompleted        EventType = "chore.completed"
+	EventRotation         EventType = "chore.rotation"
+	EventMemberJoined     EventType = "member.joined"
+	EventChoreChanged     EventType = "chore.changed"
+)
+
+// Text returns a description of the event type for display
+func (t EventType) Text() string {
+	switch t {
+	case EventAssigned:
+		return "Chore assigned"
+	case EventDueSoon:
+		return "Chore due soon"
+	case EventOverdue:
+		return "Chore overdue"
+	case EventOverdueEscalated:
+		return "Overdue chore escalated"
+	case EventInvitation:
+		return "Added to group"
+	case EventSwapRequested:
+		return "Swap requested"
+	case EventSwapResolved:
+		return "Swap resolved"
+	case EventRoleChanged:
+		return "Role changed"
+	case EventCompleted:
+		return "Chore completed"
+	case EventRotation:
+		return "Rotation run"
+	case EventMemberJoined:
+		return "Member joined"
+	case EventChoreChanged:
+		return "Chore changed"
+	}
+	return string(t)
+}
+
+// Event describes something that happened in a group. Recipients are the users the event is
+// addressed to, such as the assignee of an overdue chore. Events about the group as a whole, such
+// as a rotation, have no recipients.
+type Event struct {
+	Type       EventType
+	Group      *Group
+	Actor      *User
+	Recipients []*User
+	Chore      *Chore
+	Message    string
+	CreatedAt  time.Time
+}
+
+// EventSink receives events published by the core services
+type EventSink interface {
+	Publish(e Event)
+}
+
+// EventSinkFunc allows a plain function to be used as an EventSink
+type EventSinkFunc func(e Event)
+
+func (f EventSinkFunc) Publish(e Event) {
+	f(e)
+}
+
+// Events fans published events out to every subscribed sink
+type Events struct {
+	mu    sync.RWMutex
+	sinks []EventSink
+}
+
+func NewEvents() *Events {
+	return &Events{}
+}
+
+func (ev *Events) Subscribe(sink EventSink) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	ev.sinks = append(ev.sinks, sink)
+}
+
+func (ev *Events) Publish(e Event) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	ev.mu.RLock()
+	defer ev.mu.RUnlock()
+	for _, s := range ev.sinks {
+		s.Publish(e)
+	}
+}
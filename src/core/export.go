@@ -0,0 +1,343 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportVersion is the version of the export document. It changes whenever the format changes in
+// a way older readers would not understand.
+const ExportVersion = 1
+
+// permissionNames names each permission bit in export documents
+var permissionNames = []struct {
+	Bit  PermBit
+	Name string
+}{
+	{EditMembers, "edit_members"},
+	{EditChores, "edit_chores"},
+	{EditGroup, "edit_group"},
+	{EditRoles, "edit_roles"},
+	{ViewAudit, "view_audit"},
+}
+
+// constraintNames names each constraint kind in export documents
+var constraintNames = map[ConstraintKind]string{
+	ConstraintPin:     "only",
+	ConstraintExclude: "never",
+	ConstraintRole:    "role",
+}
+
+// GroupExport is a complete copy of a group's data. Members, chores and roles refer to each other
+// by name so the document can be read without knowing the ids used by this server.
+type GroupExport struct {
+	Version     int                `json:"version"`
+	ExportedAt  time.Time          `json:"exported_at"`
+	Group       ExportGroup        `json:"group"`
+	Members     []ExportMember     `json:"members"`
+	Roles       []ExportRole       `json:"roles"`
+	Chores      []ExportChore      `json:"chores"`
+	Assignments []ExportAssignment `json:"assignments"`
+	History     []ExportHistory    `json:"history"`
+}
+
+type ExportGroup struct {
+	Name          string       `json:"name"`
+	MissedPolicy  MissedPolicy `json:"missed_policy"`
+	RemindAfter   int          `json:"remind_after"`
+	EscalateAfter int          `json:"escalate_after"`
+	TimeZone      string       `json:"time_zone,omitempty"`
+}
+
+type ExportMember struct {
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+	Debt     int       `json:"debt"`
+	Roles    []string  `json:"roles"`
+}
+
+type ExportRole struct {
+	Name        string   `json:"name"`
+	GetsChores  bool     `json:"gets_chores"`
+	Permissions []string `json:"permissions"`
+}
+
+type ExportChore struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Duration    int                `json:"duration"`
+	Points      int                `json:"points"`
+	Recurrence  Recurrence         `json:"recurrence"`
+	Constraints []ExportConstraint `json:"constraints,omitempty"`
+}
+
+type ExportConstraint struct {
+	Kind string `json:"kind"`
+	User string `json:"user,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+type ExportAssignment struct {
+	Chore        string     `json:"chore"`
+	User         string     `json:"user"`
+	DateAssigned time.Time  `json:"date_assigned"`
+	DateDue      time.Time  `json:"date_due"`
+	DateComplete *time.Time `json:"date_complete,omitempty"`
+	Complete     bool       `json:"complete"`
+	Overdue      bool       `json:"overdue"`
+}
+
+type ExportHistory struct {
+	Chore        string     `json:"chore"`
+	User         string     `json:"user"`
+	DateAssigned time.Time  `json:"date_assigned"`
+	DateDue      time.Time  `json:"date_due"`
+	DateComplete *time.Time `json:"date_complete,omitempty"`
+	Points       int        `json:"points"`
+	OnTime       bool       `json:"on_time"`
+	Missed       bool       `json:"missed"`
+}
+
+type ExportRepository interface {
+	GetGroupByID(group *Group) error
+	GetMemberships(t interface{}) error
+	GetRoles(t interface{}) error
+	GetChores(t interface{}) error
+	GetConstraints(t interface{}) error
+	GetHistory(t interface{}) error
+}
+
+type ExportService interface {
+	// Export builds the export of the group for a member allowed to edit it
+	Export(g *Group, user *User) (*GroupExport, error)
+	// Build builds the export of the group without checking who asked for it. It is meant for
+	// operators running commands on the server.
+	Build(g *Group) (*GroupExport, error)
+}
+
+type exportService struct {
+	repo ExportRepository
+}
+
+func NewExportService(r ExportRepository) ExportService {
+	return &exportService{
+		repo: r,
+	}
+}
+
+func (s *exportService) Export(g *Group, user *User) (*GroupExport, error) {
+	mem := Membership{Group: g, User: user}
+	if e := s.repo.GetRoles(&mem); e != nil {
+		return nil, internal("ExportService.Export", e)
+	}
+	mem.BuildSuperRole()
+	if !mem.SuperRole.Can(EditGroup) {
+		return nil, forbidden("You do not have permission to export this group")
+	}
+	return s.Build(g)
+}
+
+func (s *exportService) Build(g *Group) (*GroupExport, error) {
+	if e := s.load(g); e != nil {
+		return nil, internal("ExportService.Build", e)
+	}
+	x := GroupExport{
+		Version:    ExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Group: ExportGroup{
+			Name:          g.Name,
+			MissedPolicy:  g.Settings.MissedPolicy,
+			RemindAfter:   g.Settings.RemindAfter,
+			EscalateAfter: g.Settings.EscalateAfter,
+			TimeZone:      g.Settings.TimeZone,
+		},
+		Members:     make([]ExportMember, 0, len(g.Memberships)),
+		Roles:       make([]ExportRole, 0, len(g.Roles)),
+		Chores:      make([]ExportChore, 0, len(g.Chores)),
+		Assignments: make([]ExportAssignment, 0),
+		History:     make([]ExportHistory, 0, len(g.History)),
+	}
+	for _, m := range g.Memberships {
+		em := ExportMember{Username: m.User.Username, JoinedAt: m.JoinedAt, Debt: m.Debt, Roles: make([]string, 0)}
+		for _, r := range m.Roles {
+			em.Roles = append(em.Roles, r.Name)
+		}
+		x.Members = append(x.Members, em)
+	}
+	for i := range g.Roles {
+		x.Roles = append(x.Roles, exportRole(&g.Roles[i]))
+	}
+	for i := range g.Chores {
+		c := &g.Chores[i]
+		x.Chores = append(x.Chores, exportChore(c))
+		if ca := c.Assignment; ca != nil {
+			x.Assignments = append(x.Assignments, ExportAssignment{
+				Chore:        c.Name,
+				User:         ca.User.Username,
+				DateAssigned: ca.DateAssigned,
+				DateDue:      ca.DateDue,
+				DateComplete: optionalTime(ca.DateComplete),
+				Complete:     ca.Complete,
+				Overdue:      ca.Overdue,
+			})
+		}
+	}
+	for _, h := range g.History {
+		x.History = append(x.History, ExportHistory{
+			Chore:        h.Chore.Name,
+			User:         h.User.Username,
+			DateAssigned: h.DateAssigned,
+			DateDue:      h.DateDue,
+			DateComplete: optionalTime(h.DateComplete),
+			Points:       h.Points,
+			OnTime:       h.OnTime,
+			Missed:       h.Missed,
+		})
+	}
+	return &x, nil
+}
+
+// PermissionNames lists the permissions the role grants by the names used in exports
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0)
+	for _, p := range permissionNames {
+		if r.Can(p.Bit) {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+func exportRole(r *Role) ExportRole {
+	return ExportRole{Name: r.Name, GetsChores: r.GetsChores, Permissions: r.PermissionNames()}
+}
+
+// importRole builds a role from its exported form. Unknown permissions are ignored.
+func importRole(er *ExportRole) Role {
+	r := Role{Name: er.Name, GetsChores: er.GetsChores}
+	for _, name := range er.Permissions {
+		for _, p := range permissionNames {
+			if p.Name == name {
+				r.Set(p.Bit, true)
+			}
+		}
+	}
+	return r
+}
+
+func exportChore(c *Chore) ExportChore {
+	ec := ExportChore{
+		Name:        c.Name,
+		Description: c.Description,
+		Duration:    c.Duration,
+		Points:      c.Points,
+		Recurrence:  c.Recurrence,
+	}
+	for _, con := range c.Constraints {
+		ex := ExportConstraint{Kind: constraintNames[con.Kind]}
+		if con.User != nil {
+			ex.User = con.User.Username
+		}
+		if con.Role != nil {
+			ex.Role = con.Role.Name
+		}
+		ec.Constraints = append(ec.Constraints, ex)
+	}
+	return ec
+}
+
+func importChore(ec *ExportChore) Chore {
+	return Chore{
+		Name:        ec.Name,
+		Description: ec.Description,
+		Duration:    ec.Duration,
+		Points:      ec.Points,
+		Recurrence:  ec.Recurrence,
+	}
+}
+
+// load reads everything an export contains into the group
+func (s *exportService) load(g *Group) error {
+	if e := s.repo.GetGroupByID(g); e != nil {
+		return e
+	}
+	if e := s.repo.GetMemberships(g); e != nil {
+		return e
+	}
+	for i := range g.Memberships {
+		if e := s.repo.GetRoles(&g.Memberships[i]); e != nil {
+			return e
+		}
+	}
+	if e := s.repo.GetRoles(g); e != nil {
+		return e
+	}
+	g.Chores = nil
+	if e := s.repo.GetChores(g); e != nil {
+		return e
+	}
+	if e := s.repo.GetConstraints(g); e != nil {
+		return e
+	}
+	return s.repo.GetHistory(g)
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// WriteJSON writes the export as an indented JSON document
+func (x *GroupExport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(x)
+}
+
+// WriteChoresCSV writes a row for each chore with its current assignment. The first four columns
+// are the columns read by the chore import.
+func (x *GroupExport) WriteChoresCSV(w io.Writer) error {
+	assigned := make(map[string]*ExportAssignment)
+	for i := range x.Assignments {
+		assigned[x.Assignments[i].Chore] = &x.Assignments[i]
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "description", "duration", "recurrence", "points", "assignee", "date_due", "complete"})
+	for _, c := range x.Chores {
+		row := []string{c.Name, c.Description, strconv.Itoa(c.Duration), string(c.Recurrence),
+			strconv.Itoa(c.Points), "", "", ""}
+		if a := assigned[c.Name]; a != nil {
+			row[5] = a.User
+			row[6] = formatTime(&a.DateDue)
+			row[7] = strconv.FormatBool(a.Complete)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHistoryCSV writes a row for each entry of the group's completion history
+func (x *GroupExport) WriteHistoryCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"chore", "user", "date_assigned", "date_due", "date_complete", "points", "on_time", "missed"})
+	for _, h := range x.History {
+		cw.Write([]string{h.Chore, h.User, formatTime(&h.DateAssigned), formatTime(&h.DateDue),
+			formatTime(h.DateComplete), strconv.Itoa(h.Points), strconv.FormatBool(h.OnTime),
+			strconv.FormatBool(h.Missed)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+type FeedRepository interface {
+	CreateFeedToken(t *FeedToken) error
+	// GetFeedToken loads the user and group the token belongs to
+	GetFeedToken(t *FeedToken) error
+	GetFeedTokens(user *User) error
+	DeleteFeedToken(t *FeedToken) error
+	GetMembership(mem *Membership) error
+	GetGroupByID(group *Group) error
+	GetChores(t interface{}) error
+}
+
+type FeedService interface {
+	// Create issues a new feed token for the user. Passing a group creates a feed of the group's
+	// chores, otherwise the feed holds the user's own chores.
+	Create(user *User, group *Group) (*FeedToken, error)
+	// Revoke deletes one of the user's feed tokens so the feed can no longer be read with it
+	Revoke(t *FeedToken, user *User) error
+	GetFeedTokens(user *User) error
+	// Feed returns the chores published by the feed the token grants access to
+	Feed(token string) (*Feed, error)
+}
+
+type feedService struct {
+	repo FeedRepository
+}
+
+func NewFeedService(r FeedRepository) FeedService {
+	return &feedService{
+		repo: r,
+	}
+}
+
+func (s *feedService) Create(user *User, group *Group) (*FeedToken, error) {
+	if group != nil {
+		if e := s.repo.GetMembership(&Membership{User: user, Group: group}); e != nil {
+			return nil, notFound("Member not found")
+		}
+	}
+	b := make([]byte, 32)
+	if _, e := rand.Read(b); e != nil {
+		return nil, internal("FeedService.Create", fmt.Errorf("generate token: %w", e))
+	}
+	t := FeedToken{Token: hex.EncodeToString(b), User: user, Group: group, CreatedAt: time.Now().UTC()}
+	if e := s.repo.CreateFeedToken(&t); e != nil {
+		return nil, internal("FeedService.Create", e)
+	}
+	return &t, nil
+}
+
+func (s *feedService) Revoke(t *FeedToken, user *User) error {
+	if e := s.repo.GetFeedToken(t); e != nil {
+		return notFound("Feed not found")
+	}
+	if t.User.ID != user.ID {
+		return notFound("Feed not found")
+	}
+	if e := s.repo.DeleteFeedToken(t); e != nil {
+		return internal("FeedService.Revoke", e)
+	}
+	return nil
+}
+
+func (s *feedService) GetFeedTokens(user *User) error {
+	return s.repo.GetFeedTokens(user)
+}
+
+func (s *feedService) Feed(token string) (*Feed, error) {
+	t := FeedToken{Token: token}
+	if e := s.repo.GetFeedToken(&t); e != nil {
+		return nil, lookup("FeedService.Feed", "Feed not found", e)
+	}
+	feed := Feed{Token: &t}
+	if t.Group == nil {
+		if e := s.repo.GetChores(t.User); e != nil {
+			return nil, e
+		}
+		feed.Name = fmt.Sprintf("%s's chores", t.User.Username)
+		feed.Chores = t.User.Chores
+		return &feed, nil
+	}
+	// Leaving a group ends access to its feed even if the token was never revoked
+	if e := s.repo.GetMembership(&Membership{User: t.User, Group: t.Group}); e != nil {
+		return nil, e
+	}
+	if e := s.repo.GetGroupByID(t.Group); e != nil {
+		return nil, e
+	}
+	if e := s.repo.GetChores(t.Group); e != nil {
+		return nil, e
+	}
+	feed.Name = fmt.Sprintf("%s chores", t.Group.Name)
+	feed.Chores = t.Group.Chores
+	return &feed, nil
+}
@@ -1,12 +1,14 @@
 package core
 
 import (
-	"errors"
-	"log"
+	"chores-suck/logging"
+	"fmt"
 	"time"
 )
 
 type GroupRepository interface {
+	// CreateGroup saves a new group together with its memberships, its roles and their members and
+	// its chores in a single transaction
 	CreateGroup(group *Group) error
 	CreateRole(role *Role) error
 	CreateRoleAssignment(roleID uint64, userID uint64) error
@@ -19,13 +21,17 @@ type GroupRepository interface {
 	DeleteMember(mem *Membership) error
 	UpdateRole(role *Role) error
 	GetChores(t interface{}) error
+	CreateChore(ch *Chore) error
+	GetGroups() ([]Group, error)
 }
 
 type GroupService interface {
 	// CreateGroup creates a new group with the default roles (owner, admin, default) and creates a new membership
-	// for the owner (passed in user)
-	CreateGroup(name string, user *User) error
+	// for the owner (passed in user). The roles and chores of the template are added when one is given.
+	CreateGroup(name string, user *User, tmpl *GroupTemplate) error
 	GetGroup(group *Group) error
+	// GetGroups returns every group on the server. It is meant for operators, not members.
+	GetGroups() ([]Group, error)
 	GetMemberships(t interface{}) error
 	GetMembership(mem *Membership) error
 	GetRoles(t interface{}) error
@@ -39,72 +45,81 @@ type GroupService interface {
 }
 
 type groupService struct {
-	repo GroupRepository
+	repo   GroupRepository
+	audit  AuditService
+	events EventSink
+	log    *logging.Logger
 }
 
-func NewGroupService(r GroupRepository) GroupService {
+func NewGroupService(r GroupRepository, a AuditService, ev EventSink, l *logging.Logger) GroupService {
 	return &groupService{
-		repo: r,
+		repo:   r,
+		audit:  a,
+		events: ev,
+		log:    l,
 	}
 }
 
-func (s *groupService) CreateGroup(name string, user *User) error {
-	group := Group{Name: name}
-	e := s.repo.CreateGroup(&group)
-	if e != nil {
-		return e
-	}
-
+func (s *groupService) CreateGroup(name string, user *User, tmpl *GroupTemplate) error {
+	// A new group keeps time in its creator's zone until someone changes it
+	group := Group{Name: name, Settings: GroupSettings{TimeZone: user.TimeZone}}
 	mem := Membership{JoinedAt: time.Now().UTC(), User: user, Group: &group}
-	e = s.repo.CreateMembership(&mem)
-	if e != nil {
-		return e
-	}
-	owner := Role{Name: "Owner", Group: &group}
-	owner.SetAll(true)
-	e = s.repo.CreateRole(&owner)
-	if e != nil {
-		return e
-	}
-
-	e = s.repo.CreateRoleAssignment(owner.ID, user.ID)
-	if e != nil {
-		return e
-	}
+	group.Memberships = []Membership{mem}
 
-	admin := Role{Name: "Admin", Group: &group}
+	owner := Role{Name: "Owner", Group: &group, Members: []Membership{mem}}
+	owner.SetAll(true)
+	admin := Role{Name: "Admin", Group: &group, Members: []Membership{mem}}
 	admin.SetAll(true)
-	e = s.repo.CreateRole(&admin)
-	if e != nil {
-		return e
-	}
+	def := Role{Name: "Default", Group: &group, GetsChores: true, Members: []Membership{mem}}
+	group.Roles = []Role{owner, admin, def}
 
-	e = s.repo.CreateRoleAssignment(admin.ID, user.ID)
-	if e != nil {
-		return e
+	after := fmt.Sprintf("name=%s", group.Name)
+	if tmpl != nil {
+		if e := applyTemplate(&group, tmpl); e != nil {
+			return e
+		}
+		after = fmt.Sprintf("%s template=%s", after, tmpl.Name)
 	}
-
-	def := Role{Name: "Default", Group: &group, GetsChores: true}
-	e = s.repo.CreateRole(&def)
-	if e != nil {
-		return e
+	if e := s.repo.CreateGroup(&group); e != nil {
+		return internal("GroupService.CreateGroup", e)
 	}
+	s.audit.Record(&group, user, ActionGroupCreate, group.Name, "", after)
+	return nil
+}
 
-	e = s.repo.CreateRoleAssignment(def.ID, user.ID)
-	if e != nil {
-		return e
+// applyTemplate adds the template's roles and chores to a new group that has not been saved yet.
+// Every chore is checked first, so a bad template leaves nothing behind.
+func applyTemplate(g *Group, tmpl *GroupTemplate) error {
+	for i := range tmpl.Roles {
+		if isDefaultRole(tmpl.Roles[i].Name) {
+			continue
+		}
+		role := importRole(&tmpl.Roles[i])
+		role.Group = g
+		g.Roles = append(g.Roles, role)
+	}
+	for i := range tmpl.Chores {
+		ch := importChore(&tmpl.Chores[i])
+		ch.Group = g
+		if e := validateChore(&ch); e != nil {
+			return fmt.Errorf("template chore %s: %w", ch.Name, e)
+		}
+		g.Chores = append(g.Chores, ch)
 	}
-
 	return nil
 }
 
 func (s *groupService) GetGroup(group *Group) error {
 	if e := s.repo.GetGroupByID(group); e != nil {
-		return e
+		return lookup("GroupService.GetGroup", "Group not found", e)
 	}
 	return nil
 }
 
+func (s *groupService) GetGroups() ([]Group, error) {
+	return s.repo.GetGroups()
+}
+
 func (s *groupService) GetMemberships(t interface{}) error {
 	return s.repo.GetMemberships(t)
 }
@@ -122,12 +137,50 @@ func (s *groupService) UpdateGroup(group *Group, user *User) error {
 	mem := group.FindMember(user.ID)
 	e := s.GetRoles(mem)
 	if e != nil {
-		return e
+		return internal("GroupService.UpdateGroup", e)
 	}
 	if !mem.SuperRole.Can(EditGroup) {
-		return errors.New("Insufficient permissions")
+		return forbidden("Insufficient permissions")
+	}
+	if e := validateSettings(&group.Settings); e != nil {
+		return e
+	}
+	old := Group{ID: group.ID}
+	if e := s.repo.GetGroupByID(&old); e != nil {
+		return internal("GroupService.UpdateGroup", e)
+	}
+	if e := s.repo.UpdateGroup(group); e != nil {
+		return internal("GroupService.UpdateGroup", e)
+	}
+	if old.Name != group.Name {
+		s.audit.Record(group, user, ActionGroupRename, group.Name, fmt.Sprintf("name=%s", old.Name),
+			fmt.Sprintf("name=%s", group.Name))
+	}
+	if old.Settings != group.Settings {
+		s.audit.Record(group, user, ActionGroupSettings, group.Name, describeSettings(&old.Settings),
+			describeSettings(&group.Settings))
 	}
-	return s.repo.UpdateGroup(group)
+	return nil
+}
+
+func validateSettings(gs *GroupSettings) error {
+	valid := false
+	for _, p := range MissedPolicies {
+		valid = valid || gs.MissedPolicy == p
+	}
+	if !valid {
+		return invalid("missed_policy", "Unknown missed chore policy")
+	}
+	if gs.RemindAfter < 0 || gs.EscalateAfter < 0 {
+		return invalid("remind_after", "Delays cannot be negative")
+	}
+	if gs.EscalateAfter < gs.RemindAfter {
+		return invalid("escalate_after", "Admins cannot be notified before the assignee")
+	}
+	if !validTimeZone(gs.TimeZone) {
+		return invalid("time_zone", "Unknown time zone")
+	}
+	return nil
 }
 
 func (s *groupService) CanEdit(group *Group, user *User) bool {
@@ -141,12 +194,12 @@ func (s *groupService) CanEdit(group *Group, user *User) bool {
 		}
 	}
 	if !isMember {
-		log.Print("User is not a member of the group to edit")
+		s.log.Debug("User is not a member of the group to edit", "user", user.ID, "group", group.ID)
 		return false
 	}
-	log.Printf("CanEdit: Number of roles: %v", len(mem.Roles))
 	if !mem.SuperRole.CanEdit() {
-		log.Printf("User has insufficient privileges to edit this group: %v", mem.SuperRole.Permissions)
+		s.log.Debug("User has insufficient privileges to edit the group", "user", user.ID, "group", group.ID,
+			"permissions", mem.SuperRole.Permissions)
 		return false
 	}
 	return true
@@ -155,22 +208,23 @@ func (s *groupService) CanEdit(group *Group, user *User) bool {
 func (s *groupService) DeleteMember(mem *Membership, user *User) error {
 	authMem := mem.Group.FindMember(user.ID)
 	if e := s.GetRoles(authMem); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.DeleteMember", e)
 	}
 	if !authMem.SuperRole.Can(EditMembers) {
-		return errors.New("You do not have permission to remove members!")
+		return forbidden("You do not have permission to remove members!")
 	}
 	if e := s.GetRoles(mem); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.DeleteMember", e)
 	}
 	for _, v := range mem.Roles {
 		if v.Name == "Owner" {
-			return errors.New("Cannot delete owner")
+			return forbidden("Cannot delete owner")
 		}
 	}
 	if e := s.repo.DeleteMember(mem); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.DeleteMember", e)
 	}
+	s.audit.Record(mem.Group, user, ActionMemberRemove, memberName(mem), "member", "")
 	return nil
 }
 
@@ -191,65 +245,92 @@ func (s *groupService) GetMembership(mem *Membership) error {
 func (s *groupService) AddMember(mem *Membership, user *User) error {
 	authMem := mem.Group.FindMember(user.ID)
 	if e := s.GetRoles(authMem); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.AddMember", e)
 	}
 	if !authMem.SuperRole.Can(EditMembers) {
-		return errors.New("You do not have permission to add members!")
+		return forbidden("You do not have permission to add members!")
 	}
 	mem.JoinedAt = time.Now().UTC()
 	if e := s.repo.CreateMembership(mem); e != nil {
-		return errors.New("An unexpected error occurred")
-	}
+		return internal("GroupService.AddMember", e)
+	}
+	s.audit.Record(mem.Group, user, ActionMemberAdd, memberName(mem), "", "member")
+	s.events.Publish(Event{
+		Type:       EventInvitation,
+		Group:      mem.Group,
+		Actor:      user,
+		Recipients: []*User{mem.User},
+		Message:    fmt.Sprintf("%s added you to %s", user.Username, mem.Group.Name),
+	})
+	s.events.Publish(Event{
+		Type:    EventMemberJoined,
+		Group:   mem.Group,
+		Actor:   user,
+		Message: fmt.Sprintf("%s joined %s", memberName(mem), mem.Group.Name),
+	})
 	return nil
 }
 
 func (s *groupService) AddRole(role *Role, user *User) error {
 	mem := role.Group.FindMember(user.ID)
 	if e := s.GetRoles(mem); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.AddRole", e)
 	}
 	if !mem.SuperRole.Can(EditRoles) {
-		return errors.New("You do not have permission to add roles!")
+		return forbidden("You do not have permission to add roles!")
 	}
 	if e := s.GetRoles(role.Group); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.AddRole", e)
 	}
 	for _, r := range role.Group.Roles {
 		if r.Name == role.Name {
-			return errors.New("Role already exists")
+			return conflict("Role already exists")
 		}
 	}
 	if e := s.repo.CreateRole(role); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.AddRole", e)
 	}
+	s.audit.Record(role.Group, user, ActionRoleCreate, role.Name, "", describeRole(role))
 	return nil
 }
 
 func (s *groupService) UpdateRole(role *Role, user *User) error {
 	mem := role.Group.FindMember(user.ID)
 	if e := s.GetRoles(mem); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.UpdateRole", e)
 	}
 	if !mem.SuperRole.Can(EditRoles) {
-		return errors.New("You do not have permission to update roles")
+		return forbidden("You do not have permission to update roles")
 	}
 	s.GetRoles(role.Group)
 	oldRole := role.Group.FindRole(role.ID)
 	if oldRole == nil {
-		return errors.New("Invalid request")
+		return notFound("Role not found")
 	} else if oldRole.Name == "Owner" || oldRole.Name == "Admin" || oldRole.Name == "Default" {
-		return errors.New("Cannot make changes to Owner, Admin, or Default roles")
+		return forbidden("Cannot make changes to Owner, Admin, or Default roles")
 	}
 	if e := s.repo.UpdateRole(role); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.UpdateRole", e)
 	}
+	s.audit.Record(role.Group, user, ActionRoleUpdate, oldRole.Name, describeRole(oldRole), describeRole(role))
 	return nil
 }
 
 func (s *groupService) GetChores(group *Group) error {
 	if e := s.repo.GetChores(group); e != nil {
-		log.Printf("Web: GroupService: GetChores: %s", e.Error())
-		return errors.New("An unexpected error occurred")
+		return internal("GroupService.GetChores", e)
 	}
 	return nil
 }
+
+// memberName returns the username of the membership, falling back to the user id when the
+// username has not been loaded
+func memberName(mem *Membership) string {
+	if mem.User.Username != "" {
+		return mem.User.Username
+	}
+	if m := mem.Group.FindMember(mem.User.ID); m != nil && m.User.Username != "" {
+		return m.User.Username
+	}
+	return fmt.Sprintf("user %d", mem.User.ID)
+}
@@ -0,0 +1,292 @@
+package core
+
+import (
+	storageErr "chores-suck/core/storage/errors"
+	"chores-suck/logging"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ImportFormat string
+
+const (
+	// ImportCSV is a CSV of chores with the columns name, description, duration and recurrence. A
+	// header row and any further columns, such as those of the chore export, are ignored.
+	ImportCSV ImportFormat = "csv"
+	// ImportJSON is the JSON group export. Its chores and members are imported.
+	ImportJSON ImportFormat = "json"
+)
+
+// ImportItem is a chore or member read from an import file along with the reason it cannot be
+// imported, if any. Items that are left out without stopping the import, such as members already
+// in the group, are Skipped. Row is the line of a CSV file or the position of the item in a JSON
+// document.
+type ImportItem struct {
+	Row     int
+	Chore   *Chore
+	Member  *Membership
+	Error   string
+	Skipped string
+}
+
+// ImportPlan holds everything an import would add to a group
+type ImportPlan struct {
+	Group *Group
+	Items []ImportItem
+}
+
+// Valid reports whether every item of the plan can be imported and there is something to import
+func (p *ImportPlan) Valid() bool {
+	for _, it := range p.Items {
+		if it.Error != "" {
+			return false
+		}
+	}
+	return len(p.Chores())+len(p.Members()) > 0
+}
+
+func (p *ImportPlan) Chores() []Chore {
+	chores := make([]Chore, 0)
+	for _, it := range p.Items {
+		if it.Chore != nil && it.Skipped == "" {
+			chores = append(chores, *it.Chore)
+		}
+	}
+	return chores
+}
+
+func (p *ImportPlan) Members() []Membership {
+	members := make([]Membership, 0)
+	for _, it := range p.Items {
+		if it.Member != nil && it.Skipped == "" {
+			members = append(members, *it.Member)
+		}
+	}
+	return members
+}
+
+type ImportRepository interface {
+	GetChores(t interface{}) error
+	GetRoles(t interface{}) error
+	GetUserByName(user *User) error
+	// ImportGroupData adds the chores and memberships to the group in a single transaction
+	ImportGroupData(g *Group, chores []Chore, members []Membership) error
+}
+
+type ImportService interface {
+	// Plan reads the import file and checks every item against the group, the way a dry run
+	// would, without changing anything. The group's memberships must already be loaded.
+	Plan(g *Group, user *User, format ImportFormat, r io.Reader) (*ImportPlan, error)
+	// Apply imports everything in the plan at once. Nothing is imported unless the plan is valid.
+	Apply(plan *ImportPlan, user *User) error
+}
+
+type importService struct {
+	repo   ImportRepository
+	audit  AuditService
+	events EventSink
+	log    *logging.Logger
+}
+
+func NewImportService(r ImportRepository, a AuditService, ev EventSink, l *logging.Logger) ImportService {
+	return &importService{
+		repo:   r,
+		audit:  a,
+		events: ev,
+		log:    l,
+	}
+}
+
+func (s *importService) Plan(g *Group, user *User, format ImportFormat, r io.Reader) (*ImportPlan, error) {
+	var items []ImportItem
+	var e error
+	switch format {
+	case ImportCSV:
+		items, e = readChoresCSV(r)
+	case ImportJSON:
+		items, e = readExport(r)
+	default:
+		return nil, invalid("format", "Unknown import format")
+	}
+	if e != nil {
+		return nil, e
+	}
+	if len(items) == 0 {
+		return nil, invalid("data", "The file has nothing to import")
+	}
+	mem := g.FindMember(user.ID)
+	if mem == nil {
+		return nil, notFound("Member not found")
+	}
+	g.Chores = nil
+	if e := s.repo.GetChores(g); e != nil {
+		return nil, internal("ImportService.Plan", e)
+	}
+	if e := s.repo.GetRoles(mem); e != nil {
+		return nil, internal("ImportService.Plan", e)
+	}
+	mem.BuildSuperRole()
+
+	chores := make(map[string]bool)
+	for _, c := range g.Chores {
+		chores[c.Name] = true
+	}
+	members := make(map[string]bool)
+	for _, m := range g.Memberships {
+		members[m.User.Username] = true
+	}
+	plan := ImportPlan{Group: g, Items: items}
+	for i := range plan.Items {
+		it := &plan.Items[i]
+		if it.Error != "" {
+			continue
+		}
+		if it.Chore != nil {
+			it.Chore.Group = g
+			it.Error = s.checkChore(it.Chore, mem, chores)
+		} else if members[it.Member.User.Username] {
+			it.Skipped = "Already a member"
+		} else {
+			it.Member.Group = g
+			it.Error = s.checkMember(it.Member, mem, members)
+		}
+	}
+	return &plan, nil
+}
+
+// checkChore applies the rules of ChoreService.Create to a chore to be imported. Names seen so
+// far are tracked in names so the file cannot repeat a chore either.
+func (s *importService) checkChore(ch *Chore, mem *Membership, names map[string]bool) string {
+	if !mem.SuperRole.Can(EditChores) {
+		return "You do not have permission to add chores"
+	}
+	if e := validateChore(ch); e != nil {
+		return e.Error()
+	}
+	if names[ch.Name] {
+		return "Chore already exists"
+	}
+	names[ch.Name] = true
+	return ""
+}
+
+func (s *importService) checkMember(m *Membership, mem *Membership, names map[string]bool) string {
+	if !mem.SuperRole.Can(EditMembers) {
+		return "You do not have permission to add members"
+	}
+	if e := s.repo.GetUserByName(m.User); errors.Is(e, storageErr.ErrNotFound) {
+		return "User not found"
+	} else if e != nil {
+		s.log.Error("Failed to look up member to import", "username", m.User.Username, "err", e)
+		return "An unexpected error occurred"
+	}
+	names[m.User.Username] = true
+	return ""
+}
+
+func (s *importService) Apply(plan *ImportPlan, user *User) error {
+	if !plan.Valid() {
+		return invalid("data", "Fix the errors in the file before importing it")
+	}
+	g := plan.Group
+	chores := plan.Chores()
+	members := plan.Members()
+	now := time.Now().UTC()
+	for i := range members {
+		members[i].JoinedAt = now
+	}
+	if e := s.repo.ImportGroupData(g, chores, members); e != nil {
+		return internal("ImportService.Apply", e)
+	}
+	for i := range chores {
+		s.audit.Record(g, user, ActionChoreCreate, chores[i].Name, "", describeChore(&chores[i]))
+	}
+	for i := range members {
+		m := &members[i]
+		s.audit.Record(g, user, ActionMemberAdd, memberName(m), "", "member")
+		s.events.Publish(Event{
+			Type:       EventInvitation,
+			Group:      g,
+			Actor:      user,
+			Recipients: []*User{m.User},
+			Message:    fmt.Sprintf("%s added you to %s", user.Username, g.Name),
+		})
+		s.events.Publish(Event{
+			Type:    EventMemberJoined,
+			Group:   g,
+			Actor:   user,
+			Message: fmt.Sprintf("%s joined %s", memberName(m), g.Name),
+		})
+	}
+	if len(chores) > 0 {
+		s.events.Publish(Event{
+			Type:    EventChoreChanged,
+			Group:   g,
+			Actor:   user,
+			Message: fmt.Sprintf("%s imported %d chores", user.Username, len(chores)),
+		})
+	}
+	return nil
+}
+
+func readChoresCSV(r io.Reader) ([]ImportItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, e := cr.ReadAll()
+	if e != nil {
+		return nil, invalid("data", "The file is not a valid CSV file")
+	}
+	items := make([]ImportItem, 0, len(records))
+	for i, rec := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "name") {
+			continue
+		}
+		it := ImportItem{Row: i + 1, Chore: &Chore{}}
+		if len(rec) < 3 {
+			it.Error = "Expected the columns name, description, duration and recurrence"
+			items = append(items, it)
+			continue
+		}
+		it.Chore.Name = strings.TrimSpace(rec[0])
+		it.Chore.Description = strings.TrimSpace(rec[1])
+		d, e := strconv.Atoi(strings.TrimSpace(rec[2]))
+		if e != nil {
+			it.Error = "Duration must be a whole number of minutes"
+		}
+		it.Chore.Duration = d
+		if len(rec) > 3 {
+			it.Chore.Recurrence = Recurrence(strings.ToLower(strings.TrimSpace(rec[3])))
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func readExport(r io.Reader) ([]ImportItem, error) {
+	var x GroupExport
+	if e := json.NewDecoder(r).Decode(&x); e != nil {
+		return nil, invalid("data", "The file is not a valid group export")
+	}
+	if x.Version < 1 || x.Version > ExportVersion {
+		return nil, invalid("data", fmt.Sprintf("Export version %d is not supported", x.Version))
+	}
+	items := make([]ImportItem, 0, len(x.Chores)+len(x.Members))
+	for i := range x.Chores {
+		c := importChore(&x.Chores[i])
+		items = append(items, ImportItem{Row: i + 1, Chore: &c})
+	}
+	for i, m := range x.Members {
+		items = append(items, ImportItem{
+			Row:    i + 1,
+			Member: &Membership{User: &User{Username: m.Username}},
+		})
+	}
+	return items, nil
+}
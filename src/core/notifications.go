@@ -0,0 +1,131 @@
+package core
+
+import (
+	"chores-suck/logging"
+	"net/url"
+	"time"
+)
+
+const (
+	// readRetention is how long notifications are kept in the inbox after they are read
+	readRetention = 30 * 24 * time.Hour
+	// unreadRetention is how long notifications are kept in the inbox when they are never read
+	unreadRetention = 90 * 24 * time.Hour
+)
+
+type NotificationRepository interface {
+	GetChannelPrefs(user *User) error
+	// SaveChannelPref creates or replaces the user's preference for the channel
+	SaveChannelPref(pref *ChannelPref) error
+	CreateNotification(n *Notification) error
+	// GetNotifications loads up to limit of the user's most recent notifications, newest first
+	GetNotifications(user *User, limit int) error
+	CountUnread(user *User) error
+	// MarkRead marks the user's notifications with the given IDs read, or all of them if ids is empty
+	MarkRead(user *User, ids []uint64, at time.Time) error
+	// DeleteNotifications removes notifications read before readBefore and those created before
+	// createdBefore whether they were read or not
+	DeleteNotifications(readBefore time.Time, createdBefore time.Time) (int64, error)
+}
+
+type NotificationService interface {
+	// GetChannelPrefs loads the user's preference for every channel. Channels the user never
+	// configured use the defaults: the inbox and email are enabled, webhooks are not.
+	GetChannelPrefs(user *User) error
+	UpdateChannelPref(pref *ChannelPref, user *User) error
+	CreateNotification(n *Notification) error
+	// GetInbox loads up to limit of the user's most recent notifications and the unread count
+	GetInbox(user *User, limit int) error
+	CountUnread(user *User) error
+	// MarkRead marks the listed notifications read, or every notification if ids is empty
+	MarkRead(user *User, ids []uint64) error
+	// Purge deletes notifications past their retention. It is meant to be run by the scheduler.
+	Purge(now time.Time)
+}
+
+type notificationService struct {
+	repo NotificationRepository
+	log  *logging.Logger
+}
+
+func NewNotificationService(r NotificationRepository, l *logging.Logger) NotificationService {
+	return &notificationService{
+		repo: r,
+		log:  l,
+	}
+}
+
+func (s *notificationService) GetChannelPrefs(user *User) error {
+	user.Channels = nil
+	if e := s.repo.GetChannelPrefs(user); e != nil {
+		return e
+	}
+	for _, c := range Channels {
+		if user.Channel(c) == nil {
+			user.Channels = append(user.Channels, ChannelPref{User: user, Channel: c, Enabled: c != ChannelWebhook})
+		}
+	}
+	return nil
+}
+
+func (s *notificationService) UpdateChannelPref(pref *ChannelPref, user *User) error {
+	if pref.User.ID != user.ID {
+		return forbidden("You can only change your own notification settings")
+	}
+	known := false
+	for _, c := range Channels {
+		known = known || c == pref.Channel
+	}
+	if !known {
+		return invalid("", "Unknown notification channel")
+	}
+	if pref.Channel == ChannelWebhook && pref.Target != "" {
+		u, e := url.Parse(pref.Target)
+		if e != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid("webhook_target", "Webhook URL must be a valid http or https URL")
+		}
+		if e := checkWebhookHost("webhook_target", u.Hostname()); e != nil {
+			return e
+		}
+	}
+	if pref.Channel == ChannelWebhook && pref.Enabled && pref.Target == "" {
+		return invalid("webhook_target", "A webhook URL is required to enable webhooks")
+	}
+	if e := s.repo.SaveChannelPref(pref); e != nil {
+		return internal("NotificationService.UpdateChannelPref", e)
+	}
+	return nil
+}
+
+func (s *notificationService) CreateNotification(n *Notification) error {
+	return s.repo.CreateNotification(n)
+}
+
+func (s *notificationService) GetInbox(user *User, limit int) error {
+	if e := s.repo.GetNotifications(user, limit); e != nil {
+		return e
+	}
+	return s.repo.CountUnread(user)
+}
+
+func (s *notificationService) CountUnread(user *User) error {
+	return s.repo.CountUnread(user)
+}
+
+func (s *notificationService) MarkRead(user *User, ids []uint64) error {
+	if e := s.repo.MarkRead(user, ids, time.Now().UTC()); e != nil {
+		return internal("NotificationService.MarkRead", e)
+	}
+	return nil
+}
+
+func (s *notificationService) Purge(now time.Time) {
+	n, e := s.repo.DeleteNotifications(now.Add(-readRetention), now.Add(-unreadRetention))
+	if e != nil {
+		s.log.Error("Failed to purge notifications", "err", e)
+		return
+	}
+	if n > 0 {
+		s.log.Info("Purged notifications", "deleted", n)
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"chores-suck/logging"
+	"fmt"
+	"time"
+)
+
+// dueSoonWindow is how long before its due date an assignee is reminded of a chore
+const dueSoonWindow = 24 * time.Hour
+
+type OverdueRepository interface {
+	// GetOverdueAssignments returns the incomplete assignments due before now that have not been
+	// escalated to the group admins yet. The chore and its group, including settings, are loaded.
+	GetOverdueAssignments(now time.Time) ([]ChoreAssignment, error)
+	// GetDueSoonAssignments returns the incomplete assignments due between now and until whose
+	// assignee has not been reminded yet
+	GetDueSoonAssignments(now time.Time, until time.Time) ([]ChoreAssignment, error)
+	// UpdateAssignmentState saves the reminder, overdue and escalation state of the assignment
+	UpdateAssignmentState(ca *ChoreAssignment) error
+}
+
+type OverdueService interface {
+	// CheckOverdue marks assignments that are past due as overdue and escalates them according to
+	// their group's settings. It is meant to be run periodically by the scheduler.
+	CheckOverdue(now time.Time)
+	// CheckDueSoon reminds assignees of chores that are due within the next day
+	CheckDueSoon(now time.Time)
+}
+
+type overdueService struct {
+	repo   OverdueRepository
+	gs     GroupService
+	events EventSink
+	log    *logging.Logger
+}
+
+func NewOverdueService(r OverdueRepository, g GroupService, ev EventSink, l *logging.Logger) OverdueService {
+	return &overdueService{
+		repo:   r,
+		gs:     g,
+		events: ev,
+		log:    l,
+	}
+}
+
+func (s *overdueService) CheckOverdue(now time.Time) {
+	cas, e := s.repo.GetOverdueAssignments(now)
+	if e != nil {
+		s.log.Error("Failed to get overdue assignments", "err", e)
+		return
+	}
+	admins := make(map[uint64][]*User)
+	for i := range cas {
+		ca := &cas[i]
+		g := ca.Chore.Group
+		changed := !ca.Overdue
+		ca.Overdue = true
+		if ca.Escalation < EscalateAssignee && !now.Before(ca.DateDue.Add(hours(g.Settings.RemindAfter))) {
+			ca.Escalation = EscalateAssignee
+			changed = true
+			s.events.Publish(Event{
+				Type:       EventOverdue,
+				Group:      g,
+				Chore:      ca.Chore,
+				Recipients: []*User{ca.User},
+				Message:    fmt.Sprintf("%s in %s was due %s", ca.Chore.Name, g.Name, localDue(ca)),
+			})
+		}
+		if ca.Escalation == EscalateAssignee && !now.Before(ca.DateDue.Add(hours(g.Settings.EscalateAfter))) {
+			if _, ok := admins[g.ID]; !ok {
+				admins[g.ID] = s.admins(g)
+			}
+			ca.Escalation = EscalateAdmins
+			changed = true
+			s.events.Publish(Event{
+				Type:       EventOverdueEscalated,
+				Group:      g,
+				Chore:      ca.Chore,
+				Recipients: admins[g.ID],
+				Message: fmt.Sprintf("%s in %s assigned to %s is overdue since %s", ca.Chore.Name, g.Name,
+					ca.User.Username, localDue(ca)),
+			})
+		}
+		if !changed {
+			continue
+		}
+		if e := s.repo.UpdateAssignmentState(ca); e != nil {
+			s.log.Error("Failed to update overdue assignment", "chore", ca.Chore.ID, "err", e)
+		}
+	}
+}
+
+func (s *overdueService) CheckDueSoon(now time.Time) {
+	cas, e := s.repo.GetDueSoonAssignments(now, now.Add(dueSoonWindow))
+	if e != nil {
+		s.log.Error("Failed to get assignments due soon", "err", e)
+		return
+	}
+	for i := range cas {
+		ca := &cas[i]
+		ca.Reminded = true
+		if e := s.repo.UpdateAssignmentState(ca); e != nil {
+			s.log.Error("Failed to update assignment due soon", "chore", ca.Chore.ID, "err", e)
+			continue
+		}
+		s.events.Publish(Event{
+			Type:       EventDueSoon,
+			Group:      ca.Chore.Group,
+			Chore:      ca.Chore,
+			Recipients: []*User{ca.User},
+			Message: fmt.Sprintf("%s in %s is due %s", ca.Chore.Name, ca.Chore.Group.Name,
+				localDue(ca)),
+		})
+	}
+}
+
+// localDue formats the due date of an assignment for a message in the group's time zone
+func localDue(ca *ChoreAssignment) string {
+	return ca.DateDue.In(ca.Chore.Group.Location()).Format("Jan 2 15:04")
+}
+
+// admins returns the members of the group that can edit its chores
+func (s *overdueService) admins(g *Group) []*User {
+	users := make([]*User, 0)
+	if e := s.gs.GetMemberships(g); e != nil {
+		s.log.Error("Failed to get group members", "group", g.ID, "err", e)
+		return users
+	}
+	for i := range g.Memberships {
+		if e := s.gs.GetRoles(&g.Memberships[i]); e != nil {
+			s.log.Error("Failed to get member roles", "group", g.ID, "err", e)
+			continue
+		}
+		if g.Memberships[i].SuperRole.Can(EditChores) {
+			users = append(users, g.Memberships[i].User)
+		}
+	}
+	return users
+}
+
+func hours(h int) time.Duration {
+	return time.Duration(h) * time.Hour
+}
@@ -1,9 +1,7 @@
 package core
 
 import (
-	"errors"
 	"fmt"
-	"log"
 )
 
 type RoleRepository interface {
@@ -20,42 +18,66 @@ type RoleService interface {
 	AddMember(role *Role, username string, user *User) error
 	GetRole(role *Role) error
 	Update(role *Role, newRole *Role, user *User) error
-	Delete(role *Role) error
+	Delete(role *Role, user *User) error
 }
 
 type roleService struct {
-	repo RoleRepository
-	us   UserService
+	repo   RoleRepository
+	us     UserService
+	audit  AuditService
+	events EventSink
 }
 
-func NewRoleService(re RoleRepository, u UserService) RoleService {
+func NewRoleService(re RoleRepository, u UserService, a AuditService, ev EventSink) RoleService {
 	return &roleService{
-		repo: re,
-		us:   u,
+		repo:   re,
+		us:     u,
+		audit:  a,
+		events: ev,
 	}
 }
 
 func (s *roleService) RemoveMember(role *Role, userID uint64, user *User) error {
 	if role.Name == "Owner" {
-		return errors.New("Cannot remove owner")
+		return forbidden("Cannot remove owner")
 	}
 	if e := s.repo.RemoveMember(role.ID, userID); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("RoleService.RemoveMember", e)
 	}
+	target := fmt.Sprintf("user %d", userID)
+	if mem := role.Group.FindMember(userID); mem != nil {
+		target = mem.User.Username
+	}
+	s.audit.Record(role.Group, user, ActionRoleUnassign, target, role.Name, "")
+	s.events.Publish(Event{
+		Type:       EventRoleChanged,
+		Group:      role.Group,
+		Actor:      user,
+		Recipients: []*User{{ID: userID}},
+		Message:    fmt.Sprintf("You were removed from the %s role in %s", role.Name, role.Group.Name),
+	})
 	return nil
 }
 
 func (s *roleService) AddMember(role *Role, username string, user *User) error {
 	if role.Name == "Owner" {
-		return errors.New("There can only be one owner")
+		return conflict("There can only be one owner")
 	}
 	mem := role.Group.FindMember(username)
 	if mem == nil {
-		return errors.New("Member not found")
+		return notFound("Member not found")
 	}
 	if e := s.repo.AddMember(role.ID, mem.User.ID); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("RoleService.AddMember", e)
 	}
+	s.audit.Record(role.Group, user, ActionRoleAssign, mem.User.Username, "", role.Name)
+	s.events.Publish(Event{
+		Type:       EventRoleChanged,
+		Group:      role.Group,
+		Actor:      user,
+		Recipients: []*User{mem.User},
+		Message:    fmt.Sprintf("You were given the %s role in %s", role.Name, role.Group.Name),
+	})
 	return nil
 }
 
@@ -65,33 +87,33 @@ func (s *roleService) GetRole(role *Role) error {
 
 func (s *roleService) Update(role *Role, newRole *Role, user *User) error {
 	if role.Name == "Owner" || role.Name == "Admin" || role.Name == "Default" {
-		return errors.New("Cannot make changes to Owner, Admin, or Default roles")
+		return forbidden("Cannot make changes to Owner, Admin, or Default roles")
 	}
 	if e := s.repo.GetRoles(role.Group); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("RoleService.Update", e)
 	}
 	if role.Name != newRole.Name {
 		for i := range role.Group.Roles {
 			if newRole.Name == role.Group.Roles[i].Name {
-				return errors.New("Role name already exists")
+				return conflict("Role name already exists")
 			}
 		}
 	}
 	if e := s.repo.UpdateRole(newRole); e != nil {
-		return errors.New("An unexpected error occurred")
+		return internal("RoleService.Update", e)
 	}
+	s.audit.Record(role.Group, user, ActionRoleUpdate, role.Name, describeRole(role), describeRole(newRole))
 	return nil
 
 }
 
-func (s *roleService) Delete(role *Role) error {
+func (s *roleService) Delete(role *Role, user *User) error {
 	if role.Name == "Owner" || role.Name == "Admin" || role.Name == "Default" {
-		msg := fmt.Sprintf("Cannot delete %s role", role.Name)
-		return errors.New(msg)
+		return forbidden(fmt.Sprintf("Cannot delete %s role", role.Name))
 	}
 	if e := s.repo.DeleteRole(role); e != nil {
-		log.Printf("Core: RoleService: Delete: %s", e.Error())
-		return errors.New("An unexpected error occurred")
+		return internal("RoleService.Delete", e)
 	}
+	s.audit.Record(role.Group, user, ActionRoleDelete, role.Name, describeRole(role), "")
 	return nil
 }
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+type ScoreRepository interface {
+	GetHistory(t interface{}) error
+}
+
+type ScoreService interface {
+	// Leaderboard returns the standings of every member of the group over the period, ordered from
+	// the most points to the least. The group's memberships must already be loaded.
+	Leaderboard(g *Group, p Period) ([]Standing, error)
+}
+
+type Period string
+
+const (
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+	PeriodAll   Period = "all"
+)
+
+// Periods lists the periods a leaderboard can be viewed over
+var Periods = []Period{PeriodWeek, PeriodMonth, PeriodYear, PeriodAll}
+
+// Text returns a description of the period for display
+func (p Period) Text() string {
+	switch p {
+	case PeriodWeek:
+		return "Past week"
+	case PeriodMonth:
+		return "Past month"
+	case PeriodYear:
+		return "Past year"
+	}
+	return "All time"
+}
+
+// Since returns the start of the period ending at now. The zero time is returned for PeriodAll
+// and unknown periods.
+func (p Period) Since(now time.Time) time.Time {
+	switch p {
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7)
+	case PeriodMonth:
+		return now.AddDate(0, -1, 0)
+	case PeriodYear:
+		return now.AddDate(-1, 0, 0)
+	}
+	return time.Time{}
+}
+
+type scoreService struct {
+	repo ScoreRepository
+}
+
+func NewScoreService(r ScoreRepository) ScoreService {
+	return &scoreService{
+		repo: r,
+	}
+}
+
+func (s *scoreService) Leaderboard(g *Group, p Period) ([]Standing, error) {
+	if e := s.repo.GetHistory(g); e != nil {
+		return nil, internal("ScoreService.Leaderboard", e)
+	}
+	return standings(g.Memberships, g.History, p.Since(time.Now().UTC())), nil
+}
+
+// scoreCompletion builds the history entry for an assignment completed at the given time. Chores
+// completed by their due date earn their worth, late chores cost half of it.
+func scoreCompletion(ca *ChoreAssignment, at time.Time) HistoryEntry {
+	h := HistoryEntry{
+		Group:        ca.Chore.Group,
+		Chore:        ca.Chore,
+		User:         ca.User,
+		DateAssigned: ca.DateAssigned,
+		DateDue:      ca.DateDue,
+		DateComplete: at,
+		OnTime:       ca.DateDue.IsZero() || !at.After(ca.DateDue),
+	}
+	if h.OnTime {
+		h.Points = ca.Chore.Worth()
+	} else {
+		h.Points = -(ca.Chore.Worth() + 1) / 2
+	}
+	return h
+}
+
+// scoreMissed builds the history entry for an assignment that was never completed. Missed chores
+// cost their full worth.
+func scoreMissed(ca *ChoreAssignment) HistoryEntry {
+	return HistoryEntry{
+		Group:        ca.Chore.Group,
+		Chore:        ca.Chore,
+		User:         ca.User,
+		DateAssigned: ca.DateAssigned,
+		DateDue:      ca.DateDue,
+		Points:       -ca.Chore.Worth(),
+		Missed:       true,
+	}
+}
+
+// missed returns history entries for the assignments that are incomplete and past due
+func missed(ca []ChoreAssignment, now time.Time) []HistoryEntry {
+	entries := make([]HistoryEntry, 0)
+	for i := range ca {
+		if ca[i].Complete || ca[i].DateDue.IsZero() || !now.After(ca[i].DateDue) {
+			continue
+		}
+		entries = append(entries, scoreMissed(&ca[i]))
+	}
+	return entries
+}
+
+// When returns the time an entry counts towards, its completion or, if it was missed, its due date
+func (h *HistoryEntry) When() time.Time {
+	if h.Missed {
+		return h.DateDue
+	}
+	return h.DateComplete
+}
+
+// standings totals the history of each member. Points and counts only include entries after
+// since, streaks are calculated over the whole history.
+func standings(m []Membership, history []HistoryEntry, since time.Time) []Standing {
+	sorted := make([]HistoryEntry, len(history))
+	copy(sorted, history)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].When().Before(sorted[b].When())
+	})
+
+	table := make([]Standing, len(m))
+	index := make(map[uint64]int)
+	for i := range m {
+		table[i].User = m[i].User
+		index[m[i].User.ID] = i
+	}
+	for _, h := range sorted {
+		i, ok := index[h.User.ID]
+		if !ok {
+			continue
+		}
+		st := &table[i]
+		if h.OnTime && !h.Missed {
+			st.Streak++
+			if st.Streak > st.BestStreak {
+				st.BestStreak = st.Streak
+			}
+		} else {
+			st.Streak = 0
+		}
+		if h.When().Before(since) {
+			continue
+		}
+		st.Points += h.Points
+		switch {
+		case h.Missed:
+			st.Missed++
+		case h.OnTime:
+			st.Completed++
+			st.OnTime++
+		default:
+			st.Completed++
+		}
+	}
+	sort.SliceStable(table, func(a, b int) bool {
+		return table[a].Points > table[b].Points
+	})
+	return table
+}
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScoreCompletion(t *testing.T) {
+	tests := []struct {
+		name   string
+		chore  Chore
+		due    time.Time
+		at     time.Time
+		points int
+		onTime bool
+	}{
+		{"on time", Chore{Duration: 20}, due, due.Add(-time.Hour), 4, true},
+		{"right at the due date", Chore{Duration: 20}, due, due, 4, true},
+		{"late costs half", Chore{Duration: 20}, due, due.Add(time.Minute), -2, false},
+		{"late rounds the cost up", Chore{Duration: 25}, due, due.Add(time.Hour), -3, false},
+		{"no due date", Chore{Duration: 20}, time.Time{}, due, 4, true},
+		{"points override the duration", Chore{Duration: 20, Points: 7}, due, due.Add(-time.Hour), 7, true},
+		{"short chores are worth a point", Chore{Duration: 2}, due, due.Add(-time.Hour), 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca := &ChoreAssignment{Chore: &tt.chore, User: &User{ID: 1}, DateDue: tt.due}
+			h := scoreCompletion(ca, tt.at)
+			if h.Points != tt.points || h.OnTime != tt.onTime || h.Missed {
+				t.Errorf("got %d points, on time %t, missed %t, want %d points, on time %t",
+					h.Points, h.OnTime, h.Missed, tt.points, tt.onTime)
+			}
+			if !h.DateComplete.Equal(tt.at) {
+				t.Errorf("completed at %s, want %s", h.DateComplete, tt.at)
+			}
+		})
+	}
+}
+
+func TestMissed(t *testing.T) {
+	c := &Chore{Duration: 20}
+	u := &User{ID: 1}
+	ca := []ChoreAssignment{
+		{Chore: c, User: u, DateDue: due.Add(-time.Hour)},
+		{Chore: c, User: u, DateDue: due.Add(-time.Hour), Complete: true},
+		{Chore: c, User: u, DateDue: due},
+		{Chore: c, User: u, DateDue: due.Add(time.Hour)},
+		{Chore: c, User: u},
+	}
+	misses := missed(ca, due)
+	if len(misses) != 1 {
+		t.Fatalf("%d misses, want 1", len(misses))
+	}
+	if h := misses[0]; !h.Missed || h.Points != -4 || !h.When().Equal(due.Add(-time.Hour)) {
+		t.Errorf("unexpected miss %+v", h)
+	}
+}
+
+func TestPeriodSince(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period Period
+		want   time.Time
+	}{
+		{PeriodWeek, time.Date(2024, 3, 24, 12, 0, 0, 0, time.UTC)},
+		// March 31st less a month normalizes to March 2nd
+		{PeriodMonth, time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{PeriodYear, time.Date(2023, 3, 31, 12, 0, 0, 0, time.UTC)},
+		{PeriodAll, time.Time{}},
+		{Period("decade"), time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := tt.period.Since(now); !got.Equal(tt.want) {
+			t.Errorf("%s.Since = %s, want %s", tt.period, got, tt.want)
+		}
+	}
+}
+
+// history returns a fixture of three members' history up to now. Dishes are worth 4 points and
+// the trash 10.
+func history(now time.Time) []HistoryEntry {
+	dishes := &Chore{ID: 1, Name: "dishes", Duration: 20}
+	trash := &Chore{ID: 2, Name: "trash", Duration: 5, Points: 10}
+	days := func(n int) time.Time { return now.Add(time.Duration(-n) * 24 * time.Hour) }
+	done := func(u uint64, c *Chore, at time.Time, points int, onTime bool) HistoryEntry {
+		return HistoryEntry{Chore: c, User: &User{ID: u}, DateDue: at, DateComplete: at, Points: points, OnTime: onTime}
+	}
+	miss := func(u uint64, c *Chore, due time.Time) HistoryEntry {
+		return HistoryEntry{Chore: c, User: &User{ID: u}, DateDue: due, Points: -c.Worth(), Missed: true}
+	}
+	// Stored newest first to check the entries are put in order before streaks are counted
+	return []HistoryEntry{
+		done(2, trash, days(1), 10, true),
+		done(1, dishes, days(2), -2, false),
+		miss(2, dishes, days(1).Add(-6*time.Hour)),
+		done(1, trash, days(3), 10, true),
+		miss(3, dishes, days(10)),
+		done(9, trash, days(3), 10, true),
+		done(1, dishes, days(40), 4, true),
+		done(2, trash, days(60), 10, true),
+	}
+}
+
+func TestStandings(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	st := func(u uint64, points, completed, onTime, missed, streak, best int) Standing {
+		return Standing{User: &User{ID: u}, Points: points, Completed: completed, OnTime: onTime,
+			Missed: missed, Streak: streak, BestStreak: best}
+	}
+	tests := []struct {
+		period Period
+		want   []Standing
+	}{
+		{PeriodAll, []Standing{st(2, 16, 2, 2, 1, 1, 1), st(1, 12, 3, 2, 0, 0, 2), st(3, -4, 0, 0, 1, 0, 0)}},
+		{PeriodYear, []Standing{st(2, 16, 2, 2, 1, 1, 1), st(1, 12, 3, 2, 0, 0, 2), st(3, -4, 0, 0, 1, 0, 0)}},
+		{PeriodMonth, []Standing{st(1, 8, 2, 1, 0, 0, 2), st(2, 6, 1, 1, 1, 1, 1), st(3, -4, 0, 0, 1, 0, 0)}},
+		// Streaks still count the history from before the period
+		{PeriodWeek, []Standing{st(1, 8, 2, 1, 0, 0, 2), st(2, 6, 1, 1, 1, 1, 1), st(3, 0, 0, 0, 0, 0, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.period), func(t *testing.T) {
+			got := standings(members(3), history(now), tt.period.Since(now))
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d standings, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.User.ID != w.User.ID {
+					t.Fatalf("place %d is user %d, want %d", i+1, g.User.ID, w.User.ID)
+				}
+				g.User, w.User = nil, nil
+				if g != w {
+					t.Errorf("user %d: got %+v, want %+v", got[i].User.ID, g, w)
+				}
+			}
+		})
+	}
+}
+
+// historyRepo is a ScoreRepository that loads a fixed history
+type historyRepo struct {
+	history []HistoryEntry
+	e       error
+}
+
+func (r *historyRepo) GetHistory(t interface{}) error {
+	t.(*Group).History = r.history
+	return r.e
+}
+
+func TestLeaderboard(t *testing.T) {
+	s := NewScoreService(&historyRepo{history: history(time.Now().UTC())})
+	g := &Group{Memberships: members(3)}
+	week, e := s.Leaderboard(g, PeriodWeek)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if week[0].User.ID != 1 || week[0].Points != 8 {
+		t.Errorf("week leader is user %d with %d points, want user 1 with 8", week[0].User.ID, week[0].Points)
+	}
+	all, e := s.Leaderboard(g, PeriodAll)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if all[0].User.ID != 2 || all[0].Points != 16 {
+		t.Errorf("all time leader is user %d with %d points, want user 2 with 16", all[0].User.ID, all[0].Points)
+	}
+
+	s = NewScoreService(&historyRepo{e: errors.New("connection refused")})
+	var ie *InternalError
+	if _, e := s.Leaderboard(g, PeriodAll); !errors.As(e, &ie) {
+		t.Errorf("error = %v, want an InternalError", e)
+	}
+}
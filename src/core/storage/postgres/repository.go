@@ -6,12 +6,11 @@ import (
 	"strconv"
 	"strings"
 
-	_ "github.com/lib/pq" //Required for compilation. Functionality is wrapped using database/sql
+	"github.com/lib/pq"
 
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
-	"os"
 	"time"
 )
 
@@ -29,31 +28,34 @@ type Storage struct {
 	Db *sql.DB
 }
 
-// NewStorage creates and returns a new storage object
-func NewStorage() *Storage {
-	connString := os.Getenv("POSTGRES_CONN")
-	db, err := sql.Open("postgres", connString)
+// NewStorage creates and returns a new storage object connected to the database. The time taken
+// by every statement is recorded in the metrics.
+func NewStorage(connString string) (*Storage, error) {
+	connector, err := pq.NewConnector(connString)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	db := sql.OpenDB(timedConnector{connector})
 	s := &Storage{
 		Db: db,
 	}
-	if err != nil {
-		log.Fatal(fmt.Sprintf("Storage: %s", err))
-	}
 
 	err = db.Ping()
 	if err != nil {
-		log.Fatal(fmt.Sprintf("Storage: %s", err))
+		db.Close()
+		return nil, fmt.Errorf("storage: %w", err)
 	}
-	return s
+	return s, nil
 }
 
 // GetUserByName fetches a user from the database by unique username
 func (s *Storage) GetUserByName(user *core.User) error {
 	query := `
-	SELECT users.id, users.email, users.pword, users.created_at 
+	SELECT users.id, users.email, users.pword, users.created_at, users.locale, users.time_zone 
 	FROM users 
 	WHERE users.uname = $1`
-	err := s.Db.QueryRow(query, user.Username).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt)
+	err := s.Db.QueryRow(query, user.Username).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt,
+		&user.Locale, &user.TimeZone)
 	if err == sql.ErrNoRows {
 		return errors.ErrNotFound
 	}
@@ -74,7 +76,7 @@ func (s *Storage) GetUserByEmail(user *core.User) error {
 
 // GetUserByID fetches a user from the database by unique ID
 func (s *Storage) GetUserByID(user *core.User) error {
-	err := s.Db.QueryRow("SELECT uname, email, pword, created_at FROM users WHERE id = $1", user.ID).Scan(&user.Username, &user.Email, &user.Password, &user.CreatedAt)
+	err := s.Db.QueryRow("SELECT uname, email, pword, created_at, locale, time_zone FROM users WHERE id = $1", user.ID).Scan(&user.Username, &user.Email, &user.Password, &user.CreatedAt, &user.Locale, &user.TimeZone)
 	return err
 }
 
@@ -89,6 +91,31 @@ func (s *Storage) CreateUser(user *core.User) error {
 	return nil
 }
 
+// UpdatePassword replaces the password hash of the user
+func (s *Storage) UpdatePassword(user *core.User) error {
+	res, e := s.Db.Exec(`UPDATE users SET pword = $1 WHERE id = $2`, user.Password, user.ID)
+	if e != nil {
+		return e
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+// UpdatePreferences saves the language and time zone the user chose
+func (s *Storage) UpdatePreferences(user *core.User) error {
+	res, e := s.Db.Exec(`UPDATE users SET (locale, time_zone) = ($1, $2) WHERE id = $3`, user.Locale,
+		user.TimeZone, user.ID)
+	if e != nil {
+		return e
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
 func (s *Storage) GetChores(t interface{}) error {
 	switch v := t.(type) {
 	case *core.User:
@@ -102,8 +129,8 @@ func (s *Storage) GetChores(t interface{}) error {
 
 func (s *Storage) GetUserChores(user *core.User) error {
 	query := `
-	SELECT ca.complete, ca.date_assigned, ca.date_complete, ca.date_due,
-	c.id, c.name, c.description, c.duration, g.id, g.name
+	SELECT ca.complete, ca.date_assigned, ca.date_complete, ca.date_due, ca.overdue,
+	c.id, c.name, c.description, c.duration, c.points, c.recurrence, g.id, g.name
 	FROM chore_assignments ca
 	INNER JOIN chores c ON c.id = ca.chore_id
 	INNER JOIN groups g ON g.id = c.group_id
@@ -120,8 +147,8 @@ func (s *Storage) GetUserChores(user *core.User) error {
 		c := core.Chore{}
 		g := core.Group{}
 
-		err = rows.Scan(&ca.Complete, &ca.DateAssigned, &ca.DateComplete, &ca.DateDue,
-			&c.ID, &c.Name, &c.Description, &c.Duration, &g.ID, &g.Name)
+		err = rows.Scan(&ca.Complete, &ca.DateAssigned, &ca.DateComplete, &ca.DateDue, &ca.Overdue,
+			&c.ID, &c.Name, &c.Description, &c.Duration, &c.Points, &c.Recurrence, &g.ID, &g.Name)
 
 		if err != nil && err != sql.ErrNoRows {
 			return err
@@ -137,9 +164,9 @@ func (s *Storage) GetUserChores(user *core.User) error {
 
 func (s *Storage) GetGroupChores(group *core.Group) error {
 	query := `
-	SELECT c.id, c.name, c.description, c.duration,
-	ca.complete, ca.date_assigned, ca.date_complete, ca.date_due, ca.user_id,
-	u.uname
+	SELECT c.id, c.name, c.description, c.duration, c.points, c.recurrence,
+	ca.complete, ca.date_assigned, ca.date_complete, ca.date_due, ca.overdue, ca.escalation,
+	ca.user_id, u.uname
 	FROM chores c
 	LEFT JOIN chore_assignments ca ON ca.chore_id = c.id 
 	LEFT JOIN users u ON u.id = ca.user_id
@@ -151,11 +178,13 @@ func (s *Storage) GetGroupChores(group *core.Group) error {
 		var dateAssigned sql.NullTime
 		var dateComplete sql.NullTime
 		var dateDue sql.NullTime
+		var overdue sql.NullBool
+		var escalation sql.NullInt64
 		var userID sql.NullInt64
 		var userName sql.NullString
 		ch := core.Chore{Group: group}
-		if e := rows.Scan(&ch.ID, &ch.Name, &ch.Description, &ch.Duration,
-			&complete, &dateAssigned, &dateComplete, &dateDue, &userID, &userName); e != nil {
+		if e := rows.Scan(&ch.ID, &ch.Name, &ch.Description, &ch.Duration, &ch.Points, &ch.Recurrence,
+			&complete, &dateAssigned, &dateComplete, &dateDue, &overdue, &escalation, &userID, &userName); e != nil {
 			if e == sql.ErrNoRows {
 				return nil
 			}
@@ -168,6 +197,8 @@ func (s *Storage) GetGroupChores(group *core.Group) error {
 			ca.DateAssigned = dateAssigned.Time
 			ca.DateComplete = dateComplete.Time
 			ca.DateDue = dateDue.Time
+			ca.Overdue = overdue.Bool
+			ca.Escalation = int(escalation.Int64)
 			u.ID = uint64(userID.Int64)
 			u.Username = userName.String
 			ca.Chore = &ch
@@ -181,48 +212,62 @@ func (s *Storage) GetGroupChores(group *core.Group) error {
 
 func (s *Storage) CreateChore(chore *core.Chore) error {
 	query := `
-	INSERT INTO chores (name, description, duration, group_id)
-	VALUES($1,$2,$3,$4) RETURNING id`
-	_, e := s.Db.Exec(query, chore.Name, chore.Description, chore.Duration, chore.Group.ID)
-	return e
+	INSERT INTO chores (name, description, duration, points, recurrence, group_id)
+	VALUES($1,$2,$3,$4,$5,$6) RETURNING id`
+	return s.Db.QueryRow(query, chore.Name, chore.Description, chore.Duration, chore.Points, chore.Recurrence,
+		chore.Group.ID).Scan(&chore.ID)
 }
 
 func (s *Storage) GetChore(ch *core.Chore) error {
 	query := `
-	SELECT name, description, duration, group_id
+	SELECT name, description, duration, points, recurrence, group_id
 	FROM chores WHERE id = $1`
 	ch.Group = &core.Group{}
-	return s.Db.QueryRow(query, ch.ID).Scan(&ch.Name, &ch.Description, &ch.Duration, &ch.Group.ID)
+	e := s.Db.QueryRow(query, ch.ID).Scan(&ch.Name, &ch.Description, &ch.Duration, &ch.Points, &ch.Recurrence,
+		&ch.Group.ID)
+	if e == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	return e
 }
 
 func (s *Storage) UpdateChore(ch *core.Chore) error {
 	query := `
-	UPDATE chores SET (name, description, duration) = ($1, $2, $3)
-	WHERE id = $4`
-	_, e := s.Db.Exec(query, ch.Name, ch.Description, ch.Duration, ch.ID)
+	UPDATE chores SET (name, description, duration, points, recurrence) = ($1, $2, $3, $4, $5)
+	WHERE id = $6`
+	_, e := s.Db.Exec(query, ch.Name, ch.Description, ch.Duration, ch.Points, ch.Recurrence, ch.ID)
 	return e
 }
 
 func (s *Storage) InsertAssignments(ca []core.ChoreAssignment) error {
-	args := make([]interface{}, 0, len(ca)*6)
+	if len(ca) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(ca)*8)
 	argStr := make([]string, 0, len(ca))
 	for i := range ca {
-		argStr = append(argStr, fmt.Sprintf("($%v,$%v,$%v,$%v,$%v,$%v)", i*6+1, i*6+2, i*6+3, i*6+4, i*6+5, i*6+6))
+		argStr = append(argStr, fmt.Sprintf("($%v,$%v,$%v,$%v,$%v,$%v,$%v,$%v)",
+			i*8+1, i*8+2, i*8+3, i*8+4, i*8+5, i*8+6, i*8+7, i*8+8))
 		args = append(args, ca[i].Complete)
 		args = append(args, ca[i].DateAssigned)
 		args = append(args, ca[i].DateComplete)
 		args = append(args, ca[i].DateDue)
+		args = append(args, ca[i].Overdue)
+		args = append(args, ca[i].Escalation)
 		args = append(args, ca[i].Chore.ID)
 		args = append(args, ca[i].User.ID)
 	}
 	query := fmt.Sprintf(`INSERT INTO chore_assignments
-	(complete, date_assigned, date_complete, date_due, chore_id, user_id)
+	(complete, date_assigned, date_complete, date_due, overdue, escalation, chore_id, user_id)
 	VALUES %s`, strings.Join(argStr, ","))
 	_, e := s.Db.Exec(query, args...)
 	return e
 }
 
 func (s *Storage) DeleteAssignments(ca []core.ChoreAssignment) error {
+	if len(ca) == 0 {
+		return nil
+	}
 	cids := make([]string, 0, len(ca))
 	for i := range ca {
 		cids = append(cids, strconv.FormatUint(ca[i].Chore.ID, 10))
@@ -232,6 +277,111 @@ func (s *Storage) DeleteAssignments(ca []core.ChoreAssignment) error {
 	return e
 }
 
+func (s *Storage) GetConstraints(t interface{}) error {
+	switch v := t.(type) {
+	case *core.Chore:
+		return s.GetChoreConstraints(v)
+	case *core.Group:
+		return s.GetGroupConstraints(v)
+	default:
+		return errors.ErrType
+	}
+}
+
+func (s *Storage) GetChoreConstraints(ch *core.Chore) error {
+	query := `
+	SELECT cc.id, cc.kind, cc.user_id, u.uname, cc.role_id, r.name
+	FROM chore_constraints cc
+	LEFT JOIN users u ON u.id = cc.user_id
+	LEFT JOIN roles r ON r.id = cc.role_id
+	WHERE cc.chore_id = $1`
+	rows, e := s.Db.Query(query, ch.ID)
+	if e != nil {
+		return e
+	}
+	ch.Constraints = []core.ChoreConstraint{}
+	defer rows.Close()
+	for rows.Next() {
+		con, e := scanConstraint(rows)
+		if e != nil {
+			return e
+		}
+		con.Chore = ch
+		ch.Constraints = append(ch.Constraints, con)
+	}
+	return nil
+}
+
+func (s *Storage) GetGroupConstraints(group *core.Group) error {
+	query := `
+	SELECT cc.id, cc.kind, cc.user_id, u.uname, cc.role_id, r.name, cc.chore_id
+	FROM chore_constraints cc
+	INNER JOIN chores c ON c.id = cc.chore_id
+	LEFT JOIN users u ON u.id = cc.user_id
+	LEFT JOIN roles r ON r.id = cc.role_id
+	WHERE c.group_id = $1`
+	rows, e := s.Db.Query(query, group.ID)
+	if e != nil {
+		return e
+	}
+	for i := range group.Chores {
+		group.Chores[i].Constraints = []core.ChoreConstraint{}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var choreID uint64
+		con, e := scanConstraint(rows, &choreID)
+		if e != nil {
+			return e
+		}
+		if ch := group.FindChore(choreID); ch != nil {
+			con.Chore = ch
+			ch.Constraints = append(ch.Constraints, con)
+		}
+	}
+	return nil
+}
+
+func scanConstraint(rows *sql.Rows, extra ...interface{}) (core.ChoreConstraint, error) {
+	var userID sql.NullInt64
+	var userName sql.NullString
+	var roleID sql.NullInt64
+	var roleName sql.NullString
+	con := core.ChoreConstraint{}
+	dest := append([]interface{}{&con.ID, &con.Kind, &userID, &userName, &roleID, &roleName}, extra...)
+	if e := rows.Scan(dest...); e != nil {
+		return con, e
+	}
+	if userID.Valid {
+		con.User = &core.User{ID: uint64(userID.Int64), Username: userName.String}
+	}
+	if roleID.Valid {
+		con.Role = &core.Role{ID: uint64(roleID.Int64), Name: roleName.String}
+	}
+	return con, nil
+}
+
+func (s *Storage) CreateConstraint(con *core.ChoreConstraint) error {
+	var userID sql.NullInt64
+	var roleID sql.NullInt64
+	if con.User != nil {
+		userID = sql.NullInt64{Int64: int64(con.User.ID), Valid: true}
+	}
+	if con.Role != nil {
+		roleID = sql.NullInt64{Int64: int64(con.Role.ID), Valid: true}
+	}
+	query := `
+	INSERT INTO chore_constraints (chore_id, kind, user_id, role_id)
+	VALUES ($1,$2,$3,$4) RETURNING id`
+	return s.Db.QueryRow(query, con.Chore.ID, con.Kind, userID, roleID).Scan(&con.ID)
+}
+
+func (s *Storage) DeleteConstraint(con *core.ChoreConstraint) error {
+	query := `DELETE FROM chore_constraints WHERE id = $1`
+	_, e := s.Db.Exec(query, con.ID)
+	return e
+}
+
 func (s *Storage) DeleteChore(ch *core.Chore) error {
 	query := `DELETE FROM chores WHERE id = $1`
 	_, e := s.Db.Exec(query, ch.ID)
@@ -240,11 +390,35 @@ func (s *Storage) DeleteChore(ch *core.Chore) error {
 
 func (s *Storage) GetGroupByID(group *core.Group) error {
 	query := `
-	SELECT name FROM groups WHERE id = $1`
-	e := s.Db.QueryRow(query, group.ID).Scan(&group.Name)
+	SELECT name, missed_policy, remind_after, escalate_after, time_zone FROM groups WHERE id = $1`
+	e := s.Db.QueryRow(query, group.ID).Scan(&group.Name, &group.Settings.MissedPolicy,
+		&group.Settings.RemindAfter, &group.Settings.EscalateAfter, &group.Settings.TimeZone)
+	if e == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
 	return e
 }
 
+// GetGroups fetches every group ordered by id
+func (s *Storage) GetGroups() ([]core.Group, error) {
+	rows, e := s.Db.Query(`SELECT id, name, missed_policy, remind_after, escalate_after, time_zone FROM groups
+	ORDER BY id`)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+	groups := make([]core.Group, 0)
+	for rows.Next() {
+		g := core.Group{}
+		if e := rows.Scan(&g.ID, &g.Name, &g.Settings.MissedPolicy, &g.Settings.RemindAfter,
+			&g.Settings.EscalateAfter, &g.Settings.TimeZone); e != nil {
+			return nil, e
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
 func (s *Storage) GetMembership(mem *core.Membership) error {
 	query := `SELECT joined_at FROM memberships WHERE group_id = $1 AND user_id = $2`
 	e := s.Db.QueryRow(query, mem.Group.ID, mem.User.ID).Scan(&mem.JoinedAt)
@@ -299,7 +473,7 @@ func (s *Storage) GetUserMemberships(user *core.User) error {
 
 func (s *Storage) GetGroupMemberships(group *core.Group) error {
 	query := `
-	SELECT m.joined_at, m.user_id, u.uname
+	SELECT m.joined_at, m.debt, m.user_id, u.uname
 	FROM memberships m
 	INNER JOIN users u ON m.user_id = u.id
 	WHERE m.group_id = $1`
@@ -312,7 +486,7 @@ func (s *Storage) GetGroupMemberships(group *core.Group) error {
 	defer rows.Close()
 	for rows.Next() {
 		mem := core.Membership{Group: group, User: &core.User{}}
-		err = rows.Scan(&mem.JoinedAt, &mem.User.ID, &mem.User.Username)
+		err = rows.Scan(&mem.JoinedAt, &mem.Debt, &mem.User.ID, &mem.User.Username)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil
@@ -340,7 +514,6 @@ func (s *Storage) GetRoleMemberships(role *core.Role) error {
 		mem := core.Membership{User: &core.User{}, Group: role.Group}
 		e = rows.Scan(&mem.JoinedAt, &mem.User.ID, &mem.User.Username)
 		if e != nil {
-			log.Print(e.Error())
 			return e
 		}
 		role.Members = append(role.Members, mem)
@@ -349,14 +522,54 @@ func (s *Storage) GetRoleMemberships(role *core.Role) error {
 }
 
 func (s *Storage) CreateGroup(group *core.Group) error {
-	query := `INSERT INTO groups (name) VALUES ($1) RETURNING id`
-	e := s.Db.QueryRow(query, &group.Name).Scan(&group.ID)
-	return e
+	tx, e := s.Db.Begin()
+	if e != nil {
+		return e
+	}
+	// Rolling back after a successful commit does nothing
+	defer tx.Rollback()
+	query := `INSERT INTO groups (name, time_zone) VALUES ($1, $2) RETURNING id`
+	if e := tx.QueryRow(query, &group.Name, group.Settings.TimeZone).Scan(&group.ID); e != nil {
+		return e
+	}
+	memberQuery := `INSERT INTO memberships (joined_at, user_id, group_id) VALUES ($1,$2,$3)`
+	for _, m := range group.Memberships {
+		if _, e := tx.Exec(memberQuery, m.JoinedAt, m.User.ID, group.ID); e != nil {
+			return e
+		}
+	}
+	roleQuery := `INSERT INTO roles (name, permissions, group_id, gets_chores) VALUES ($1,$2,$3,$4) RETURNING id`
+	assignQuery := `INSERT INTO role_assignments (role_id, user_id) VALUES ($1,$2)`
+	for i := range group.Roles {
+		r := &group.Roles[i]
+		if e := tx.QueryRow(roleQuery, r.Name, r.Permissions, group.ID, r.GetsChores).Scan(&r.ID); e != nil {
+			return e
+		}
+		for _, m := range r.Members {
+			if _, e := tx.Exec(assignQuery, r.ID, m.User.ID); e != nil {
+				return e
+			}
+		}
+	}
+	choreQuery := `
+	INSERT INTO chores (name, description, duration, points, recurrence, group_id)
+	VALUES($1,$2,$3,$4,$5,$6) RETURNING id`
+	for i := range group.Chores {
+		c := &group.Chores[i]
+		if e := tx.QueryRow(choreQuery, c.Name, c.Description, c.Duration, c.Points, c.Recurrence,
+			group.ID).Scan(&c.ID); e != nil {
+			return e
+		}
+	}
+	return tx.Commit()
 }
 
 func (s *Storage) UpdateGroup(group *core.Group) error {
-	query := `UPDATE groups SET name = $1 WHERE id = $2`
-	_, e := s.Db.Exec(query, group.Name, group.ID)
+	query := `
+	UPDATE groups SET (name, missed_policy, remind_after, escalate_after, time_zone) = ($1, $2, $3, $4, $5)
+	WHERE id = $6`
+	_, e := s.Db.Exec(query, group.Name, group.Settings.MissedPolicy, group.Settings.RemindAfter,
+		group.Settings.EscalateAfter, group.Settings.TimeZone, group.ID)
 	return e
 }
 
@@ -378,6 +591,88 @@ func (s *Storage) CreateMembership(mem *core.Membership) error {
 	return e
 }
 
+func (s *Storage) UpdateDebt(mem *core.Membership) error {
+	query := `UPDATE memberships SET debt = $1 WHERE group_id = $2 AND user_id = $3`
+	_, e := s.Db.Exec(query, mem.Debt, mem.Group.ID, mem.User.ID)
+	return e
+}
+
+func (s *Storage) GetAbsences(t interface{}) error {
+	switch v := t.(type) {
+	case *core.Group:
+		return s.GetGroupAbsences(v)
+	case *core.Membership:
+		return s.GetMemberAbsences(v)
+	default:
+		return errors.ErrType
+	}
+}
+
+// GetGroupAbsences fills in the current and upcoming absences of each member of the group
+func (s *Storage) GetGroupAbsences(group *core.Group) error {
+	query := `
+	SELECT id, user_id, start_at, end_at, compensate
+	FROM absences
+	WHERE group_id = $1 AND end_at > $2
+	ORDER BY start_at`
+	rows, e := s.Db.Query(query, group.ID, time.Now().UTC())
+	if e != nil {
+		return e
+	}
+	for i := range group.Memberships {
+		group.Memberships[i].Absences = []core.Absence{}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID uint64
+		a := core.Absence{Group: group}
+		if e = rows.Scan(&a.ID, &userID, &a.Start, &a.End, &a.Compensate); e != nil {
+			return e
+		}
+		if mem := group.FindMember(userID); mem != nil {
+			a.User = mem.User
+			mem.Absences = append(mem.Absences, a)
+		}
+	}
+	return nil
+}
+
+// GetMemberAbsences fills in the current and upcoming absences of the member
+func (s *Storage) GetMemberAbsences(mem *core.Membership) error {
+	query := `
+	SELECT id, start_at, end_at, compensate
+	FROM absences
+	WHERE group_id = $1 AND user_id = $2 AND end_at > $3
+	ORDER BY start_at`
+	rows, e := s.Db.Query(query, mem.Group.ID, mem.User.ID, time.Now().UTC())
+	if e != nil {
+		return e
+	}
+	mem.Absences = []core.Absence{}
+	defer rows.Close()
+	for rows.Next() {
+		a := core.Absence{Group: mem.Group, User: mem.User}
+		if e = rows.Scan(&a.ID, &a.Start, &a.End, &a.Compensate); e != nil {
+			return e
+		}
+		mem.Absences = append(mem.Absences, a)
+	}
+	return nil
+}
+
+func (s *Storage) CreateAbsence(a *core.Absence) error {
+	query := `
+	INSERT INTO absences (group_id, user_id, start_at, end_at, compensate)
+	VALUES ($1,$2,$3,$4,$5) RETURNING id`
+	return s.Db.QueryRow(query, a.Group.ID, a.User.ID, a.Start, a.End, a.Compensate).Scan(&a.ID)
+}
+
+func (s *Storage) DeleteAbsence(a *core.Absence) error {
+	query := `DELETE FROM absences WHERE id = $1`
+	_, e := s.Db.Exec(query, a.ID)
+	return e
+}
+
 func (s *Storage) GetMemberChores(member *core.Membership) error {
 	rows, err := s.Db.Query("SELECT chores.id, chores.description, chores.name, chores.duration, chore_assignments.complete, chore_assignments.date_assigned, chore_assignments.date_complete FROM chores WHERE chores.group_id = $1 INNER JOIN chore_assignment ON chore_assignment.chore_id = chores.id", member.Group.ID)
 
@@ -554,3 +849,844 @@ func (s *Storage) UpsertSession(ses *core.Session) error {
 	_, err = statement.Exec(ses.UUID, ses.Values, ses.Created, ses.UserID)
 	return err
 }
+
+// CountSessions returns the number of stored login sessions
+func (s *Storage) CountSessions() (int, error) {
+	var n int
+	e := s.Db.QueryRow(`SELECT count(*) FROM sessions`).Scan(&n)
+	return n, e
+}
+
+// DeleteSessions removes the sessions created before the given time and returns how many were
+// removed
+func (s *Storage) DeleteSessions(createdBefore time.Time) (int64, error) {
+	res, e := s.Db.Exec(`DELETE FROM sessions WHERE created < $1`, createdBefore)
+	if e != nil {
+		return 0, e
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) CreateAuditEntry(entry *core.AuditEntry) error {
+	query := `
+	INSERT INTO audit_log (group_id, actor_id, action, target, before, after, created_at)
+	VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id`
+	return s.Db.QueryRow(query, entry.Group.ID, entry.Actor.ID, entry.Action, entry.Target,
+		entry.Before, entry.After, entry.CreatedAt).Scan(&entry.ID)
+}
+
+func (s *Storage) GetAuditLog(group *core.Group) error {
+	query := `
+	SELECT a.id, a.action, a.target, a.before, a.after, a.created_at, a.actor_id, u.uname
+	FROM audit_log a
+	LEFT JOIN users u ON u.id = a.actor_id
+	WHERE a.group_id = $1
+	ORDER BY a.created_at DESC
+	LIMIT 200`
+	rows, e := s.Db.Query(query, group.ID)
+	if e != nil {
+		return e
+	}
+	group.AuditLog = []core.AuditEntry{}
+	defer rows.Close()
+	for rows.Next() {
+		var actorID sql.NullInt64
+		var actorName sql.NullString
+		entry := core.AuditEntry{Group: group, Actor: &core.User{}}
+		e = rows.Scan(&entry.ID, &entry.Action, &entry.Target, &entry.Before, &entry.After,
+			&entry.CreatedAt, &actorID, &actorName)
+		if e != nil {
+			return e
+		}
+		entry.Actor.ID = uint64(actorID.Int64)
+		entry.Actor.Username = actorName.String
+		group.AuditLog = append(group.AuditLog, entry)
+	}
+	return nil
+}
+
+const swapColumns = `
+	sr.id, sr.status, sr.created_at, sr.resolved_at, g.id, g.name,
+	sr.from_id, uf.uname, sr.to_id, ut.uname, sr.chore_id, c.name, sr.counter_id, cc.name
+	FROM swap_requests sr
+	INNER JOIN groups g ON g.id = sr.group_id
+	INNER JOIN users uf ON uf.id = sr.from_id
+	INNER JOIN users ut ON ut.id = sr.to_id
+	INNER JOIN chores c ON c.id = sr.chore_id
+	LEFT JOIN chores cc ON cc.id = sr.counter_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSwapRequest(row scanner, r *core.SwapRequest) error {
+	var resolved sql.NullTime
+	var counterID sql.NullInt64
+	var counterName sql.NullString
+	r.Group = &core.Group{}
+	r.From = &core.User{}
+	r.To = &core.User{}
+	r.Chore = &core.Chore{Group: r.Group}
+	e := row.Scan(&r.ID, &r.Status, &r.CreatedAt, &resolved, &r.Group.ID, &r.Group.Name,
+		&r.From.ID, &r.From.Username, &r.To.ID, &r.To.Username, &r.Chore.ID, &r.Chore.Name,
+		&counterID, &counterName)
+	if e != nil {
+		return e
+	}
+	r.ResolvedAt = resolved.Time
+	r.Counter = nil
+	if counterID.Valid {
+		r.Counter = &core.Chore{ID: uint64(counterID.Int64), Name: counterName.String, Group: r.Group}
+	}
+	return nil
+}
+
+func (s *Storage) CreateSwapRequest(r *core.SwapRequest) error {
+	var counterID sql.NullInt64
+	if r.Counter != nil {
+		counterID = sql.NullInt64{Int64: int64(r.Counter.ID), Valid: true}
+	}
+	query := `
+	INSERT INTO swap_requests (group_id, from_id, to_id, chore_id, counter_id, status, created_at)
+	VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id`
+	return s.Db.QueryRow(query, r.Group.ID, r.From.ID, r.To.ID, r.Chore.ID, counterID,
+		r.Status, r.CreatedAt).Scan(&r.ID)
+}
+
+func (s *Storage) GetSwapRequest(r *core.SwapRequest) error {
+	query := `SELECT ` + swapColumns + ` WHERE sr.id = $1`
+	e := scanSwapRequest(s.Db.QueryRow(query, r.ID), r)
+	if e == sql.ErrNoRows {
+		return errors.ErrNotFound
+	}
+	return e
+}
+
+func (s *Storage) GetSwapRequests(t interface{}) error {
+	switch v := t.(type) {
+	case *core.User:
+		return s.GetUserSwapRequests(v)
+	default:
+		return errors.ErrType
+	}
+}
+
+// GetUserSwapRequests fetches the pending and recently resolved requests made by or to the user
+func (s *Storage) GetUserSwapRequests(user *core.User) error {
+	query := `SELECT ` + swapColumns + `
+	WHERE (sr.from_id = $1 OR sr.to_id = $1)
+	AND (sr.status = $2 OR sr.resolved_at > $3)
+	ORDER BY sr.created_at DESC`
+	rows, e := s.Db.Query(query, user.ID, core.SwapPending, time.Now().UTC().AddDate(0, 0, -14))
+	if e != nil {
+		return e
+	}
+	user.Swaps = []core.SwapRequest{}
+	defer rows.Close()
+	for rows.Next() {
+		r := core.SwapRequest{}
+		if e = scanSwapRequest(rows, &r); e != nil {
+			return e
+		}
+		user.Swaps = append(user.Swaps, r)
+	}
+	return nil
+}
+
+func (s *Storage) UpdateSwapRequest(r *core.SwapRequest) error {
+	query := `UPDATE swap_requests SET status = $1, resolved_at = $2 WHERE id = $3 AND status = $4`
+	res, e := s.Db.Exec(query, r.Status, r.ResolvedAt, r.ID, core.SwapPending)
+	if e != nil {
+		return e
+	}
+	if n, e := res.RowsAffected(); e != nil {
+		return e
+	} else if n != 1 {
+		return core.ErrSwapResolved
+	}
+	return nil
+}
+
+func (s *Storage) ApplySwap(r *core.SwapRequest) error {
+	tx, e := s.Db.Begin()
+	if e != nil {
+		return e
+	}
+	defer tx.Rollback()
+	// Resolving the request first locks it, so a concurrent accept or cancel waits for this one and
+	// then finds the request no longer pending
+	query := `UPDATE swap_requests SET status = $1, resolved_at = $2 WHERE id = $3 AND status = $4`
+	res, e := tx.Exec(query, core.SwapAccepted, r.ResolvedAt, r.ID, core.SwapPending)
+	if e != nil {
+		return e
+	}
+	if n, e := res.RowsAffected(); e != nil {
+		return e
+	} else if n != 1 {
+		return core.ErrSwapResolved
+	}
+	move := `
+	UPDATE chore_assignments SET user_id = $1
+	WHERE chore_id = $2 AND user_id = $3 AND complete IS NOT TRUE`
+	moves := [][3]uint64{{r.To.ID, r.Chore.ID, r.From.ID}}
+	if r.Counter != nil {
+		moves = append(moves, [3]uint64{r.From.ID, r.Counter.ID, r.To.ID})
+	}
+	for _, m := range moves {
+		res, e := tx.Exec(move, m[0], m[1], m[2])
+		if e != nil {
+			return e
+		}
+		if n, e := res.RowsAffected(); e != nil {
+			return e
+		} else if n != 1 {
+			return core.ErrSwapStale
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) CompleteAssignment(ca *core.ChoreAssignment, h *core.HistoryEntry) error {
+	tx, e := s.Db.Begin()
+	if e != nil {
+		return e
+	}
+	defer tx.Rollback()
+	query := `
+	UPDATE chore_assignments SET (complete, date_complete) = ($1, $2)
+	WHERE chore_id = $3 AND user_id = $4 AND complete IS NOT TRUE`
+	res, e := tx.Exec(query, ca.Complete, ca.DateComplete, ca.Chore.ID, ca.User.ID)
+	if e != nil {
+		return e
+	}
+	if n, e := res.RowsAffected(); e != nil {
+		return e
+	} else if n != 1 {
+		return core.ErrAlreadyComplete
+	}
+	if e = insertHistory(tx, h); e != nil {
+		return e
+	}
+	return tx.Commit()
+}
+
+func (s *Storage) InsertHistory(h []core.HistoryEntry) error {
+	tx, e := s.Db.Begin()
+	if e != nil {
+		return e
+	}
+	defer tx.Rollback()
+	for i := range h {
+		if e = insertHistory(tx, &h[i]); e != nil {
+			return e
+		}
+	}
+	return tx.Commit()
+}
+
+func insertHistory(tx *sql.Tx, h *core.HistoryEntry) error {
+	var complete sql.NullTime
+	if !h.Missed {
+		complete = sql.NullTime{Time: h.DateComplete, Valid: true}
+	}
+	query := `
+	INSERT INTO chore_history (group_id, chore_id, chore_name, user_id, date_assigned, date_due,
+	date_complete, points, on_time, missed)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id`
+	return tx.QueryRow(query, h.Group.ID, h.Chore.ID, h.Chore.Name, h.User.ID, h.DateAssigned, h.DateDue,
+		complete, h.Points, h.OnTime, h.Missed).Scan(&h.ID)
+}
+
+func (s *Storage) GetHistory(t interface{}) error {
+	switch v := t.(type) {
+	case *core.Group:
+		return s.GetGroupHistory(v)
+	default:
+		return errors.ErrType
+	}
+}
+
+func (s *Storage) GetGroupHistory(group *core.Group) error {
+	query := `
+	SELECT h.id, h.chore_id, h.chore_name, h.user_id, u.uname, h.date_assigned, h.date_due,
+	h.date_complete, h.points, h.on_time, h.missed
+	FROM chore_history h
+	INNER JOIN users u ON u.id = h.user_id
+	WHERE h.group_id = $1
+	ORDER BY h.id`
+	rows, e := s.Db.Query(query, group.ID)
+	if e != nil {
+		return e
+	}
+	group.History = []core.HistoryEntry{}
+	defer rows.Close()
+	for rows.Next() {
+		var choreID sql.NullInt64
+		var dateDue sql.NullTime
+		var dateComplete sql.NullTime
+		h := core.HistoryEntry{Group: group, Chore: &core.Chore{Group: group}, User: &core.User{}}
+		e = rows.Scan(&h.ID, &choreID, &h.Chore.Name, &h.User.ID, &h.User.Username, &h.DateAssigned,
+			&dateDue, &dateComplete, &h.Points, &h.OnTime, &h.Missed)
+		if e != nil {
+			return e
+		}
+		h.Chore.ID = uint64(choreID.Int64)
+		h.DateDue = dateDue.Time
+		h.DateComplete = dateComplete.Time
+		group.History = append(group.History, h)
+	}
+	return nil
+}
+
+func (s *Storage) GetOverdueAssignments(now time.Time) ([]core.ChoreAssignment, error) {
+	query := fmt.Sprintf(`%s
+	WHERE NOT ca.complete AND ca.date_due < $1 AND ca.escalation < $2
+	ORDER BY g.id`, reminderColumns)
+	return s.getReminderAssignments(query, now, core.EscalateAdmins)
+}
+
+func (s *Storage) GetDueSoonAssignments(now time.Time, until time.Time) ([]core.ChoreAssignment, error) {
+	query := fmt.Sprintf(`%s
+	WHERE NOT ca.complete AND NOT ca.reminded AND ca.date_due >= $1 AND ca.date_due < $2
+	ORDER BY g.id`, reminderColumns)
+	return s.getReminderAssignments(query, now, until)
+}
+
+const reminderColumns = `
+	SELECT ca.date_assigned, ca.date_due, ca.reminded, ca.overdue, ca.escalation, u.id, u.uname, u.email,
+	c.id, c.name, c.description, c.duration, c.points,
+	g.id, g.name, g.missed_policy, g.remind_after, g.escalate_after, g.time_zone
+	FROM chore_assignments ca
+	INNER JOIN users u ON u.id = ca.user_id
+	INNER JOIN chores c ON c.id = ca.chore_id
+	INNER JOIN groups g ON g.id = c.group_id`
+
+func (s *Storage) getReminderAssignments(query string, args ...interface{}) ([]core.ChoreAssignment, error) {
+	rows, e := s.Db.Query(query, args...)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+	cas := make([]core.ChoreAssignment, 0)
+	groups := make(map[uint64]*core.Group)
+	for rows.Next() {
+		ca := core.ChoreAssignment{User: &core.User{}, Chore: &core.Chore{}}
+		g := core.Group{}
+		if e := rows.Scan(&ca.DateAssigned, &ca.DateDue, &ca.Reminded, &ca.Overdue, &ca.Escalation,
+			&ca.User.ID, &ca.User.Username, &ca.User.Email,
+			&ca.Chore.ID, &ca.Chore.Name, &ca.Chore.Description, &ca.Chore.Duration, &ca.Chore.Points,
+			&g.ID, &g.Name, &g.Settings.MissedPolicy, &g.Settings.RemindAfter, &g.Settings.EscalateAfter,
+			&g.Settings.TimeZone); e != nil {
+			return nil, e
+		}
+		if _, ok := groups[g.ID]; !ok {
+			groups[g.ID] = &g
+		}
+		ca.Chore.Group = groups[g.ID]
+		cas = append(cas, ca)
+	}
+	return cas, rows.Err()
+}
+
+func (s *Storage) UpdateAssignmentState(ca *core.ChoreAssignment) error {
+	query := `
+	UPDATE chore_assignments SET (reminded, overdue, escalation) = ($1, $2, $3)
+	WHERE chore_id = $4 AND user_id = $5`
+	_, e := s.Db.Exec(query, ca.Reminded, ca.Overdue, ca.Escalation, ca.Chore.ID, ca.User.ID)
+	return e
+}
+
+func (s *Storage) GetChannelPrefs(user *core.User) error {
+	query := `SELECT channel, enabled, target FROM notification_channels WHERE user_id = $1`
+	rows, e := s.Db.Query(query, user.ID)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	for rows.Next() {
+		p := core.ChannelPref{User: user}
+		if e := rows.Scan(&p.Channel, &p.Enabled, &p.Target); e != nil {
+			return e
+		}
+		user.Channels = append(user.Channels, p)
+	}
+	return rows.Err()
+}
+
+func (s *Storage) SaveChannelPref(p *core.ChannelPref) error {
+	query := `
+	INSERT INTO notification_channels (user_id, channel, enabled, target) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (user_id, channel) DO UPDATE SET enabled = EXCLUDED.enabled, target = EXCLUDED.target`
+	_, e := s.Db.Exec(query, p.User.ID, p.Channel, p.Enabled, p.Target)
+	return e
+}
+
+func (s *Storage) CreateNotification(n *core.Notification) error {
+	query := `
+	INSERT INTO notifications (user_id, group_id, type, message, created_at)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	var groupID sql.NullInt64
+	if n.Group != nil {
+		groupID = sql.NullInt64{Int64: int64(n.Group.ID), Valid: true}
+	}
+	return s.Db.QueryRow(query, n.User.ID, groupID, n.Type, n.Message, n.CreatedAt).Scan(&n.ID)
+}
+
+func (s *Storage) GetNotifications(user *core.User, limit int) error {
+	query := `
+	SELECT n.id, n.type, n.message, n.created_at, n.read_at, g.id, g.name
+	FROM notifications n
+	LEFT JOIN groups g ON g.id = n.group_id
+	WHERE n.user_id = $1
+	ORDER BY n.created_at DESC, n.id DESC
+	LIMIT $2`
+	rows, e := s.Db.Query(query, user.ID, limit)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	user.Notifications = make([]core.Notification, 0)
+	for rows.Next() {
+		var readAt sql.NullTime
+		var groupID sql.NullInt64
+		var groupName sql.NullString
+		n := core.Notification{User: user}
+		if e := rows.Scan(&n.ID, &n.Type, &n.Message, &n.CreatedAt, &readAt, &groupID, &groupName); e != nil {
+			return e
+		}
+		n.ReadAt = readAt.Time
+		if groupID.Valid {
+			n.Group = &core.Group{ID: uint64(groupID.Int64), Name: groupName.String}
+		}
+		user.Notifications = append(user.Notifications, n)
+	}
+	return rows.Err()
+}
+
+func (s *Storage) CountUnread(user *core.User) error {
+	query := `SELECT count(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+	return s.Db.QueryRow(query, user.ID).Scan(&user.Unread)
+}
+
+func (s *Storage) MarkRead(user *core.User, ids []uint64, at time.Time) error {
+	query := `UPDATE notifications SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL`
+	if len(ids) > 0 {
+		strIDs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strIDs = append(strIDs, strconv.FormatUint(id, 10))
+		}
+		query = fmt.Sprintf("%s AND id IN (%s)", query, strings.Join(strIDs, ","))
+	}
+	_, e := s.Db.Exec(query, at, user.ID)
+	return e
+}
+
+func (s *Storage) DeleteNotifications(readBefore time.Time, createdBefore time.Time) (int64, error) {
+	query := `DELETE FROM notifications WHERE read_at < $1 OR created_at < $2`
+	res, e := s.Db.Exec(query, readBefore, createdBefore)
+	if e != nil {
+		return 0, e
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) CreateWebhook(w *core.Webhook) error {
+	query := `
+	INSERT INTO webhooks (group_id, url, secret, events, created_at)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	return s.Db.QueryRow(query, w.Group.ID, w.URL, w.Secret, joinEvents(w.Events), w.CreatedAt).Scan(&w.ID)
+}
+
+func (s *Storage) GetWebhooks(g *core.Group) error {
+	query := `SELECT id, url, secret, events, created_at FROM webhooks WHERE group_id = $1 ORDER BY id`
+	rows, e := s.Db.Query(query, g.ID)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	g.Webhooks = make([]core.Webhook, 0)
+	for rows.Next() {
+		var events string
+		w := core.Webhook{Group: g}
+		if e := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.CreatedAt); e != nil {
+			return e
+		}
+		w.Events = splitEvents(events)
+		g.Webhooks = append(g.Webhooks, w)
+	}
+	return rows.Err()
+}
+
+func (s *Storage) GetWebhook(w *core.Webhook) error {
+	query := `SELECT group_id, url, secret, events, created_at FROM webhooks WHERE id = $1`
+	var events string
+	w.Group = &core.Group{}
+	e := s.Db.QueryRow(query, w.ID).Scan(&w.Group.ID, &w.URL, &w.Secret, &events, &w.CreatedAt)
+	if e == sql.ErrNoRows {
+		return errors.ErrNotFound
+	} else if e != nil {
+		return e
+	}
+	w.Events = splitEvents(events)
+	return nil
+}
+
+func (s *Storage) DeleteWebhook(w *core.Webhook) error {
+	_, e := s.Db.Exec(`DELETE FROM webhooks WHERE id = $1`, w.ID)
+	return e
+}
+
+func (s *Storage) CreateDelivery(d *core.WebhookDelivery) error {
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event, attempt, status_code, error, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	return s.Db.QueryRow(query, d.Webhook.ID, d.Event, d.Attempt, d.StatusCode, d.Error, d.CreatedAt).Scan(&d.ID)
+}
+
+func (s *Storage) GetDeliveries(g *core.Group, limit int) error {
+	query := `
+	SELECT d.id, d.event, d.attempt, d.status_code, d.error, d.created_at, w.id, w.url
+	FROM webhook_deliveries d
+	INNER JOIN webhooks w ON w.id = d.webhook_id
+	WHERE w.group_id = $1
+	ORDER BY d.created_at DESC, d.id DESC
+	LIMIT $2`
+	rows, e := s.Db.Query(query, g.ID, limit)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	g.Deliveries = make([]core.WebhookDelivery, 0)
+	for rows.Next() {
+		d := core.WebhookDelivery{Webhook: &core.Webhook{Group: g}}
+		if e := rows.Scan(&d.ID, &d.Event, &d.Attempt, &d.StatusCode, &d.Error, &d.CreatedAt,
+			&d.Webhook.ID, &d.Webhook.URL); e != nil {
+			return e
+		}
+		g.Deliveries = append(g.Deliveries, d)
+	}
+	return rows.Err()
+}
+
+func joinEvents(events []core.EventType) string {
+	strs := make([]string, 0, len(events))
+	for _, e := range events {
+		strs = append(strs, string(e))
+	}
+	return strings.Join(strs, ",")
+}
+
+func splitEvents(events string) []core.EventType {
+	types := make([]core.EventType, 0)
+	for _, e := range strings.Split(events, ",") {
+		if e != "" {
+			types = append(types, core.EventType(e))
+		}
+	}
+	return types
+}
+
+func (s *Storage) GetDigestPrefs(user *core.User) error {
+	query := `SELECT frequency, hour, weekday, time_zone, last_sent FROM digest_prefs WHERE user_id = $1`
+	e := s.Db.QueryRow(query, user.ID).Scan(&user.Digest.Frequency, &user.Digest.Hour, &user.Digest.Weekday,
+		&user.Digest.TimeZone, &user.Digest.LastSent)
+	if e == sql.ErrNoRows {
+		user.Digest = core.DigestPrefs{Frequency: core.DigestOff, Hour: 7, Weekday: time.Monday, TimeZone: "UTC"}
+		return nil
+	}
+	return e
+}
+
+func (s *Storage) SaveDigestPrefs(user *core.User) error {
+	query := `
+	INSERT INTO digest_prefs (user_id, frequency, hour, weekday, time_zone, last_sent)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (user_id) DO UPDATE SET frequency = EXCLUDED.frequency, hour = EXCLUDED.hour,
+	weekday = EXCLUDED.weekday, time_zone = EXCLUDED.time_zone, last_sent = EXCLUDED.last_sent`
+	d := &user.Digest
+	_, e := s.Db.Exec(query, user.ID, d.Frequency, d.Hour, d.Weekday, d.TimeZone, d.LastSent)
+	return e
+}
+
+// GetDigestUsers fetches the users who receive digests. A digest without its own time zone is
+// sent in the zone of its user.
+func (s *Storage) GetDigestUsers() ([]core.User, error) {
+	query := `
+	SELECT u.id, u.uname, u.email, d.frequency, d.hour, d.weekday, COALESCE(NULLIF(d.time_zone, ''), u.time_zone),
+	d.last_sent
+	FROM digest_prefs d
+	INNER JOIN users u ON u.id = d.user_id
+	WHERE d.frequency <> $1`
+	rows, e := s.Db.Query(query, core.DigestOff)
+	if e != nil {
+		return nil, e
+	}
+	defer rows.Close()
+	users := make([]core.User, 0)
+	for rows.Next() {
+		u := core.User{}
+		if e := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Digest.Frequency, &u.Digest.Hour, &u.Digest.Weekday,
+			&u.Digest.TimeZone, &u.Digest.LastSent); e != nil {
+			return nil, e
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *Storage) MarkDigestSent(user *core.User) error {
+	_, e := s.Db.Exec(`UPDATE digest_prefs SET last_sent = $1 WHERE user_id = $2`, user.Digest.LastSent, user.ID)
+	return e
+}
+
+func (s *Storage) GetUserHistory(user *core.User, since time.Time) error {
+	query := `
+	SELECT h.id, h.chore_id, h.chore_name, h.group_id, g.name, h.date_assigned, h.date_due,
+	h.date_complete, h.points, h.on_time, h.missed
+	FROM chore_history h
+	INNER JOIN groups g ON g.id = h.group_id
+	WHERE h.user_id = $1 AND (h.date_complete >= $2 OR h.date_due >= $2)
+	ORDER BY h.id`
+	rows, e := s.Db.Query(query, user.ID, since)
+	if e != nil {
+		return e
+	}
+	user.History = []core.HistoryEntry{}
+	defer rows.Close()
+	for rows.Next() {
+		var choreID sql.NullInt64
+		var dateDue sql.NullTime
+		var dateComplete sql.NullTime
+		g := &core.Group{}
+		h := core.HistoryEntry{Group: g, Chore: &core.Chore{Group: g}, User: user}
+		e = rows.Scan(&h.ID, &choreID, &h.Chore.Name, &g.ID, &g.Name, &h.DateAssigned,
+			&dateDue, &dateComplete, &h.Points, &h.OnTime, &h.Missed)
+		if e != nil {
+			return e
+		}
+		h.Chore.ID = uint64(choreID.Int64)
+		h.DateDue = dateDue.Time
+		h.DateComplete = dateComplete.Time
+		user.History = append(user.History, h)
+	}
+	return rows.Err()
+}
+
+func (s *Storage) CreateFeedToken(t *core.FeedToken) error {
+	var groupID sql.NullInt64
+	if t.Group != nil {
+		groupID = sql.NullInt64{Int64: int64(t.Group.ID), Valid: true}
+	}
+	query := `INSERT INTO feed_tokens (token, user_id, group_id, created_at) VALUES ($1,$2,$3,$4)`
+	_, e := s.Db.Exec(query, t.Token, t.User.ID, groupID, t.CreatedAt)
+	return e
+}
+
+func (s *Storage) GetFeedToken(t *core.FeedToken) error {
+	query := `
+	SELECT f.user_id, u.uname, f.group_id, g.name, f.created_at
+	FROM feed_tokens f
+	INNER JOIN users u ON u.id = f.user_id
+	LEFT JOIN groups g ON g.id = f.group_id
+	WHERE f.token = $1`
+	var groupID sql.NullInt64
+	var groupName sql.NullString
+	t.User = &core.User{}
+	e := s.Db.QueryRow(query, t.Token).Scan(&t.User.ID, &t.User.Username, &groupID, &groupName, &t.CreatedAt)
+	if e == sql.ErrNoRows {
+		return errors.ErrNotFound
+	} else if e != nil {
+		return e
+	}
+	t.Group = nil
+	if groupID.Valid {
+		t.Group = &core.Group{ID: uint64(groupID.Int64), Name: groupName.String}
+	}
+	return nil
+}
+
+func (s *Storage) GetFeedTokens(user *core.User) error {
+	query := `
+	SELECT f.token, f.group_id, g.name, f.created_at
+	FROM feed_tokens f
+	LEFT JOIN groups g ON g.id = f.group_id
+	WHERE f.user_id = $1
+	ORDER BY f.created_at`
+	rows, e := s.Db.Query(query, user.ID)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	user.Feeds = make([]core.FeedToken, 0)
+	for rows.Next() {
+		var groupID sql.NullInt64
+		var groupName sql.NullString
+		t := core.FeedToken{User: user}
+		if e := rows.Scan(&t.Token, &groupID, &groupName, &t.CreatedAt); e != nil {
+			return e
+		}
+		if groupID.Valid {
+			t.Group = &core.Group{ID: uint64(groupID.Int64), Name: groupName.String}
+		}
+		user.Feeds = append(user.Feeds, t)
+	}
+	return rows.Err()
+}
+
+func (s *Storage) DeleteFeedToken(t *core.FeedToken) error {
+	_, e := s.Db.Exec(`DELETE FROM feed_tokens WHERE token = $1`, t.Token)
+	return e
+}
+
+func (s *Storage) ImportGroupData(g *core.Group, chores []core.Chore, members []core.Membership) error {
+	tx, e := s.Db.Begin()
+	if e != nil {
+		return e
+	}
+	// Rolling back after a successful commit does nothing
+	defer tx.Rollback()
+	choreQuery := `
+	INSERT INTO chores (name, description, duration, points, recurrence, group_id)
+	VALUES($1,$2,$3,$4,$5,$6) RETURNING id`
+	for i := range chores {
+		c := &chores[i]
+		if e := tx.QueryRow(choreQuery, c.Name, c.Description, c.Duration, c.Points, c.Recurrence,
+			g.ID).Scan(&c.ID); e != nil {
+			return e
+		}
+	}
+	memberQuery := `INSERT INTO memberships (joined_at, user_id, group_id) VALUES ($1,$2,$3)`
+	for _, m := range members {
+		if _, e := tx.Exec(memberQuery, m.JoinedAt, m.User.ID, g.ID); e != nil {
+			return e
+		}
+	}
+	return tx.Commit()
+}
+
+// templateBody is how a group template's roles and chores are stored
+type templateBody struct {
+	Roles  []core.ExportRole  `json:"roles"`
+	Chores []core.ExportChore `json:"chores"`
+}
+
+func (s *Storage) CreateGroupTemplate(t *core.GroupTemplate) error {
+	body, e := json.Marshal(templateBody{Roles: t.Roles, Chores: t.Chores})
+	if e != nil {
+		return e
+	}
+	query := `
+	INSERT INTO group_templates (user_id, name, description, body, created_at)
+	VALUES ($1,$2,$3,$4,$5) RETURNING id`
+	return s.Db.QueryRow(query, t.Owner.ID, t.Name, t.Description, string(body), t.CreatedAt).Scan(&t.ID)
+}
+
+func (s *Storage) GetGroupTemplates(user *core.User) error {
+	query := `
+	SELECT id, name, description, body, created_at
+	FROM group_templates WHERE user_id = $1 ORDER BY name`
+	rows, e := s.Db.Query(query, user.ID)
+	if e != nil {
+		return e
+	}
+	defer rows.Close()
+	user.Templates = make([]core.GroupTemplate, 0)
+	for rows.Next() {
+		var body string
+		t := core.GroupTemplate{Owner: user}
+		if e := rows.Scan(&t.ID, &t.Name, &t.Description, &body, &t.CreatedAt); e != nil {
+			return e
+		}
+		if e := decodeTemplate(&t, body); e != nil {
+			return e
+		}
+		user.Templates = append(user.Templates, t)
+	}
+	return rows.Err()
+}
+
+func (s *Storage) GetGroupTemplate(t *core.GroupTemplate) error {
+	query := `SELECT user_id, name, description, body, created_at FROM group_templates WHERE id = $1`
+	var body string
+	t.Owner = &core.User{}
+	e := s.Db.QueryRow(query, t.ID).Scan(&t.Owner.ID, &t.Name, &t.Description, &body, &t.CreatedAt)
+	if e == sql.ErrNoRows {
+		return errors.ErrNotFound
+	} else if e != nil {
+		return e
+	}
+	return decodeTemplate(t, body)
+}
+
+func (s *Storage) DeleteGroupTemplate(t *core.GroupTemplate) error {
+	_, e := s.Db.Exec(`DELETE FROM group_templates WHERE id = $1`, t.ID)
+	return e
+}
+
+func decodeTemplate(t *core.GroupTemplate, body string) error {
+	var b templateBody
+	if e := json.Unmarshal([]byte(body), &b); e != nil {
+		return e
+	}
+	t.Roles = b.Roles
+	t.Chores = b.Chores
+	return nil
+}
+
+// Migrate runs the schema script unless a migration of the same name has already been applied.
+// The script and the record of it run in one transaction. It reports whether the script ran.
+func (s *Storage) Migrate(name string, script string) (bool, error) {
+	applied, e := s.migrated(name)
+	if e != nil || applied {
+		return false, e
+	}
+	tx, e := s.Db.Begin()
+	if e != nil {
+		return false, e
+	}
+	if _, e := tx.Exec(script); e != nil {
+		tx.Rollback()
+		return false, e
+	}
+	if _, e := tx.Exec(`INSERT INTO schema_migrations (name, applied_at) VALUES ($1,$2)`, name,
+		time.Now().UTC()); e != nil {
+		tx.Rollback()
+		return false, e
+	}
+	return true, tx.Commit()
+}
+
+// Baseline records the migration as applied without running it if the table exists but the
+// migration was never recorded. Databases created from the schema before migrations were tracked
+// are adopted this way so the schema is not run against them again. It reports whether the
+// migration was recorded.
+func (s *Storage) Baseline(name string, table string) (bool, error) {
+	applied, e := s.migrated(name)
+	if e != nil || applied {
+		return false, e
+	}
+	var exists bool
+	if e := s.Db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); e != nil {
+		return false, e
+	}
+	if !exists {
+		return false, nil
+	}
+	_, e = s.Db.Exec(`INSERT INTO schema_migrations (name, applied_at) VALUES ($1,$2)`, name, time.Now().UTC())
+	return e == nil, e
+}
+
+// migrated creates the table of applied migrations if needed and reports whether the migration is
+// in it
+func (s *Storage) migrated(name string) (bool, error) {
+	if _, e := s.Db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		name varchar(255) primary key,
+		applied_at timestamp not null
+	)`); e != nil {
+		return false, e
+	}
+	var applied bool
+	e := s.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE name = $1)`, name).Scan(&applied)
+	return applied, e
+}
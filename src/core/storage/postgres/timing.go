@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"chores-suck/metrics"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+)
+
+var queryDuration = metrics.Default.NewHistogram("chores_db_query_duration_seconds",
+	"Time taken by database statements by kind of statement.", metrics.DefaultBuckets, "op", "result")
+
+// observeQuery records how long a statement took. Statements are grouped by their first keyword so
+// the number of series stays small.
+func observeQuery(query string, start time.Time, e error) {
+	op := "other"
+	if f := strings.Fields(query); len(f) > 0 {
+		switch kw := strings.ToLower(f[0]); kw {
+		case "select", "insert", "update", "delete", "with":
+			op = kw
+		}
+	}
+	result := "ok"
+	if e != nil && e != driver.ErrSkip {
+		result = "error"
+	}
+	queryDuration.Observe(time.Since(start).Seconds(), op, result)
+}
+
+// timedConnector hands out connections that time every statement they run
+type timedConnector struct {
+	driver.Connector
+}
+
+func (c timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, e := c.Connector.Connect(ctx)
+	if e != nil {
+		return nil, e
+	}
+	return &timedConn{Conn: conn}, nil
+}
+
+// timedConn passes everything to the driver's connection, timing queries and statements on the
+// way. Optional driver interfaces the connection lacks are reported the way database/sql expects.
+type timedConn struct {
+	driver.Conn
+}
+
+func (c *timedConn) Prepare(query string) (driver.Stmt, error) {
+	st, e := c.Conn.Prepare(query)
+	if e != nil {
+		return nil, e
+	}
+	return &timedStmt{Stmt: st, query: query}, nil
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	pc, ok := c.Conn.(driver.ConnPrepareContext)
+	if !ok {
+		return c.Prepare(query)
+	}
+	st, e := pc.PrepareContext(ctx, query)
+	if e != nil {
+		return nil, e
+	}
+	return &timedStmt{Stmt: st, query: query}, nil
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, e := q.QueryContext(ctx, query, args)
+	observeQuery(query, start, e)
+	return rows, e
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	x, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, e := x.ExecContext(ctx, query, args)
+	observeQuery(query, start, e)
+	return res, e
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *timedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type timedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var e error
+	if x, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, e = x.ExecContext(ctx, args)
+	} else {
+		res, e = s.Stmt.Exec(values(args))
+	}
+	observeQuery(s.query, start, e)
+	return res, e
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var e error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, e = q.QueryContext(ctx, args)
+	} else {
+		rows, e = s.Stmt.Query(values(args))
+	}
+	observeQuery(s.query, start, e)
+	return rows, e
+}
+
+func (s *timedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if ch, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return ch.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	v := make([]driver.Value, len(args))
+	for i, a := range args {
+		v[i] = a.Value
+	}
+	return v
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrSwapStale occurs when the assignments a swap request refers to changed before it was accepted
+	ErrSwapStale = conflict("The chores in this request have been reassigned since it was made")
+	// ErrSwapResolved occurs when a swap request was accepted, declined or cancelled before
+	ErrSwapResolved = conflict("This request has already been resolved")
+)
+
+type SwapRepository interface {
+	CreateSwapRequest(r *SwapRequest) error
+	GetSwapRequest(r *SwapRequest) error
+	GetSwapRequests(t interface{}) error
+	// UpdateSwapRequest saves the status of a pending request. It returns ErrSwapResolved if the
+	// request is no longer pending.
+	UpdateSwapRequest(r *SwapRequest) error
+	// ApplySwap moves the assignments described by the request and marks the request accepted in a
+	// single transaction. It returns ErrSwapResolved if the request is no longer pending and
+	// ErrSwapStale if either assignment no longer matches the request.
+	ApplySwap(r *SwapRequest) error
+	GetConstraints(interface{}) error
+}
+
+type SwapService interface {
+	// Propose creates a pending request for the user to hand off or swap one of their chores
+	Propose(r *SwapRequest, user *User) error
+	Accept(r *SwapRequest, user *User) error
+	Decline(r *SwapRequest, user *User) error
+	Cancel(r *SwapRequest, user *User) error
+	GetSwapRequest(r *SwapRequest) error
+	GetSwapRequests(user *User) error
+}
+
+type swapService struct {
+	repo   SwapRepository
+	gs     GroupService
+	audit  AuditService
+	events EventSink
+}
+
+func NewSwapService(r SwapRepository, g GroupService, a AuditService, ev EventSink) SwapService {
+	return &swapService{
+		repo:   r,
+		gs:     g,
+		audit:  a,
+		events: ev,
+	}
+}
+
+func (s *swapService) Propose(r *SwapRequest, user *User) error {
+	if r.From.ID != user.ID {
+		return forbidden("You can only offer your own chores")
+	}
+	if r.To.ID == user.ID {
+		return invalid("to_id", "You cannot swap chores with yourself")
+	}
+	if e := s.load(r); e != nil {
+		return e
+	}
+	if !assignedTo(r.Chore, r.From) {
+		return forbidden("This chore is not assigned to you")
+	}
+	if r.Counter != nil && !assignedTo(r.Counter, r.To) {
+		return conflict(fmt.Sprintf("%s is not assigned to %s", r.Counter.Name, r.To.Username))
+	}
+	if e := s.check(r); e != nil {
+		return e
+	}
+	if e := s.GetSwapRequests(user); e != nil {
+		return internal("SwapService.Propose", e)
+	}
+	for _, v := range user.Swaps {
+		if v.IsPending() && v.Chore.ID == r.Chore.ID {
+			return conflict("There is already a pending request for this chore")
+		}
+	}
+	r.Status = SwapPending
+	r.CreatedAt = time.Now().UTC()
+	if e := s.repo.CreateSwapRequest(r); e != nil {
+		return internal("SwapService.Propose", e)
+	}
+	msg := fmt.Sprintf("%s offered you %s in %s", r.From.Username, r.Chore.Name, r.Group.Name)
+	if r.Counter != nil {
+		msg = fmt.Sprintf("%s wants to swap %s for your %s in %s", r.From.Username, r.Chore.Name, r.Counter.Name,
+			r.Group.Name)
+	}
+	s.events.Publish(Event{Type: EventSwapRequested, Group: r.Group, Actor: user, Recipients: []*User{r.To},
+		Chore: r.Chore, Message: msg})
+	return nil
+}
+
+func (s *swapService) Accept(r *SwapRequest, user *User) error {
+	if e := s.pending(r, r.To, user); e != nil {
+		return e
+	}
+	if e := s.load(r); e != nil {
+		return e
+	}
+	if e := s.check(r); e != nil {
+		s.resolve(r, SwapCancelled)
+		return e
+	}
+	r.ResolvedAt = time.Now().UTC()
+	if e := s.repo.ApplySwap(r); e != nil {
+		if errors.Is(e, ErrSwapStale) {
+			s.resolve(r, SwapCancelled)
+			return ErrSwapStale
+		}
+		if errors.Is(e, ErrSwapResolved) {
+			return ErrSwapResolved
+		}
+		return internal("SwapService.Accept", e)
+	}
+	r.Status = SwapAccepted
+	s.notifyResolved(r, user, r.From)
+	if r.IsHandoff() {
+		s.audit.Record(r.Group, user, ActionChoreHandoff, r.Chore.Name,
+			fmt.Sprintf("%s=%s", r.Chore.Name, r.From.Username),
+			fmt.Sprintf("%s=%s", r.Chore.Name, r.To.Username))
+	} else {
+		s.audit.Record(r.Group, user, ActionChoreSwap, fmt.Sprintf("%s, %s", r.Chore.Name, r.Counter.Name),
+			fmt.Sprintf("%s=%s, %s=%s", r.Chore.Name, r.From.Username, r.Counter.Name, r.To.Username),
+			fmt.Sprintf("%s=%s, %s=%s", r.Chore.Name, r.To.Username, r.Counter.Name, r.From.Username))
+	}
+	return nil
+}
+
+func (s *swapService) Decline(r *SwapRequest, user *User) error {
+	if e := s.pending(r, r.To, user); e != nil {
+		return e
+	}
+	if e := s.resolve(r, SwapDeclined); e != nil {
+		return e
+	}
+	s.notifyResolved(r, user, r.From)
+	return nil
+}
+
+func (s *swapService) Cancel(r *SwapRequest, user *User) error {
+	if e := s.pending(r, r.From, user); e != nil {
+		return e
+	}
+	if e := s.resolve(r, SwapCancelled); e != nil {
+		return e
+	}
+	s.notifyResolved(r, user, r.To)
+	return nil
+}
+
+func (s *swapService) GetSwapRequest(r *SwapRequest) error {
+	if e := s.repo.GetSwapRequest(r); e != nil {
+		return lookup("SwapService.GetSwapRequest", "Swap request not found", e)
+	}
+	return nil
+}
+
+func (s *swapService) GetSwapRequests(user *User) error {
+	return s.repo.GetSwapRequests(user)
+}
+
+// pending checks that the request is still open and that the user is the party expected to act on it
+func (s *swapService) pending(r *SwapRequest, party *User, user *User) error {
+	if party.ID != user.ID {
+		return forbidden("You are not allowed to respond to this request")
+	}
+	if !r.IsPending() {
+		return ErrSwapResolved
+	}
+	return nil
+}
+
+func (s *swapService) resolve(r *SwapRequest, status SwapStatus) error {
+	r.Status = status
+	r.ResolvedAt = time.Now().UTC()
+	if e := s.repo.UpdateSwapRequest(r); e != nil {
+		if errors.Is(e, ErrSwapResolved) {
+			return ErrSwapResolved
+		}
+		return internal("SwapService.resolve", e)
+	}
+	return nil
+}
+
+// load fetches the group's members, chores and constraints and points the request at them
+func (s *swapService) load(r *SwapRequest) error {
+	if e := s.gs.GetGroup(r.Group); e != nil {
+		return internal("SwapService.load", e)
+	}
+	if e := s.gs.GetMemberships(r.Group); e != nil {
+		return internal("SwapService.load", e)
+	}
+	if e := s.gs.GetChores(r.Group); e != nil {
+		return e
+	}
+	if e := s.repo.GetConstraints(r.Group); e != nil {
+		return internal("SwapService.load", e)
+	}
+	from := r.Group.FindMember(r.From.ID)
+	to := r.Group.FindMember(r.To.ID)
+	if from == nil || to == nil {
+		return notFound("Member not found")
+	}
+	r.From = from.User
+	r.To = to.User
+	if r.Chore = r.Group.FindChore(r.Chore.ID); r.Chore == nil {
+		return notFound("Chore not found")
+	}
+	if r.Counter != nil {
+		if r.Counter = r.Group.FindChore(r.Counter.ID); r.Counter == nil {
+			return notFound("Chore not found")
+		}
+	}
+	return nil
+}
+
+// check verifies the chores are still assigned as the request expects and that the chore
+// constraints allow each chore to change hands
+func (s *swapService) check(r *SwapRequest) error {
+	if !assignedTo(r.Chore, r.From) {
+		return ErrSwapStale
+	}
+	if r.Counter != nil && !assignedTo(r.Counter, r.To) {
+		return ErrSwapStale
+	}
+	to := r.Group.FindMember(r.To.ID)
+	if e := s.gs.GetRoles(to); e != nil {
+		return internal("SwapService.check", e)
+	}
+	if !r.Chore.Allows(to) {
+		return invalid("to_id", "The chore's constraints do not allow it to go to that member")
+	}
+	if r.Counter != nil {
+		from := r.Group.FindMember(r.From.ID)
+		if e := s.gs.GetRoles(from); e != nil {
+			return internal("SwapService.check", e)
+		}
+		if !r.Counter.Allows(from) {
+			return invalid("counter_id", "The constraints of the chore offered in return do not allow it to go to you")
+		}
+	}
+	return nil
+}
+
+// notifyResolved tells the other party to a request that it was resolved
+func (s *swapService) notifyResolved(r *SwapRequest, user *User, to *User) {
+	s.events.Publish(Event{
+		Type:       EventSwapResolved,
+		Group:      r.Group,
+		Actor:      user,
+		Recipients: []*User{to},
+		Chore:      r.Chore,
+		Message:    fmt.Sprintf("%s %s the request for %s", user.Username, strings.ToLower(r.StatusText()), r.Chore.Name),
+	})
+}
+
+func assignedTo(c *Chore, u *User) bool {
+	return c.Assignment != nil && c.Assignment.User != nil && c.Assignment.User.ID == u.ID && !c.Assignment.Complete
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// savedTemplatePrefix starts the key of every saved template, the rest of the key is its id
+const savedTemplatePrefix = "saved-"
+
+// defaultRoles are created with every group, so templates never contain them
+var defaultRoles = []string{"Owner", "Admin", "Default"}
+
+// BuiltinTemplates are offered to everyone creating a group
+var BuiltinTemplates = []GroupTemplate{
+	{
+		Key:         "apartment",
+		Name:        "4-person apartment",
+		Description: "Shared spaces split between flatmates, with one flatmate keeping the chore list up to date.",
+		Roles: []ExportRole{
+			{Name: "House-Manager", GetsChores: true, Permissions: []string{"edit_chores", "edit_members"}},
+		},
+		Chores: []ExportChore{
+			{Name: "Kitchen", Description: "Wipe counters and stove, clean the sink, mop the floor", Duration: 30, Recurrence: RecurWeekly},
+			{Name: "Bathroom", Description: "Toilet, shower, sink and mirror", Duration: 45, Recurrence: RecurWeekly},
+			{Name: "Living-room", Description: "Vacuum and dust", Duration: 30, Recurrence: RecurWeekly},
+			{Name: "Trash", Description: "Take out the trash and recycling", Duration: 10, Recurrence: RecurWeekly},
+			{Name: "Supplies", Description: "Restock toilet paper, soap and cleaning products", Duration: 15, Recurrence: RecurMonthly},
+		},
+	},
+	{
+		Key:         "family",
+		Name:        "Family with kids",
+		Description: "Parents run the group and kids get age friendly daily jobs.",
+		Roles: []ExportRole{
+			{Name: "Parent", GetsChores: true, Permissions: []string{"edit_members", "edit_chores", "edit_roles", "view_audit"}},
+			{Name: "Kid", GetsChores: true, Permissions: []string{}},
+		},
+		Chores: []ExportChore{
+			{Name: "Make-beds", Duration: 5, Recurrence: RecurDaily},
+			{Name: "Set-table", Duration: 10, Recurrence: RecurDaily},
+			{Name: "Dishes", Duration: 20, Recurrence: RecurDaily},
+			{Name: "Tidy-toys", Duration: 15, Recurrence: RecurDaily},
+			{Name: "Laundry", Description: "Wash, dry and fold", Duration: 60, Recurrence: RecurWeekly},
+			{Name: "Water-plants", Duration: 5, Recurrence: RecurWeekly},
+			{Name: "Groceries", Duration: 60, Recurrence: RecurWeekly},
+		},
+	},
+	{
+		Key:         "couple",
+		Name:        "Couple",
+		Description: "The essentials for two people sharing a home.",
+		Chores: []ExportChore{
+			{Name: "Cooking", Duration: 45, Recurrence: RecurDaily},
+			{Name: "Dishes", Duration: 20, Recurrence: RecurDaily},
+			{Name: "Laundry", Duration: 60, Recurrence: RecurWeekly},
+			{Name: "Cleaning", Description: "Vacuum, dust and bathroom", Duration: 90, Recurrence: RecurWeekly},
+			{Name: "Groceries", Duration: 60, Recurrence: RecurWeekly},
+		},
+	},
+}
+
+type TemplateRepository interface {
+	CreateGroupTemplate(t *GroupTemplate) error
+	// GetGroupTemplates loads the templates the user saved
+	GetGroupTemplates(user *User) error
+	GetGroupTemplate(t *GroupTemplate) error
+	DeleteGroupTemplate(t *GroupTemplate) error
+	GetRoles(t interface{}) error
+	GetChores(t interface{}) error
+}
+
+type TemplateService interface {
+	// GetTemplates loads the built in templates followed by the ones the user saved
+	GetTemplates(user *User) error
+	// GetTemplate returns the built in or saved template with the key. Saved templates can only be
+	// used by their owner.
+	GetTemplate(key string, user *User) (*GroupTemplate, error)
+	// Save saves the group's roles and chores as a template for the user
+	Save(g *Group, name string, user *User) error
+	Delete(key string, user *User) error
+}
+
+type templateService struct {
+	repo TemplateRepository
+}
+
+func NewTemplateService(r TemplateRepository) TemplateService {
+	return &templateService{
+		repo: r,
+	}
+}
+
+func (s *templateService) GetTemplates(user *User) error {
+	if e := s.repo.GetGroupTemplates(user); e != nil {
+		return e
+	}
+	for i := range user.Templates {
+		user.Templates[i].Key = savedTemplatePrefix + strconv.FormatUint(user.Templates[i].ID, 10)
+	}
+	user.Templates = append(append([]GroupTemplate{}, BuiltinTemplates...), user.Templates...)
+	return nil
+}
+
+func (s *templateService) GetTemplate(key string, user *User) (*GroupTemplate, error) {
+	for i := range BuiltinTemplates {
+		if BuiltinTemplates[i].Key == key {
+			t := BuiltinTemplates[i]
+			return &t, nil
+		}
+	}
+	if !strings.HasPrefix(key, savedTemplatePrefix) {
+		return nil, notFound("Template not found")
+	}
+	id, e := strconv.ParseUint(strings.TrimPrefix(key, savedTemplatePrefix), 10, 64)
+	if e != nil {
+		return nil, notFound("Template not found")
+	}
+	t := GroupTemplate{ID: id, Key: key}
+	if e := s.repo.GetGroupTemplate(&t); e != nil || t.Owner.ID != user.ID {
+		return nil, notFound("Template not found")
+	}
+	return &t, nil
+}
+
+func (s *templateService) Save(g *Group, name string, user *User) error {
+	mem := g.FindMember(user.ID)
+	if mem == nil {
+		return notFound("Member not found")
+	}
+	if e := s.repo.GetRoles(mem); e != nil {
+		return internal("TemplateService.Save", e)
+	}
+	mem.BuildSuperRole()
+	if !mem.SuperRole.Can(EditGroup) {
+		return forbidden("You do not have permission to save this group as a template")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return invalid("template_name", "Template name cannot be empty")
+	}
+	if e := s.repo.GetRoles(g); e != nil {
+		return internal("TemplateService.Save", e)
+	}
+	g.Chores = nil
+	if e := s.repo.GetChores(g); e != nil {
+		return internal("TemplateService.Save", e)
+	}
+	t := GroupTemplate{
+		Name:        name,
+		Description: fmt.Sprintf("Saved from %s", g.Name),
+		Owner:       user,
+		Roles:       make([]ExportRole, 0),
+		Chores:      make([]ExportChore, 0, len(g.Chores)),
+		CreatedAt:   time.Now().UTC(),
+	}
+	for i := range g.Roles {
+		if !isDefaultRole(g.Roles[i].Name) {
+			t.Roles = append(t.Roles, exportRole(&g.Roles[i]))
+		}
+	}
+	for i := range g.Chores {
+		// Constraints name members of this group, so they do not carry over to other groups
+		c := exportChore(&g.Chores[i])
+		c.Constraints = nil
+		t.Chores = append(t.Chores, c)
+	}
+	if e := s.repo.CreateGroupTemplate(&t); e != nil {
+		return internal("TemplateService.Save", e)
+	}
+	return nil
+}
+
+func (s *templateService) Delete(key string, user *User) error {
+	t, e := s.GetTemplate(key, user)
+	if e != nil {
+		return e
+	}
+	if t.IsBuiltin() {
+		return forbidden("Built in templates cannot be deleted")
+	}
+	if e := s.repo.DeleteGroupTemplate(t); e != nil {
+		return internal("TemplateService.Delete", e)
+	}
+	return nil
+}
+
+func isDefaultRole(name string) bool {
+	for _, r := range defaultRoles {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package core
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Chore describes properties of a chore
 type Chore struct {
@@ -8,8 +11,134 @@ type Chore struct {
 	Description string
 	Name        string
 	Duration    int
+	// Points overrides the number of points the chore is worth when greater than zero
+	Points      int
+	Recurrence  Recurrence
 	Group       *Group
 	Assignment  *ChoreAssignment
+	Constraints []ChoreConstraint
+}
+
+// Recurrence is how often a chore needs doing
+type Recurrence string
+
+const (
+	RecurNone    Recurrence = ""
+	RecurDaily   Recurrence = "daily"
+	RecurWeekly  Recurrence = "weekly"
+	RecurMonthly Recurrence = "monthly"
+)
+
+// Recurrences lists the recurrences a chore can have
+var Recurrences = []Recurrence{RecurNone, RecurDaily, RecurWeekly, RecurMonthly}
+
+// Text returns a description of the recurrence for display
+func (r Recurrence) Text() string {
+	switch r {
+	case RecurDaily:
+		return "Every day"
+	case RecurWeekly:
+		return "Every week"
+	case RecurMonthly:
+		return "Every month"
+	}
+	return "Does not repeat"
+}
+
+// RRule returns the iCalendar recurrence rule for the recurrence or an empty string if the chore
+// does not repeat
+func (r Recurrence) RRule() string {
+	switch r {
+	case RecurDaily:
+		return "FREQ=DAILY"
+	case RecurWeekly:
+		return "FREQ=WEEKLY"
+	case RecurMonthly:
+		return "FREQ=MONTHLY"
+	}
+	return ""
+}
+
+func validRecurrence(r Recurrence) bool {
+	for _, v := range Recurrences {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Worth returns the number of points earned by completing the chore on time. Unless overridden
+// a chore is worth a point for every five minutes it takes.
+func (c *Chore) Worth() int {
+	if c.Points > 0 {
+		return c.Points
+	}
+	if c.Duration < 5 {
+		return 1
+	}
+	return c.Duration / 5
+}
+
+type ConstraintKind int
+
+const (
+	// ConstraintPin assigns the chore only to the given user
+	ConstraintPin ConstraintKind = iota
+	// ConstraintExclude never assigns the chore to the given user
+	ConstraintExclude
+	// ConstraintRole assigns the chore only to members holding the given role
+	ConstraintRole
+)
+
+// ChoreConstraint restricts which members of a group a chore may be assigned to. User is set for
+// pin and exclude constraints, Role is set for role constraints.
+type ChoreConstraint struct {
+	ID    uint64
+	Kind  ConstraintKind
+	Chore *Chore
+	User  *User
+	Role  *Role
+}
+
+func (c *ChoreConstraint) IsPin() bool {
+	return c.Kind == ConstraintPin
+}
+
+func (c *ChoreConstraint) IsExclude() bool {
+	return c.Kind == ConstraintExclude
+}
+
+func (c *ChoreConstraint) IsRole() bool {
+	return c.Kind == ConstraintRole
+}
+
+// Allows reports whether the constraints on the chore permit it to be assigned to the member.
+// The member's roles must be loaded for role constraints to be honored.
+func (c *Chore) Allows(m *Membership) bool {
+	hasRoleRule := false
+	hasRole := false
+	for i := range c.Constraints {
+		con := &c.Constraints[i]
+		switch con.Kind {
+		case ConstraintPin:
+			if con.User.ID != m.User.ID {
+				return false
+			}
+		case ConstraintExclude:
+			if con.User.ID == m.User.ID {
+				return false
+			}
+		case ConstraintRole:
+			hasRoleRule = true
+			for j := range m.Roles {
+				if m.Roles[j].ID == con.Role.ID {
+					hasRole = true
+				}
+			}
+		}
+	}
+	return !hasRoleRule || hasRole
 }
 
 type ChoreAssignment struct {
@@ -17,17 +146,74 @@ type ChoreAssignment struct {
 	DateAssigned time.Time
 	DateComplete time.Time
 	DateDue      time.Time
-	Chore        *Chore
-	User         *User
+	// Reminded is set once the assignee has been told the assignment is due soon
+	Reminded bool
+	// Overdue is set once the assignment has been found incomplete past its due date
+	Overdue bool
+	// Escalation is how far the overdue assignment has been escalated. See the Escalate constants.
+	Escalation int
+	Chore      *Chore
+	User       *User
+}
+
+const (
+	EscalateNone     = 0
+	EscalateAssignee = 1
+	EscalateAdmins   = 2
+)
+
+type MissedPolicy string
+
+const (
+	// MissedCount reassigns missed chores as usual and counts them against the assignee
+	MissedCount MissedPolicy = "count"
+	// MissedCarry leaves missed chores with their assignee for another period
+	MissedCarry MissedPolicy = "carry"
+	// MissedReassign hands missed chores to the next member after the assignee
+	MissedReassign MissedPolicy = "reassign"
+)
+
+// MissedPolicies lists the policies a group can choose from
+var MissedPolicies = []MissedPolicy{MissedCount, MissedCarry, MissedReassign}
+
+// Text returns a description of the policy for display
+func (p MissedPolicy) Text() string {
+	switch p {
+	case MissedCarry:
+		return "Carry over to the same member"
+	case MissedReassign:
+		return "Reassign to the next member"
+	}
+	return "Count as missed"
+}
+
+// GroupSettings controls how a group handles overdue and missed chores. Overdue assignees are
+// notified RemindAfter hours past the due date and admins EscalateAfter hours past it. Chores fall
+// due at the end of a day in the group's TimeZone, an IANA name such as "Europe/Berlin".
+type GroupSettings struct {
+	MissedPolicy  MissedPolicy
+	RemindAfter   int
+	EscalateAfter int
+	TimeZone      string
 }
 
 // Group defines properties for a group
 type Group struct {
 	ID          uint64
 	Name        string
+	Settings    GroupSettings
 	Memberships []Membership
 	Roles       []Role
 	Chores      []Chore
+	AuditLog    []AuditEntry
+	Webhooks    []Webhook
+	Deliveries  []WebhookDelivery
+	History     []HistoryEntry
+}
+
+// Location returns the group's time zone, falling back to UTC when it is not set or unknown
+func (g *Group) Location() *time.Location {
+	return location(g.Settings.TimeZone)
 }
 
 func (g *Group) FindRole(id uint64) *Role {
@@ -75,6 +261,34 @@ func (g *Group) FindChore(value interface{}) *Chore {
 	return nil
 }
 
+// HistoryEntry records the outcome of a single chore assignment. Missed entries are recorded for
+// assignments that were still incomplete past their due date when the chores were reassigned.
+type HistoryEntry struct {
+	ID           uint64
+	Group        *Group
+	Chore        *Chore
+	User         *User
+	DateAssigned time.Time
+	DateDue      time.Time
+	DateComplete time.Time
+	Points       int
+	OnTime       bool
+	Missed       bool
+}
+
+// Standing summarizes a member's performance within a group over a period of time
+type Standing struct {
+	User      *User
+	Points    int
+	Completed int
+	OnTime    int
+	Missed    int
+	// Streak is the number of consecutive on time completions up to now and BestStreak the
+	// longest such run. Both consider the member's entire history.
+	Streak     int
+	BestStreak int
+}
+
 // Membership relates a User to a specific group
 type Membership struct {
 	JoinedAt    time.Time
@@ -82,6 +296,10 @@ type Membership struct {
 	Group       *Group
 	Assignments []ChoreAssignment
 	Roles       []Role
+	Absences    []Absence
+	//Debt is the number of minutes of chores the member owes the group for
+	//absences they agreed to make up for
+	Debt int
 	//SuperRole is a combination of all the roles assigned to the member
 	//This is a convenience for checking permissions for a member without
 	//checking all of their roles individually. See Membership.BuildSuperRole()
@@ -98,6 +316,32 @@ func (m *Membership) BuildSuperRole() {
 	}
 }
 
+// AbsentOn returns the absence covering the given time or nil if the member is available
+func (m *Membership) AbsentOn(t time.Time) *Absence {
+	for i := range m.Absences {
+		if m.Absences[i].Covers(t) {
+			return &m.Absences[i]
+		}
+	}
+	return nil
+}
+
+// Absence is a period of time during which a member should not be assigned chores in a group.
+// If Compensate is set the member makes up for the chores they missed once they are back.
+type Absence struct {
+	ID         uint64
+	User       *User
+	Group      *Group
+	Start      time.Time
+	End        time.Time
+	Compensate bool
+}
+
+// Covers reports whether t falls within the absence
+func (a *Absence) Covers(t time.Time) bool {
+	return !t.Before(a.Start) && t.Before(a.End)
+}
+
 // User defines properties of a user
 type User struct {
 	ID          uint64
@@ -107,6 +351,308 @@ type User struct {
 	CreatedAt   time.Time
 	Memberships []Membership
 	Chores      []Chore
+	Swaps       []SwapRequest
+	Channels    []ChannelPref
+	// Notifications holds the most recent items of the user's inbox and Unread the number of
+	// items in it the user has not read
+	Notifications []Notification
+	Unread        int
+	Digest        DigestPrefs
+	History       []HistoryEntry
+	Feeds         []FeedToken
+	Templates     []GroupTemplate
+	// Locale is the tag of the language the user chose for the site, or empty to use the
+	// language of their browser
+	Locale string
+	// TimeZone is the IANA name of the zone times are shown to the user in
+	TimeZone string
+}
+
+// Location returns the user's time zone, falling back to UTC when it is not set or unknown
+func (u *User) Location() *time.Location {
+	return location(u.TimeZone)
+}
+
+// Channel returns the user's preference for the named notification channel or nil if it is not loaded
+func (u *User) Channel(name string) *ChannelPref {
+	for i := range u.Channels {
+		if u.Channels[i].Channel == name {
+			return &u.Channels[i]
+		}
+	}
+	return nil
+}
+
+// Notification channels users can choose to receive notifications over
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInbox   = "inbox"
+)
+
+// Channels lists the notification channels in the order they are shown to users
+var Channels = []string{ChannelInbox, ChannelEmail, ChannelWebhook}
+
+// ChannelPref is a user's choice to receive notifications over a channel. Target holds the channel's
+// destination where the user must provide one, such as the URL of a webhook.
+type ChannelPref struct {
+	User    *User
+	Channel string
+	Enabled bool
+	Target  string
+}
+
+// Text returns the name of the channel for display
+func (p *ChannelPref) Text() string {
+	switch p.Channel {
+	case ChannelEmail:
+		return "Email"
+	case ChannelWebhook:
+		return "Webhook"
+	}
+	return "In-app inbox"
+}
+
+// Webhook is a URL outside the app that a group's events are posted to. Payloads are signed with
+// Secret so the receiver can check they came from us.
+type Webhook struct {
+	ID        uint64
+	Group     *Group
+	URL       string
+	Secret    string
+	Events    []EventType
+	CreatedAt time.Time
+}
+
+// Subscribes reports whether the webhook should receive events of the type
+func (w *Webhook) Subscribes(t EventType) bool {
+	for _, v := range w.Events {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID         uint64
+	Webhook    *Webhook
+	Event      EventType
+	Attempt    int
+	StatusCode int
+	Error      string
+	CreatedAt  time.Time
+}
+
+func (d *WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+type DigestFrequency string
+
+const (
+	DigestOff    DigestFrequency = "off"
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+// DigestFrequencies lists the frequencies a user can choose to receive digests at
+var DigestFrequencies = []DigestFrequency{DigestOff, DigestDaily, DigestWeekly}
+
+// Text returns a description of the frequency for display
+func (f DigestFrequency) Text() string {
+	switch f {
+	case DigestDaily:
+		return "Daily"
+	case DigestWeekly:
+		return "Weekly"
+	}
+	return "Never"
+}
+
+// DigestPrefs controls when a user is emailed a summary of their chores. Digests are sent at Hour
+// in the user's TimeZone, every day or on Weekday for weekly digests.
+type DigestPrefs struct {
+	Frequency DigestFrequency
+	Hour      int
+	Weekday   time.Weekday
+	TimeZone  string
+	LastSent  time.Time
+}
+
+// Location returns the digest time zone, falling back to UTC when it is not set or unknown
+func (d *DigestPrefs) Location() *time.Location {
+	return location(d.TimeZone)
+}
+
+// location loads the named time zone, falling back to UTC when the name is empty or unknown
+func location(name string) *time.Location {
+	if loc, e := LoadLocation(name); e == nil && name != "" {
+		return loc
+	}
+	return time.UTC
+}
+
+// validTimeZone reports whether name is empty or a time zone that can be loaded
+func validTimeZone(name string) bool {
+	_, e := LoadLocation(name)
+	return e == nil
+}
+
+// zones holds the time zones loaded so far by name. Only zones that exist are kept, so it never
+// grows beyond the zone database however many names are asked for.
+var zones sync.Map
+
+// LoadLocation is time.LoadLocation, which reads the zone database on every call, with the zones
+// it loads kept for later calls
+func LoadLocation(name string) (*time.Location, error) {
+	if loc, ok := zones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, e := time.LoadLocation(name)
+	if e != nil {
+		return nil, e
+	}
+	zones.Store(name, loc)
+	return loc, nil
+}
+
+// Scheduled returns the latest time at or before now a digest was scheduled to be sent. It
+// returns the zero time if digests are off.
+func (d *DigestPrefs) Scheduled(now time.Time) time.Time {
+	if d.Frequency != DigestDaily && d.Frequency != DigestWeekly {
+		return time.Time{}
+	}
+	local := now.In(d.Location())
+	at := time.Date(local.Year(), local.Month(), local.Day(), d.Hour, 0, 0, 0, local.Location())
+	if at.After(local) {
+		at = at.AddDate(0, 0, -1)
+	}
+	for d.Frequency == DigestWeekly && at.Weekday() != d.Weekday {
+		at = at.AddDate(0, 0, -1)
+	}
+	return at
+}
+
+// Due reports whether a digest should be sent at now
+func (d *DigestPrefs) Due(now time.Time) bool {
+	at := d.Scheduled(now)
+	return !at.IsZero() && d.LastSent.Before(at)
+}
+
+// Period returns how far back a digest sent at now looks for completed chores, and how far ahead
+// it looks for upcoming ones
+func (d *DigestPrefs) Period() time.Duration {
+	if d.Frequency == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Digest summarizes a user's chores across all of their groups
+type Digest struct {
+	User      *User
+	Since     time.Time
+	Until     time.Time
+	Upcoming  []Chore
+	Overdue   []Chore
+	Completed []HistoryEntry
+}
+
+func (d *Digest) IsEmpty() bool {
+	return len(d.Upcoming) == 0 && len(d.Overdue) == 0 && len(d.Completed) == 0
+}
+
+// GroupTemplate is a starting set of roles and chores for new groups. Built in templates have no
+// Owner, saved templates belong to the user who saved them. Key identifies either kind.
+type GroupTemplate struct {
+	ID          uint64
+	Key         string
+	Name        string
+	Description string
+	Owner       *User
+	Roles       []ExportRole
+	Chores      []ExportChore
+	CreatedAt   time.Time
+}
+
+func (t *GroupTemplate) IsBuiltin() bool {
+	return t.Owner == nil
+}
+
+// FeedToken grants read access to a calendar feed without logging in. A token with a Group is a
+// feed of the group's chores, otherwise it is a feed of the user's own chores.
+type FeedToken struct {
+	Token     string
+	User      *User
+	Group     *Group
+	CreatedAt time.Time
+}
+
+// Feed is the set of chores published by a calendar feed
+type Feed struct {
+	Token  *FeedToken
+	Name   string
+	Chores []Chore
+}
+
+// Notification is a message about an event delivered to one user
+type Notification struct {
+	ID        uint64
+	User      *User
+	Group     *Group
+	Type      EventType
+	Message   string
+	CreatedAt time.Time
+	ReadAt    time.Time
+}
+
+func (n *Notification) IsRead() bool {
+	return !n.ReadAt.IsZero()
+}
+
+type SwapStatus int
+
+const (
+	SwapPending SwapStatus = iota
+	SwapAccepted
+	SwapDeclined
+	SwapCancelled
+)
+
+// SwapRequest proposes moving a chore assigned to one member over to another member of the group.
+// When Counter is set the two chores trade assignees, otherwise the chore is handed off.
+type SwapRequest struct {
+	ID         uint64
+	Group      *Group
+	From       *User
+	To         *User
+	Chore      *Chore
+	Counter    *Chore
+	Status     SwapStatus
+	CreatedAt  time.Time
+	ResolvedAt time.Time
+}
+
+func (r *SwapRequest) IsHandoff() bool {
+	return r.Counter == nil
+}
+
+func (r *SwapRequest) IsPending() bool {
+	return r.Status == SwapPending
+}
+
+func (r *SwapRequest) StatusText() string {
+	switch r.Status {
+	case SwapAccepted:
+		return "Accepted"
+	case SwapDeclined:
+		return "Declined"
+	case SwapCancelled:
+		return "Cancelled"
+	}
+	return "Pending"
 }
 
 // Session contains properties for a session pulled from the database
@@ -117,6 +663,19 @@ type Session struct {
 	UserID  uint64
 }
 
+// AuditEntry records a single administrative action taken within a group. Before and After hold
+// a readable description of the target prior to and following the action.
+type AuditEntry struct {
+	ID        uint64
+	Group     *Group
+	Actor     *User
+	Action    string
+	Target    string
+	Before    string
+	After     string
+	CreatedAt time.Time
+}
+
 // Role defines what a member has access to within a group and if they get chores assigned to them
 type Role struct {
 	ID          uint64
@@ -134,6 +693,7 @@ const (
 	EditChores  = 1
 	EditGroup   = 2
 	EditRoles   = 3
+	ViewAudit   = 4
 )
 
 /* Permission Bits
@@ -141,6 +701,7 @@ const (
 1 - EditChores
 2 - EditGroup
 3 - DeleteGroup
+4 - ViewAudit
 */
 
 func (role *Role) Can(bit PermBit) bool {
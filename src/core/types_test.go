@@ -0,0 +1,49 @@
+package core
+
+import (
+	"testing"
+	"time"
+	// The tests must not depend on the zone database of the machine running them
+	_ "time/tzdata"
+)
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name  string
+		zone  string
+		want  string
+		valid bool
+	}{
+		{"empty", "", "UTC", true},
+		{"utc", "UTC", "UTC", true},
+		{"iana name", "Europe/Berlin", "Europe/Berlin", true},
+		{"unknown", "Mars/Olympus_Mons", "", false},
+		{"path", "../../etc/passwd", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, e := LoadLocation(tt.zone)
+			if (e == nil) != tt.valid {
+				t.Fatalf("LoadLocation(%q) error = %v, want valid %t", tt.zone, e, tt.valid)
+			}
+			if validTimeZone(tt.zone) != tt.valid {
+				t.Errorf("validTimeZone(%q) = %t, want %t", tt.zone, !tt.valid, tt.valid)
+			}
+			if !tt.valid {
+				if _, ok := zones.Load(tt.zone); ok {
+					t.Errorf("unknown zone %q was cached", tt.zone)
+				}
+				if got := location(tt.zone); got != time.UTC {
+					t.Errorf("location(%q) = %s, want UTC", tt.zone, got)
+				}
+				return
+			}
+			if loc.String() != tt.want {
+				t.Errorf("LoadLocation(%q) = %s, want %s", tt.zone, loc, tt.want)
+			}
+			if again, _ := LoadLocation(tt.zone); again != loc {
+				t.Errorf("LoadLocation(%q) loaded the zone again instead of using the cache", tt.zone)
+			}
+		})
+	}
+}
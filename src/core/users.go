@@ -1,14 +1,14 @@
 package core
 
 import (
-	"errors"
+	"chores-suck/i18n"
 
 	storagErr "chores-suck/core/storage/errors"
 )
 
 var (
-	ErrEmailExists = errors.New("Email already registered")
-	ErrNameExists  = errors.New("Username already registered")
+	ErrEmailExists = conflict("Email already registered")
+	ErrNameExists  = conflict("Username already registered")
 )
 
 type UserRepository interface {
@@ -16,6 +16,8 @@ type UserRepository interface {
 	GetUserByEmail(user *User) error
 	GetUserByID(user *User) error
 	CreateUser(user *User) error
+	UpdatePassword(user *User) error
+	UpdatePreferences(user *User) error
 	GetMemberships(t interface{}) error
 	GetChores(t interface{}) error
 	GetRoles(t interface{}) error
@@ -25,6 +27,11 @@ type UserService interface {
 	GetUserByName(user *User) error
 	GetUserByID(user *User) error
 	CreateUser(user *User) error
+	// UpdatePassword saves the user's password, which must already be hashed
+	UpdatePassword(user *User) error
+	// UpdatePreferences saves the user's language, which must be empty or have a catalog, and time
+	// zone
+	UpdatePreferences(user *User) error
 	CheckEmailExists(email string) (bool, error)
 	CheckUsernameExists(name string) (bool, error)
 	GetMemberships(user *User) error
@@ -52,21 +59,39 @@ func (s *userService) GetUserByID(user *User) error {
 func (s *userService) CreateUser(user *User) error {
 	exists, e := s.CheckEmailExists(user.Email)
 	if e != nil {
-		return e
+		return internal("UserService.CreateUser", e)
 	} else if exists {
 		return ErrEmailExists
 	}
 
 	exists, e = s.CheckUsernameExists(user.Username)
 	if e != nil {
-		return e
+		return internal("UserService.CreateUser", e)
 	} else if exists {
 		return ErrNameExists
 	}
 
-	e = s.repo.CreateUser(user)
-	return e
+	if e := s.repo.CreateUser(user); e != nil {
+		return internal("UserService.CreateUser", e)
+	}
+	return nil
+}
 
+func (s *userService) UpdatePassword(user *User) error {
+	return s.repo.UpdatePassword(user)
+}
+
+func (s *userService) UpdatePreferences(user *User) error {
+	if _, ok := i18n.Lookup(user.Locale); !ok && user.Locale != "" {
+		return invalid("locale", "Unknown language")
+	}
+	if !validTimeZone(user.TimeZone) {
+		return invalid("user_time_zone", "Unknown time zone")
+	}
+	if e := s.repo.UpdatePreferences(user); e != nil {
+		return internal("UserService.UpdatePreferences", e)
+	}
+	return nil
 }
 
 func (s *userService) CheckEmailExists(email string) (bool, error) {
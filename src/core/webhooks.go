@@ -0,0 +1,194 @@
+package core
+
+import (
+	"chores-suck/logging"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// WebhookEvents lists the event types a group webhook can subscribe to
+var WebhookEvents = []EventType{EventAssigned, EventCompleted, EventOverdue, EventMemberJoined, EventRotation}
+
+// internalNets are the address ranges of private networks and of the host itself. Webhooks may not
+// point into them, or any group admin could make the server send requests to internal services.
+var internalNets = parseNets(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+func parseNets(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, e := net.ParseCIDR(c)
+		if e != nil {
+			panic(e)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// PublicIP reports whether webhooks may be delivered to the address: it is neither loopback,
+// link-local, multicast nor in a private range
+func PublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range internalNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+type WebhookRepository interface {
+	CreateWebhook(w *Webhook) error
+	GetWebhooks(g *Group) error
+	GetWebhook(w *Webhook) error
+	DeleteWebhook(w *Webhook) error
+	CreateDelivery(d *WebhookDelivery) error
+	// GetDeliveries loads up to limit of the most recent delivery attempts to the group's webhooks
+	GetDeliveries(g *Group, limit int) error
+}
+
+type WebhookService interface {
+	// Create registers a webhook for the group with a newly generated signing secret
+	Create(w *Webhook, user *User) error
+	Delete(w *Webhook, user *User) error
+	GetWebhook(w *Webhook) error
+	GetWebhooks(g *Group) error
+	GetDeliveries(g *Group, limit int) error
+	RecordDelivery(d *WebhookDelivery)
+}
+
+type webhookService struct {
+	repo  WebhookRepository
+	gs    GroupService
+	audit AuditService
+	log   *logging.Logger
+}
+
+func NewWebhookService(r WebhookRepository, g GroupService, a AuditService, l *logging.Logger) WebhookService {
+	return &webhookService{
+		repo:  r,
+		gs:    g,
+		audit: a,
+		log:   l,
+	}
+}
+
+func (s *webhookService) Create(w *Webhook, user *User) error {
+	if e := s.authorize(w.Group, user); e != nil {
+		return e
+	}
+	u, e := url.Parse(w.URL)
+	if e != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalid("url", "Webhook URL must be a valid http or https URL")
+	}
+	if e := checkWebhookHost("url", u.Hostname()); e != nil {
+		return e
+	}
+	if len(w.Events) == 0 {
+		return invalid("events", "Choose at least one event")
+	}
+	for _, t := range w.Events {
+		known := false
+		for _, v := range WebhookEvents {
+			known = known || v == t
+		}
+		if !known {
+			return invalid("events", "Unknown event type")
+		}
+	}
+	secret := make([]byte, 32)
+	if _, e := rand.Read(secret); e != nil {
+		return internal("WebhookService.Create", fmt.Errorf("generate secret: %w", e))
+	}
+	w.Secret = hex.EncodeToString(secret)
+	w.CreatedAt = time.Now().UTC()
+	if e := s.repo.CreateWebhook(w); e != nil {
+		return internal("WebhookService.Create", e)
+	}
+	s.audit.Record(w.Group, user, ActionWebhookCreate, w.URL, "", describeWebhook(w))
+	return nil
+}
+
+func (s *webhookService) Delete(w *Webhook, user *User) error {
+	if e := s.authorize(w.Group, user); e != nil {
+		return e
+	}
+	if e := s.repo.DeleteWebhook(w); e != nil {
+		return internal("WebhookService.Delete", e)
+	}
+	s.audit.Record(w.Group, user, ActionWebhookDelete, w.URL, describeWebhook(w), "")
+	return nil
+}
+
+func (s *webhookService) GetWebhook(w *Webhook) error {
+	return s.repo.GetWebhook(w)
+}
+
+func (s *webhookService) GetWebhooks(g *Group) error {
+	return s.repo.GetWebhooks(g)
+}
+
+func (s *webhookService) GetDeliveries(g *Group, limit int) error {
+	return s.repo.GetDeliveries(g, limit)
+}
+
+func (s *webhookService) RecordDelivery(d *WebhookDelivery) {
+	if e := s.repo.CreateDelivery(d); e != nil {
+		s.log.Error("Failed to record webhook delivery", "webhook", d.Webhook.ID, "err", e)
+	}
+}
+
+// checkWebhookHost checks that every address the host resolves to is public. Problems are
+// reported against the form field.
+func checkWebhookHost(field string, host string) error {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var e error
+		if ips, e = net.LookupIP(host); e != nil || len(ips) == 0 {
+			return invalid(field, "Webhook host could not be found")
+		}
+	}
+	for _, ip := range ips {
+		if !PublicIP(ip) {
+			return invalid(field, "Webhook URL must not point to a private or local address")
+		}
+	}
+	return nil
+}
+
+// authorize checks the user may manage the group's webhooks
+func (s *webhookService) authorize(g *Group, user *User) error {
+	mem := g.FindMember(user.ID)
+	if mem == nil {
+		return notFound("Member not found")
+	}
+	if e := s.gs.GetRoles(mem); e != nil {
+		return internal("WebhookService.authorize", e)
+	}
+	if !mem.SuperRole.Can(EditGroup) {
+		return forbidden("You do not have permission to manage webhooks")
+	}
+	return nil
+}
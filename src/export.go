@@ -0,0 +1,59 @@
+package main
+
+import (
+	"chores-suck/config"
+	"chores-suck/core"
+	"chores-suck/core/storage/postgres"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runExport writes a group's export to a file or standard output. Usage:
+//
+//	chores-suck export -group 3 [-format json|chores|history] [-o file]
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	groupID := fs.Uint64("group", 0, "id of the group to export")
+	format := fs.String("format", "json", "json for the full document, chores or history for CSV")
+	out := fs.String("o", "", "file to write to instead of standard output")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if *groupID == 0 {
+		return errors.New("export: -group is required")
+	}
+	var write func(x *core.GroupExport, w io.Writer) error
+	switch *format {
+	case "json":
+		write = (*core.GroupExport).WriteJSON
+	case "chores":
+		write = (*core.GroupExport).WriteChoresCSV
+	case "history":
+		write = (*core.GroupExport).WriteHistoryCSV
+	default:
+		return fmt.Errorf("export: unknown format %q", *format)
+	}
+
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	x, e := core.NewExportService(repo).Build(&core.Group{ID: *groupID})
+	if e != nil {
+		return fmt.Errorf("export: %w", e)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, e := os.Create(*out)
+		if e != nil {
+			return e
+		}
+		defer f.Close()
+		w = f
+	}
+	return write(x, w)
+}
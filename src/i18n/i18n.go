@@ -0,0 +1,202 @@
+// Package i18n translates the text shown to users. Messages are written in English where they are
+// used and looked up by that text in the catalog of the user's language, so a message missing from
+// a catalog is shown in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default is the language messages are written in and the one used when no other is wanted
+const Default = "en"
+
+//go:embed locales/*.json
+var catalogs embed.FS
+
+// locales holds every language with a catalog, by tag
+var locales = load()
+
+// Locale translates messages into one language and formats dates the way it writes them
+type Locale struct {
+	// Tag is the language's BCP 47 tag, such as "de"
+	Tag string
+	// Name is the name of the language in the language itself, for picking it from a list
+	Name string
+
+	messages    map[string]string
+	months      []string
+	shortMonths []string
+	date        string
+	dateTime    string
+}
+
+// catalog is the layout of a locale file. Dates are written with the placeholders {day},
+// {month}, {mon} for the short month name, {year} and {time}.
+type catalog struct {
+	Name        string            `json:"name"`
+	Months      []string          `json:"months"`
+	ShortMonths []string          `json:"shortMonths"`
+	Date        string            `json:"date"`
+	DateTime    string            `json:"dateTime"`
+	Messages    map[string]string `json:"messages"`
+}
+
+func load() map[string]*Locale {
+	files, e := catalogs.ReadDir("locales")
+	if e != nil {
+		panic(e)
+	}
+	ls := make(map[string]*Locale)
+	for _, f := range files {
+		data, e := catalogs.ReadFile(path.Join("locales", f.Name()))
+		if e != nil {
+			panic(e)
+		}
+		var c catalog
+		if e := json.Unmarshal(data, &c); e != nil {
+			// The catalogs are embedded, so a broken one must not make it into a build
+			panic(fmt.Sprintf("i18n: %s: %v", f.Name(), e))
+		}
+		if len(c.Months) != 12 || len(c.ShortMonths) != 12 {
+			panic(fmt.Sprintf("i18n: %s: a catalog needs 12 months", f.Name()))
+		}
+		tag := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		ls[tag] = &Locale{
+			Tag:         tag,
+			Name:        c.Name,
+			messages:    c.Messages,
+			months:      c.Months,
+			shortMonths: c.ShortMonths,
+			date:        c.Date,
+			dateTime:    c.DateTime,
+		}
+	}
+	if _, ok := ls[Default]; !ok {
+		panic("i18n: no catalog for " + Default)
+	}
+	return ls
+}
+
+// Lookup returns the locale with the tag, ignoring case, and whether there is one
+func Lookup(tag string) (*Locale, bool) {
+	l, ok := locales[strings.ToLower(tag)]
+	return l, ok
+}
+
+// Get returns the locale with the tag or the default locale if there is none
+func Get(tag string) *Locale {
+	if l, ok := Lookup(tag); ok {
+		return l
+	}
+	return locales[Default]
+}
+
+// Locales returns every available locale ordered by tag
+func Locales() []*Locale {
+	ls := make([]*Locale, 0, len(locales))
+	for _, l := range locales {
+		ls = append(ls, l)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Tag < ls[j].Tag })
+	return ls
+}
+
+// Negotiate picks the locale for a request. A preferred tag the user chose wins if it is
+// available, otherwise the languages of the Accept-Language header are tried in order of their
+// quality, each falling back to its base language, such as "de" for "de-AT".
+func Negotiate(acceptLanguage string, preferred string) *Locale {
+	if l, ok := Lookup(preferred); ok {
+		return l
+	}
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if tag == "*" {
+			break
+		}
+		if l, ok := Lookup(tag); ok {
+			return l
+		}
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			if l, ok := Lookup(tag[:i]); ok {
+				return l
+			}
+		}
+	}
+	return locales[Default]
+}
+
+// parseAcceptLanguage returns the tags of the header ordered by quality, leaving out those the
+// client refuses with a quality of 0
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, e := strconv.ParseFloat(param[2:], 64)
+				if e != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// T translates a message. With args the translation is used as a format for them, the way
+// fmt.Sprintf does.
+func (l *Locale) T(msg string, args ...interface{}) string {
+	if translated, ok := l.messages[msg]; ok && translated != "" {
+		msg = translated
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Date formats the day of t, such as "March 4, 2021"
+func (l *Locale) Date(t time.Time) string {
+	return l.format(l.date, t)
+}
+
+// DateTime formats t to the minute, such as "Mar 4, 2021 15:04"
+func (l *Locale) DateTime(t time.Time) string {
+	return l.format(l.dateTime, t)
+}
+
+func (l *Locale) format(layout string, t time.Time) string {
+	r := strings.NewReplacer(
+		"{day}", strconv.Itoa(t.Day()),
+		"{month}", l.months[t.Month()-1],
+		"{mon}", l.shortMonths[t.Month()-1],
+		"{year}", strconv.Itoa(t.Year()),
+		"{time}", t.Format("15:04"),
+	)
+	return r.Replace(layout)
+}
@@ -0,0 +1,154 @@
+// Package ical writes chore assignments as iCalendar (RFC 5545) feeds that calendar applications
+// can subscribe to.
+package ical
+
+import (
+	"bufio"
+	"chores-suck/core"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// ContentType is the media type feeds are served with
+	ContentType = "text/calendar; charset=utf-8"
+	prodID      = "-//Chores Suck//Chore Feed//EN"
+	stampFormat = "20060102T150405Z"
+	// lineLimit is the longest a content line may be in octets before it has to be folded
+	lineLimit = 75
+)
+
+// Encode writes the feed as a calendar. Every assigned chore becomes a VEVENT ending at its due
+// date, for calendars that do not show tasks, and a VTODO carrying its completion status. Chores
+// that are not assigned have no dates and are left out.
+func Encode(w io.Writer, feed *core.Feed, now time.Time) error {
+	cw := &writer{w: bufio.NewWriter(w)}
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", prodID)
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	cw.line("X-WR-CALNAME", escape(feed.Name))
+	for i := range feed.Chores {
+		c := &feed.Chores[i]
+		if c.Assignment == nil || c.Assignment.DateDue.IsZero() {
+			continue
+		}
+		event(cw, feed, c, now)
+		todo(cw, feed, c, now)
+	}
+	cw.line("END", "VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+func event(cw *writer, feed *core.Feed, c *core.Chore, now time.Time) {
+	ca := c.Assignment
+	start := ca.DateDue.Add(-time.Duration(c.Duration) * time.Minute)
+	cw.line("BEGIN", "VEVENT")
+	cw.line("UID", uid(c, "event"))
+	cw.line("DTSTAMP", stamp(now))
+	cw.line("DTSTART", stamp(start))
+	cw.line("DTEND", stamp(ca.DateDue))
+	cw.line("SUMMARY", escape(summary(feed, c)))
+	cw.line("DESCRIPTION", escape(c.Description))
+	if rule := c.Recurrence.RRule(); rule != "" {
+		cw.line("RRULE", rule)
+	}
+	cw.line("TRANSP", "TRANSPARENT")
+	cw.line("END", "VEVENT")
+}
+
+func todo(cw *writer, feed *core.Feed, c *core.Chore, now time.Time) {
+	ca := c.Assignment
+	cw.line("BEGIN", "VTODO")
+	cw.line("UID", uid(c, "todo"))
+	cw.line("DTSTAMP", stamp(now))
+	if !ca.DateAssigned.IsZero() {
+		cw.line("DTSTART", stamp(ca.DateAssigned))
+	}
+	cw.line("DUE", stamp(ca.DateDue))
+	cw.line("SUMMARY", escape(summary(feed, c)))
+	cw.line("DESCRIPTION", escape(c.Description))
+	if rule := c.Recurrence.RRule(); rule != "" {
+		cw.line("RRULE", rule)
+	}
+	if ca.Complete {
+		cw.line("STATUS", "COMPLETED")
+		cw.line("PERCENT-COMPLETE", "100")
+		if !ca.DateComplete.IsZero() {
+			cw.line("COMPLETED", stamp(ca.DateComplete))
+		}
+	} else {
+		cw.line("STATUS", "NEEDS-ACTION")
+	}
+	cw.line("END", "VTODO")
+}
+
+// summary names the chore and, in group feeds, who it is assigned to
+func summary(feed *core.Feed, c *core.Chore) string {
+	s := c.Name
+	if feed.Token.Group != nil && c.Assignment.User != nil {
+		s = fmt.Sprintf("%s (%s)", s, c.Assignment.User.Username)
+	}
+	if c.Assignment.Complete {
+		s += " - done"
+	}
+	return s
+}
+
+// uid identifies one assignment of the chore. A new rotation assigns the chore again with a new
+// date, which makes it a new calendar entry rather than moving the old one.
+func uid(c *core.Chore, kind string) string {
+	return fmt.Sprintf("chore-%d-%d-%s@chores-suck", c.ID, c.Assignment.DateAssigned.Unix(), kind)
+}
+
+func stamp(t time.Time) string {
+	return t.UTC().Format(stampFormat)
+}
+
+// escape escapes a TEXT property value
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(s)
+}
+
+// writer writes content lines, folding them at the line limit. The first error is kept and
+// later writes are skipped.
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (cw *writer) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+	l := name + ":" + value
+	// Continuation lines start with a space, which counts towards their length
+	limit := lineLimit
+	for len(l) > limit {
+		// Fold on a character boundary so multi-byte characters are not split
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(l[cut]) {
+			cut--
+		}
+		if _, cw.err = cw.w.WriteString(l[:cut] + "\r\n "); cw.err != nil {
+			return
+		}
+		l = l[cut:]
+		limit = lineLimit - 1
+	}
+	_, cw.err = cw.w.WriteString(l + "\r\n")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"chores-suck/config"
+	"chores-suck/core"
+	"chores-suck/core/storage/postgres"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// runImport adds the chores and members in a file to a group as one of its members. Without
+// -apply it only prints what would be imported. Usage:
+//
+//	chores-suck import -group 3 -as alice [-format csv|json] [-apply] chores.csv
+func runImport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	groupID := fs.Uint64("group", 0, "id of the group to import into")
+	as := fs.String("as", "", "member the import is checked against and recorded under")
+	format := fs.String("format", "", "csv or json, taken from the file extension when not given")
+	apply := fs.Bool("apply", false, "import the file instead of only checking it")
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if fs.NArg() != 1 {
+		return errors.New("import: expected one file to import")
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	defer repo.Db.Close()
+	g, e := loadGroup(repo, *groupID)
+	if e != nil {
+		return fmt.Errorf("import: %w", e)
+	}
+	user, e := loadMember(g, *as)
+	if e != nil {
+		return fmt.Errorf("import: %w", e)
+	}
+	f, e := os.Open(path)
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	imports := core.NewImportService(repo, core.NewAuditService(repo, logger(cfg, "core.audit")), core.NewEvents(),
+		logger(cfg, "core.import"))
+	plan, e := imports.Plan(g, user, core.ImportFormat(*format), f)
+	if e != nil {
+		return fmt.Errorf("import: %w", e)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROW\tKIND\tNAME\tRESULT")
+	for _, it := range plan.Items {
+		kind, name := "chore", ""
+		if it.Member != nil {
+			kind, name = "member", it.Member.User.Username
+		} else {
+			name = it.Chore.Name
+		}
+		result := "ok"
+		if it.Error != "" {
+			result = it.Error
+		} else if it.Skipped != "" {
+			result = "skipped: " + it.Skipped
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", it.Row, kind, name, result)
+	}
+	if e := tw.Flush(); e != nil {
+		return e
+	}
+	if !*apply {
+		if plan.Valid() {
+			fmt.Println("Nothing was imported. Run again with -apply to import the file.")
+		}
+		return nil
+	}
+	if e := imports.Apply(plan, user); e != nil {
+		return fmt.Errorf("import: %w", e)
+	}
+	fmt.Printf("Imported %d chores and %d members into %s\n", len(plan.Chores()), len(plan.Members()), g.Name)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"chores-suck/core"
+	"chores-suck/core/storage/postgres"
+	"chores-suck/logging"
+	"chores-suck/metrics"
+	"math"
+)
+
+var domainEvents = metrics.Default.NewCounter("chores_events_total",
+	"Domain events by type, such as chore.completed, chore.rotation and chore.overdue.", "type")
+
+// instrument adds the metrics that come from the domain events and the database to the default
+// registry
+func instrument(events *core.Events, repo *postgres.Storage, l *logging.Logger) {
+	events.Subscribe(core.EventSinkFunc(func(e core.Event) {
+		domainEvents.Inc(string(e.Type))
+	}))
+	metrics.Default.NewGaugeFunc("chores_sessions", "Stored login sessions.", func() float64 {
+		n, e := repo.CountSessions()
+		if e != nil {
+			l.Warn("Failed to count sessions", "err", e)
+			return math.NaN()
+		}
+		return float64(n)
+	})
+	metrics.Default.NewGaugeFunc("chores_db_open_connections", "Open database connections.", func() float64 {
+		return float64(repo.Db.Stats().OpenConnections)
+	})
+}
@@ -0,0 +1,140 @@
+// Package live streams group events to open browser pages so they can update without a reload.
+package live
+
+import (
+	"chores-suck/core"
+	"sync"
+	"time"
+)
+
+// clientBuffer is how many messages may wait for a slow client before new ones are dropped
+const clientBuffer = 16
+
+// Message is the JSON sent to clients for each event
+type Message struct {
+	Type      core.EventType `json:"type"`
+	GroupID   uint64         `json:"group_id"`
+	ChoreID   uint64         `json:"chore_id,omitempty"`
+	Actor     string         `json:"actor,omitempty"`
+	Message   string         `json:"message"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Client receives the messages of one group for one user until it leaves the hub
+type Client struct {
+	Group    uint64
+	User     uint64
+	Messages chan Message
+}
+
+// Hub is an in-process publish/subscribe hub. It is subscribed to the core event publisher and
+// hands each group event to the clients watching that group.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[uint64]map[*Client]struct{}
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[uint64]map[*Client]struct{}),
+	}
+}
+
+// Join registers a client of the user for the group's messages. Clients must Leave when they are
+// done. The Messages channel is closed when the hub closes.
+func (h *Hub) Join(groupID uint64, userID uint64) *Client {
+	c := &Client{Group: groupID, User: userID, Messages: make(chan Message, clientBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(c.Messages)
+		return c
+	}
+	if h.clients[groupID] == nil {
+		h.clients[groupID] = make(map[*Client]struct{})
+	}
+	h.clients[groupID][c] = struct{}{}
+	return c
+}
+
+func (h *Hub) Leave(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[c.Group], c)
+	if len(h.clients[c.Group]) == 0 {
+		delete(h.clients, c.Group)
+	}
+}
+
+// Publish never blocks the service publishing the event. A client that has fallen behind misses
+// the message, which is harmless since every message only tells the page to refresh. The text of
+// an event meant for some members only, such as an assignment, is only sent to their clients; the
+// rest of the group's clients are told to refresh without it.
+func (h *Hub) Publish(e core.Event) {
+	if e.Group == nil {
+		return
+	}
+	m := Message{
+		Type:      e.Type,
+		GroupID:   e.Group.ID,
+		Message:   e.Message,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.Chore != nil {
+		m.ChoreID = e.Chore.ID
+	}
+	if e.Actor != nil {
+		m.Actor = e.Actor.Username
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	quiet := m
+	if len(e.Recipients) > 0 {
+		quiet.Message = ""
+	}
+	for c := range h.clients[e.Group.ID] {
+		send := m
+		if !addressed(e, c.User) {
+			send = quiet
+		}
+		select {
+		case c.Messages <- send:
+		default:
+		}
+	}
+}
+
+// addressed reports whether the event's text is meant for the user: it has no recipients, so it is
+// meant for the whole group, or the user is one of them
+func addressed(e core.Event, userID uint64) bool {
+	if len(e.Recipients) == 0 {
+		return true
+	}
+	for _, u := range e.Recipients {
+		if u != nil && u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the Messages channel of every client so their streams end, which lets the server
+// shut down without waiting for open pages to go away
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, clients := range h.clients {
+		for c := range clients {
+			close(c.Messages)
+		}
+	}
+	h.clients = make(map[uint64]map[*Client]struct{})
+}
@@ -0,0 +1,42 @@
+package live
+
+import (
+	"chores-suck/core"
+	"testing"
+)
+
+func TestPublishRecipients(t *testing.T) {
+	tests := []struct {
+		name       string
+		recipients []*core.User
+		want       map[uint64]string
+	}{
+		{"group event", nil, map[uint64]string{1: "news", 2: "news"}},
+		{"personal notice", []*core.User{{ID: 1}}, map[uint64]string{1: "news", 2: ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub()
+			defer h.Close()
+			clients := map[uint64]*Client{1: h.Join(7, 1), 2: h.Join(7, 2)}
+			other := h.Join(8, 1)
+			h.Publish(core.Event{Type: core.EventAssigned, Group: &core.Group{ID: 7}, Recipients: tt.recipients,
+				Message: "news"})
+			for id, c := range clients {
+				select {
+				case m := <-c.Messages:
+					if m.Message != tt.want[id] {
+						t.Errorf("user %d got %q, want %q", id, m.Message, tt.want[id])
+					}
+				default:
+					t.Errorf("user %d got no message", id)
+				}
+			}
+			select {
+			case m := <-other.Messages:
+				t.Errorf("client of another group got %q", m.Message)
+			default:
+			}
+		})
+	}
+}
@@ -1,31 +1,121 @@
 package main
 
 import (
+	"chores-suck/assets"
+	"chores-suck/config"
 	"chores-suck/core"
 	"chores-suck/core/storage/postgres"
+	"chores-suck/live"
+	"chores-suck/logging"
+	"chores-suck/notify"
+	"chores-suck/scheduler"
 	"chores-suck/web"
 	"chores-suck/web/sessions"
-	"log"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/context"
 )
 
 func main() {
-	repo := postgres.NewStorage()
+	if e := runCommand(os.Args[1:]); e != nil {
+		fmt.Fprintln(os.Stderr, "chores-suck:", describe(e))
+		os.Exit(1)
+	}
+}
+
+// runServe starts the web server along with the scheduled jobs
+func runServe(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if e := fs.Parse(args); e != nil {
+		return e
+	}
+	if e := cfg.Validate(); e != nil {
+		return e
+	}
+
+	log := logging.New(os.Stderr, cfg.LogLevel)
+	templates, public := assets.Templates(), assets.Public()
+	if cfg.Dev {
+		templates, public = os.DirFS(cfg.TemplatePath), os.DirFS(cfg.StaticPath)
+	}
+	site, e := web.NewAssets(templates, public, cfg.Dev)
+	if e != nil {
+		return e
+	}
+
+	repo, e := postgres.NewStorage(cfg.PostgresConn)
+	if e != nil {
+		return e
+	}
+	events := core.NewEvents()
+	auditCore := core.NewAuditService(repo, log.Component("core.audit"))
 	userCore := core.NewUserService(repo)
-	groupCore := core.NewGroupService(repo)
-	roleCore := core.NewRoleService(repo, userCore)
-	choreCore := core.NewChoreService(repo, groupCore)
+	groupCore := core.NewGroupService(repo, auditCore, events, log.Component("core.groups"))
+	roleCore := core.NewRoleService(repo, userCore, auditCore, events)
+	choreCore := core.NewChoreService(repo, groupCore, auditCore, events, log.Component("core.chores"))
+	availCore := core.NewAvailabilityService(repo, groupCore)
+	swapCore := core.NewSwapService(repo, groupCore, auditCore, events)
+	scoreCore := core.NewScoreService(repo)
+	noteCore := core.NewNotificationService(repo, log.Component("core.notifications"))
+	overdueCore := core.NewOverdueService(repo, groupCore, events, log.Component("core.overdue"))
+	webhookCore := core.NewWebhookService(repo, groupCore, auditCore, log.Component("core.webhooks"))
+	digestCore := core.NewDigestService(repo, log.Component("core.digests"))
+	feedCore := core.NewFeedService(repo)
+	exportCore := core.NewExportService(repo)
+	importCore := core.NewImportService(repo, auditCore, events, log.Component("core.import"))
+	templateCore := core.NewTemplateService(repo)
+	mailer := notify.NewMailer(cfg.Mail)
+
+	notifier := notify.NewNotifier(userCore, noteCore, log.Component("notify"),
+		notify.NewInboxChannel(noteCore),
+		notify.NewEmailChannel(mailer),
+		notify.NewWebhookChannel())
+	events.Subscribe(notifier)
+	webhooks := notify.NewGroupWebhooks(webhookCore, log.Component("notify.webhooks"))
+	events.Subscribe(webhooks)
+	hub := live.NewHub()
+	events.Subscribe(hub)
+	instrument(events, repo, log.Component("metrics"))
+
+	jobs := scheduler.NewScheduler(log.Component("scheduler"))
+	jobs.Every("overdue", 15*time.Minute, overdueCore.CheckOverdue)
+	jobs.Every("due-soon", 15*time.Minute, overdueCore.CheckDueSoon)
+	jobs.Every("notification-retention", 24*time.Hour, noteCore.Purge)
+	jobs.Every("digests", 15*time.Minute, notify.NewDigests(digestCore, mailer, log.Component("notify.digests")).Run)
+	jobs.Start()
 
-	store := sessions.NewStore(repo, []byte(os.Getenv("SESSION_KEY")))
-	auth := web.NewAuthService(userCore, store)
-	views := web.NewViewService(store, userCore, auth, groupCore)
+	store := sessions.NewStore(repo, []byte(cfg.SessionKey))
+	auth := web.NewAuthService(userCore, store, cfg.SessionName)
+	views := web.NewViewService(store, userCore, auth, groupCore, auditCore, availCore, swapCore, scoreCore, noteCore,
+		webhookCore, digestCore, feedCore, templateCore, site)
 	users := web.NewUserService(userCore, views)
-	groups := web.NewGroupService(groupCore, userCore, choreCore)
+	groups := web.NewGroupService(groupCore, userCore, choreCore, availCore, webhookCore, exportCore,
+		templateCore)
 	roles := web.NewRoleService(groupCore, roleCore, userCore, views)
 	chores := web.NewChoreService(choreCore, groupCore, userCore)
-	handler := web.Handler(web.NewServices(auth, views, groups, users, roles, chores))
-	log.Fatal(http.ListenAndServe(":8080", context.ClearHandler(handler)))
+	swaps := web.NewSwapService(swapCore, userCore)
+	notes := web.NewNotificationService(noteCore, digestCore, userCore)
+	feeds := web.NewFeedService(feedCore)
+	health := web.NewHealthService(repo.Db)
+	handler := web.Handler(web.NewServices(auth, views, groups, users, roles, chores, swaps, notes, feeds,
+		web.NewLiveService(hub, cfg.WriteTimeout), web.NewImportService(importCore, views), health),
+		site, cfg.MetricsToken, log.Component("web"))
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      context.ClearHandler(handler),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+	// Shutdown waits for open requests, so the event streams are ended rather than waited on
+	srv.RegisterOnShutdown(hub.Close)
+	// The jobs are stopped first since they publish events, then the deliveries they and the last
+	// requests queued are waited on
+	e = serve(srv, health, cfg.DrainPeriod, cfg.ShutdownTimeout, log, jobs.Stop, notifier.Wait, webhooks.Wait)
+	repo.Db.Close()
+	return e
 }
@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"chores-suck/core"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Channel delivers notifications to users over one medium. The user's preference for the channel
+// is passed along so channels can use the destination the user configured.
+type Channel interface {
+	Name() string
+	Send(n *core.Notification, pref *core.ChannelPref) error
+}
+
+// EmailChannel sends notifications as email to the user's address
+type EmailChannel struct {
+	mailer Mailer
+}
+
+func NewEmailChannel(m Mailer) *EmailChannel {
+	return &EmailChannel{mailer: m}
+}
+
+func (c *EmailChannel) Name() string {
+	return core.ChannelEmail
+}
+
+func (c *EmailChannel) Send(n *core.Notification, pref *core.ChannelPref) error {
+	if n.User.Email == "" {
+		return fmt.Errorf("user %v has no email address", n.User.ID)
+	}
+	subject := "Chores Suck"
+	if n.Group != nil {
+		subject = fmt.Sprintf("Chores Suck: %s", n.Group.Name)
+	}
+	return c.mailer.Send(&Mail{
+		To:      n.User.Email,
+		Subject: subject,
+		Text:    n.Message + "\r\n",
+	})
+}
+
+// WebhookChannel posts notifications as JSON to the URL the user configured. Like group webhooks
+// it only connects to public addresses.
+type WebhookChannel struct {
+	client *http.Client
+}
+
+func NewWebhookChannel() *WebhookChannel {
+	return &WebhookChannel{client: publicClient()}
+}
+
+func (c *WebhookChannel) Name() string {
+	return core.ChannelWebhook
+}
+
+type webhookPayload struct {
+	Type      core.EventType `json:"type"`
+	GroupID   uint64         `json:"group_id,omitempty"`
+	Group     string         `json:"group,omitempty"`
+	Message   string         `json:"message"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (c *WebhookChannel) Send(n *core.Notification, pref *core.ChannelPref) error {
+	if pref.Target == "" {
+		return fmt.Errorf("user %v has no webhook URL", n.User.ID)
+	}
+	p := webhookPayload{Type: n.Type, Message: n.Message, CreatedAt: n.CreatedAt}
+	if n.Group != nil {
+		p.GroupID = n.Group.ID
+		p.Group = n.Group.Name
+	}
+	body, e := json.Marshal(p)
+	if e != nil {
+		return e
+	}
+	res, e := c.client.Post(pref.Target, "application/json", bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return nil
+}
+
+// InboxChannel saves notifications to the user's in-app inbox
+type InboxChannel struct {
+	ns core.NotificationService
+}
+
+func NewInboxChannel(ns core.NotificationService) *InboxChannel {
+	return &InboxChannel{ns: ns}
+}
+
+func (c *InboxChannel) Name() string {
+	return core.ChannelInbox
+}
+
+func (c *InboxChannel) Send(n *core.Notification, pref *core.ChannelPref) error {
+	return c.ns.CreateNotification(n)
+}
@@ -0,0 +1,20 @@
+package notify
+
+import (
+	"chores-suck/core"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookChannelRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		t.Error("notification reached a loopback receiver")
+	}))
+	defer srv.Close()
+	n := &core.Notification{User: &core.User{ID: 1}, Type: core.EventAssigned, Message: "You were assigned Dishes"}
+	pref := &core.ChannelPref{Channel: core.ChannelWebhook, Enabled: true, Target: srv.URL}
+	if e := NewWebhookChannel().Send(n, pref); e == nil {
+		t.Fatal("expected the notification to a loopback address to fail")
+	}
+}
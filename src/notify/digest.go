@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"chores-suck/core"
+	"chores-suck/logging"
+	htmlTemplate "html/template"
+	"text/template"
+	"time"
+)
+
+var digestText = template.Must(template.New("digest").Parse(`Hi {{.User.Username}},
+
+Here is your {{.User.Digest.Frequency}} chore summary.
+{{with .Overdue}}
+Overdue:
+{{range .}}  - {{.Name}} ({{.Group.Name}}), was due {{.Assignment.DateDue.Format "Mon Jan 2"}}
+{{end}}{{end}}{{with .Upcoming}}
+Coming up:
+{{range .}}  - {{.Name}} ({{.Group.Name}}), due {{.Assignment.DateDue.Format "Mon Jan 2"}}
+{{end}}{{end}}{{with .Completed}}
+Completed:
+{{range .}}  - {{.Chore.Name}} ({{.Group.Name}}), {{.Points}} points
+{{end}}{{end}}
+`))
+
+var digestHTML = htmlTemplate.Must(htmlTemplate.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Roboto, sans-serif;">
+    <p>Hi {{.User.Username}},</p>
+    <p>Here is your {{.User.Digest.Frequency}} chore summary.</p>
+    {{with .Overdue}}
+    <h3>Overdue</h3>
+    <ul>
+        {{range .}}<li><strong>{{.Name}}</strong> ({{.Group.Name}}), was due {{.Assignment.DateDue.Format "Mon Jan 2"}}</li>{{end}}
+    </ul>
+    {{end}}
+    {{with .Upcoming}}
+    <h3>Coming up</h3>
+    <ul>
+        {{range .}}<li><strong>{{.Name}}</strong> ({{.Group.Name}}), due {{.Assignment.DateDue.Format "Mon Jan 2"}}</li>{{end}}
+    </ul>
+    {{end}}
+    {{with .Completed}}
+    <h3>Completed</h3>
+    <ul>
+        {{range .}}<li><strong>{{.Chore.Name}}</strong> ({{.Group.Name}}), {{.Points}} points</li>{{end}}
+    </ul>
+    {{end}}
+</body>
+</html>
+`))
+
+// Digests emails users the summaries they asked for. Run is meant to be run periodically by the
+// scheduler.
+type Digests struct {
+	ds     core.DigestService
+	mailer Mailer
+	log    *logging.Logger
+}
+
+func NewDigests(ds core.DigestService, m Mailer, l *logging.Logger) *Digests {
+	return &Digests{
+		ds:     ds,
+		mailer: m,
+		log:    l,
+	}
+}
+
+// Run sends every digest due at now. Users with nothing to report are skipped until their next digest.
+func (d *Digests) Run(now time.Time) {
+	users, e := d.ds.DueDigests(now)
+	if e != nil {
+		d.log.Error("Failed to get due digests", "err", e)
+		return
+	}
+	for i := range users {
+		u := &users[i]
+		digest, e := d.ds.Build(u, now)
+		if e != nil {
+			d.log.Error("Failed to build digest", "user", u.ID, "err", e)
+			continue
+		}
+		if !digest.IsEmpty() {
+			if e := d.send(digest); e != nil {
+				d.log.Warn("Failed to send digest", "user", u.ID, "err", e)
+				continue
+			}
+		}
+		d.ds.MarkSent(u, now)
+	}
+}
+
+func (d *Digests) send(digest *core.Digest) error {
+	// Show dates in the time zone the user asked to receive their digest in
+	loc := digest.User.Digest.Location()
+	for _, list := range [][]core.Chore{digest.Overdue, digest.Upcoming} {
+		for i := range list {
+			list[i].Assignment.DateDue = list[i].Assignment.DateDue.In(loc)
+		}
+	}
+	var text, html bytes.Buffer
+	if e := digestText.Execute(&text, digest); e != nil {
+		return e
+	}
+	if e := digestHTML.Execute(&html, digest); e != nil {
+		return e
+	}
+	subject := "Your daily chores"
+	if digest.User.Digest.Frequency == core.DigestWeekly {
+		subject = "Your week in chores"
+	}
+	return d.mailer.Send(&Mail{
+		To:      digest.User.Email,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	})
+}
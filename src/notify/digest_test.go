@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"chores-suck/core"
+	"chores-suck/logging"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// digestLog is a DigestService with fixed digests that records the users marked as sent
+type digestLog struct {
+	core.DigestService
+	users   []core.User
+	digests map[uint64]*core.Digest
+	sent    []uint64
+}
+
+func (l *digestLog) DueDigests(now time.Time) ([]core.User, error) {
+	return l.users, nil
+}
+
+func (l *digestLog) Build(user *core.User, now time.Time) (*core.Digest, error) {
+	if d, ok := l.digests[user.ID]; ok {
+		d.User = user
+		return d, nil
+	}
+	return nil, errors.New("connection refused")
+}
+
+func (l *digestLog) MarkSent(user *core.User, now time.Time) {
+	l.sent = append(l.sent, user.ID)
+}
+
+// outbox is a Mailer that keeps the mail it sends and refuses mail to refused
+type outbox struct {
+	mail    []*Mail
+	refused string
+}
+
+func (o *outbox) Send(m *Mail) error {
+	if m.To == o.refused {
+		return errors.New("mailbox unavailable")
+	}
+	o.mail = append(o.mail, m)
+	return nil
+}
+
+func TestDigestsRun(t *testing.T) {
+	due := time.Date(2024, 3, 7, 22, 59, 0, 0, time.UTC)
+	chores := []core.Chore{{Name: "Dishes", Group: &core.Group{Name: "Flat"},
+		Assignment: &core.ChoreAssignment{DateDue: due}}}
+	ds := &digestLog{
+		users: []core.User{
+			{ID: 1, Email: "daily@example.com", Digest: core.DigestPrefs{Frequency: core.DigestDaily}},
+			{ID: 2, Email: "weekly@example.com", Digest: core.DigestPrefs{Frequency: core.DigestWeekly,
+				TimeZone: "Asia/Tokyo"}},
+			{ID: 3, Email: "empty@example.com", Digest: core.DigestPrefs{Frequency: core.DigestDaily}},
+			{ID: 4, Email: "refused@example.com", Digest: core.DigestPrefs{Frequency: core.DigestDaily}},
+			{ID: 5, Email: "broken@example.com", Digest: core.DigestPrefs{Frequency: core.DigestDaily}},
+		},
+		digests: map[uint64]*core.Digest{
+			1: {Upcoming: chores},
+			2: {Overdue: []core.Chore{{Name: "Trash", Group: &core.Group{Name: "Flat"},
+				Assignment: &core.ChoreAssignment{DateDue: due}}}},
+			3: {},
+			4: {Upcoming: chores},
+		},
+	}
+	mail := &outbox{refused: "refused@example.com"}
+	NewDigests(ds, mail, logging.New(ioutil.Discard, logging.LevelError)).Run(due.Add(-24 * time.Hour))
+
+	// Empty digests are marked as sent without mail, failed ones are tried again next time
+	want := []uint64{1, 2, 3}
+	if len(ds.sent) != len(want) {
+		t.Fatalf("marked %v as sent, want %v", ds.sent, want)
+	}
+	for i := range want {
+		if ds.sent[i] != want[i] {
+			t.Fatalf("marked %v as sent, want %v", ds.sent, want)
+		}
+	}
+	if len(mail.mail) != 2 {
+		t.Fatalf("sent %d mails, want 2", len(mail.mail))
+	}
+	if m := mail.mail[0]; m.To != "daily@example.com" || m.Subject != "Your daily chores" {
+		t.Errorf("first mail to %s titled %q", m.To, m.Subject)
+	}
+	if m := mail.mail[1]; m.To != "weekly@example.com" || m.Subject != "Your week in chores" {
+		t.Errorf("second mail to %s titled %q", m.To, m.Subject)
+	}
+	// 22:59 UTC on Thursday is already Friday morning in Tokyo
+	if got := ds.digests[2].Overdue[0].Assignment.DateDue.Format("Mon Jan 2"); got != "Fri Mar 8" {
+		t.Errorf("weekly digest shows the due date as %s, want it in the user's zone", got)
+	}
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"bytes"
+	"chores-suck/config"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mail is an email message. HTML is optional, when set the message is sent with both a text and an
+// HTML part.
+type Mail struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(m *Mail) error
+}
+
+// NewMailer returns an SMTP mailer when an SMTP host is configured. Otherwise mail is written to
+// files in the mail directory, which is useful for development.
+func NewMailer(c config.Mail) Mailer {
+	from := c.From
+	if from == "" {
+		from = "chores-suck@localhost"
+	}
+	if host := c.SMTPHost; host != "" {
+		port := c.SMTPPort
+		if port == "" {
+			port = "587"
+		}
+		var auth smtp.Auth
+		if c.SMTPUser != "" {
+			auth = smtp.PlainAuth("", c.SMTPUser, c.SMTPPassword, host)
+		}
+		return &SMTPMailer{Addr: host + ":" + port, From: from, Auth: auth}
+	}
+	dir := c.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "chores-suck-mail")
+	}
+	return &FileMailer{Dir: dir, From: from}
+}
+
+// SMTPMailer sends mail through an SMTP server
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func (m *SMTPMailer) Send(mail *Mail) error {
+	msg, e := encode(m.From, mail)
+	if e != nil {
+		return e
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{mail.To}, msg)
+}
+
+// FileMailer writes each message to its own .eml file in Dir instead of sending it
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m *FileMailer) Send(mail *Mail) error {
+	msg, e := encode(m.From, mail)
+	if e != nil {
+		return e
+	}
+	if e := os.MkdirAll(m.Dir, 0700); e != nil {
+		return e
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_", "/", "_").Replace(mail.To))
+	return ioutil.WriteFile(filepath.Join(m.Dir, name), msg, 0600)
+}
+
+// encode builds the RFC 822 message for the mail
+func encode(from string, mail *Mail) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mail.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if mail.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		buf.WriteString(mail.Text)
+		return buf.Bytes(), nil
+	}
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+	parts := []struct {
+		kind string
+		body string
+	}{{"text/plain", mail.Text}, {"text/html", mail.HTML}}
+	for _, p := range parts {
+		pw, e := w.CreatePart(textproto.MIMEHeader{"Content-Type": {p.kind + "; charset=utf-8"}})
+		if e != nil {
+			return nil, e
+		}
+		if _, e := pw.Write([]byte(p.body)); e != nil {
+			return nil, e
+		}
+	}
+	if e := w.Close(); e != nil {
+		return nil, e
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"chores-suck/core"
+	"chores-suck/logging"
+	"sync"
+)
+
+// Notifier turns core events into notifications for each recipient and sends them over every
+// channel the recipient has enabled. It is subscribed to the core event publisher.
+type Notifier struct {
+	users    core.UserService
+	ns       core.NotificationService
+	channels []Channel
+	log      *logging.Logger
+	wg       sync.WaitGroup
+}
+
+func NewNotifier(u core.UserService, ns core.NotificationService, l *logging.Logger, channels ...Channel) *Notifier {
+	return &Notifier{
+		users:    u,
+		ns:       ns,
+		channels: channels,
+		log:      l,
+	}
+}
+
+// Publish delivers the event in the background so slow channels never hold up the request that
+// caused it
+func (n *Notifier) Publish(e core.Event) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.deliver(e)
+	}()
+}
+
+// Wait blocks until every event published so far has been delivered
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+func (n *Notifier) deliver(e core.Event) {
+	for _, r := range e.Recipients {
+		// Nobody needs to be told about something they did themselves
+		if r == nil || (e.Actor != nil && e.Actor.ID == r.ID) {
+			continue
+		}
+		user := core.User{ID: r.ID}
+		if err := n.users.GetUserByID(&user); err != nil {
+			n.log.Error("Failed to get user to notify", "user", r.ID, "err", err)
+			continue
+		}
+		if err := n.ns.GetChannelPrefs(&user); err != nil {
+			n.log.Error("Failed to get channel preferences", "user", r.ID, "err", err)
+			continue
+		}
+		note := core.Notification{
+			User:      &user,
+			Group:     e.Group,
+			Type:      e.Type,
+			Message:   e.Message,
+			CreatedAt: e.CreatedAt,
+		}
+		for _, c := range n.channels {
+			pref := user.Channel(c.Name())
+			if pref == nil || !pref.Enabled {
+				continue
+			}
+			if err := c.Send(&note, pref); err != nil {
+				n.log.Warn("Failed to notify user", "channel", c.Name(), "user", r.ID, "event", e.Type, "err", err)
+			}
+		}
+	}
+}
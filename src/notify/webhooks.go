@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"bytes"
+	"chores-suck/core"
+	"chores-suck/logging"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body keyed by the webhook's secret
+	SignatureHeader = "X-Chores-Signature"
+	// EventHeader carries the type of the event being delivered
+	EventHeader = "X-Chores-Event"
+)
+
+// webhookAttempts is how many times a delivery is tried before it is given up on. The wait before
+// each retry doubles from webhookBackoff.
+const (
+	webhookAttempts = 5
+	webhookBackoff  = 2 * time.Second
+)
+
+// GroupWebhooks posts group events to the webhooks the group's admins registered. Each delivery is
+// signed, retried with exponential backoff while the receiver fails and every attempt is logged.
+// Retries are held in memory, so deliveries in progress are lost if the server stops.
+type GroupWebhooks struct {
+	ws      core.WebhookService
+	client  *http.Client
+	backoff time.Duration
+	log     *logging.Logger
+	wg      sync.WaitGroup
+}
+
+func NewGroupWebhooks(ws core.WebhookService, l *logging.Logger) *GroupWebhooks {
+	return &GroupWebhooks{
+		ws:      ws,
+		client:  publicClient(),
+		backoff: webhookBackoff,
+		log:     l,
+	}
+}
+
+// errInternalAddress is returned when a webhook resolves to an address webhooks may not reach
+var errInternalAddress = errors.New("webhook address is private or local")
+
+// publicClient returns a client that only connects to public addresses. The address is checked
+// when connecting, so a webhook host that resolved to a public address when it was registered
+// cannot later be pointed at an internal service, and neither can a redirect.
+func publicClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !core.PublicIP(net.ParseIP(host)) {
+				return errInternalAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+}
+
+// GroupPayload is the JSON body posted to group webhooks
+type GroupPayload struct {
+	Type      core.EventType `json:"type"`
+	GroupID   uint64         `json:"group_id"`
+	Group     string         `json:"group"`
+	ChoreID   uint64         `json:"chore_id,omitempty"`
+	Chore     string         `json:"chore,omitempty"`
+	Actor     string         `json:"actor,omitempty"`
+	Message   string         `json:"message"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func (h *GroupWebhooks) Publish(e core.Event) {
+	if e.Group == nil {
+		return
+	}
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.dispatch(e)
+	}()
+}
+
+// dispatch starts a delivery to each of the group's webhooks subscribed to the event
+func (h *GroupWebhooks) dispatch(e core.Event) {
+	g := core.Group{ID: e.Group.ID}
+	if err := h.ws.GetWebhooks(&g); err != nil {
+		h.log.Error("Failed to get webhooks", "group", g.ID, "err", err)
+		return
+	}
+	var body []byte
+	for i := range g.Webhooks {
+		w := g.Webhooks[i]
+		if !w.Subscribes(e.Type) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(payload(e)); err != nil {
+				h.log.Error("Failed to encode webhook payload", "event", e.Type, "err", err)
+				return
+			}
+		}
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			h.deliver(&w, e.Type, body)
+		}()
+	}
+}
+
+// Wait blocks until every delivery started so far has succeeded or run out of attempts
+func (h *GroupWebhooks) Wait() {
+	h.wg.Wait()
+}
+
+func (h *GroupWebhooks) deliver(w *core.Webhook, t core.EventType, body []byte) {
+	wait := h.backoff
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		d := core.WebhookDelivery{Webhook: w, Event: t, Attempt: attempt, CreatedAt: time.Now().UTC()}
+		d.StatusCode, d.Error = h.post(w, t, body)
+		h.ws.RecordDelivery(&d)
+		if d.Succeeded() {
+			return
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	h.log.Warn("Giving up on webhook delivery", "webhook", w.ID, "event", t, "attempts", webhookAttempts)
+}
+
+// post sends the body to the webhook and returns the response status or the reason it failed
+func (h *GroupWebhooks) post(w *core.Webhook, t core.EventType, body []byte) (int, string) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(t))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(w.Secret, body))
+	res, err := h.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Sprintf("receiver responded with %s", res.Status)
+	}
+	return res.StatusCode, ""
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body keyed by the secret. Receivers compute the
+// same value to verify a delivery.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func payload(e core.Event) GroupPayload {
+	p := GroupPayload{
+		Type:      e.Type,
+		GroupID:   e.Group.ID,
+		Group:     e.Group.Name,
+		Message:   e.Message,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.Chore != nil {
+		p.ChoreID = e.Chore.ID
+		p.Chore = e.Chore.Name
+	}
+	if e.Actor != nil {
+		p.Actor = e.Actor.Username
+	}
+	return p
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"chores-suck/core"
+	"chores-suck/logging"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deliveryLog is a WebhookService that records the deliveries made to it
+type deliveryLog struct {
+	core.WebhookService
+	mu         sync.Mutex
+	deliveries []core.WebhookDelivery
+}
+
+func (l *deliveryLog) RecordDelivery(d *core.WebhookDelivery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deliveries = append(l.deliveries, *d)
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		{"secret", `{"type":"chore.completed"}`, "a9833b17ddd30999cf4651866d2ec7aee682112038a8bb9e87d6c4ef2028b66b"},
+		{"", "", "b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+		{"another secret", "body", "2602904f54fd945de88e0121c3d77b85021b65b031842589cd825b7621492ad2"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %q) = %s, want %s", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		success  bool
+	}{
+		{"first attempt succeeds", []int{200}, 1, true},
+		{"retries on server errors", []int{500, 503, 204}, 3, true},
+		{"stops on success", []int{502, 201, 500}, 2, true},
+		{"gives up after the last attempt", []int{500, 500, 500, 500, 500, 500}, webhookAttempts, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var calls int
+			var times []time.Time
+			body := []byte(`{"type":"chore.completed"}`)
+			srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				got, _ := ioutil.ReadAll(req.Body)
+				if sig := req.Header.Get(SignatureHeader); sig != "sha256="+Sign("secret", got) {
+					t.Errorf("signature %q does not match the body", sig)
+				}
+				if ev := req.Header.Get(EventHeader); ev != string(core.EventCompleted) {
+					t.Errorf("event header = %q", ev)
+				}
+				times = append(times, time.Now())
+				wr.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer srv.Close()
+
+			log := &deliveryLog{}
+			h := NewGroupWebhooks(log, logging.New(ioutil.Discard, logging.LevelError))
+			h.client = srv.Client()
+			h.backoff = time.Millisecond
+			h.deliver(&core.Webhook{ID: 1, URL: srv.URL, Secret: "secret"}, core.EventCompleted, body)
+
+			if calls != tt.attempts {
+				t.Fatalf("receiver got %d requests, want %d", calls, tt.attempts)
+			}
+			if len(log.deliveries) != tt.attempts {
+				t.Fatalf("recorded %d deliveries, want %d", len(log.deliveries), tt.attempts)
+			}
+			for i, d := range log.deliveries {
+				if d.Attempt != i+1 {
+					t.Errorf("delivery %d recorded as attempt %d", i+1, d.Attempt)
+				}
+				if d.StatusCode != tt.statuses[i] {
+					t.Errorf("delivery %d recorded status %d, want %d", i+1, d.StatusCode, tt.statuses[i])
+				}
+			}
+			if last := log.deliveries[len(log.deliveries)-1]; last.Succeeded() != tt.success {
+				t.Errorf("last delivery succeeded = %t, want %t", last.Succeeded(), tt.success)
+			}
+			// Each wait is at least double the one before it
+			for i := 1; i < len(times); i++ {
+				min := h.backoff << (i - 1)
+				if gap := times[i].Sub(times[i-1]); gap < min {
+					t.Errorf("retry %d came after %s, want at least %s", i, gap, min)
+				}
+			}
+		})
+	}
+}
+
+func TestPublicClientRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		t.Error("request reached a loopback receiver")
+	}))
+	defer srv.Close()
+	if res, e := publicClient().Get(srv.URL); e == nil {
+		res.Body.Close()
+		t.Fatal("expected the request to a loopback address to fail")
+	}
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"chores-suck/logging"
+	"sync"
+	"time"
+)
+
+// Job is a task run periodically by the scheduler. It is passed the time of the tick.
+type Job func(now time.Time)
+
+type entry struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+// Scheduler runs background jobs at fixed intervals until it is stopped
+type Scheduler struct {
+	jobs []entry
+	quit chan struct{}
+	log  *logging.Logger
+	wg   sync.WaitGroup
+}
+
+func NewScheduler(l *logging.Logger) *Scheduler {
+	return &Scheduler{
+		quit: make(chan struct{}),
+		log:  l,
+	}
+}
+
+// Every registers a job to run each interval once the scheduler is started
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	s.jobs = append(s.jobs, entry{name: name, interval: interval, job: job})
+}
+
+// Start runs every registered job once immediately and then on its interval
+func (s *Scheduler) Start() {
+	for _, e := range s.jobs {
+		s.wg.Add(1)
+		go s.run(e)
+	}
+}
+
+// Stop signals the jobs to stop and waits for any running job to finish
+func (s *Scheduler) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(e entry) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	s.exec(e, time.Now().UTC())
+	for {
+		select {
+		case t := <-ticker.C:
+			s.exec(e, t.UTC())
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// exec runs a job, recovering from panics so one failing job cannot take down the server
+func (s *Scheduler) exec(e entry, now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Error("Job panicked", "job", e.name, "panic", r)
+		}
+	}()
+	e.job(now)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"chores-suck/logging"
+	"chores-suck/web"
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve runs the server until it fails or the process receives SIGTERM or an interrupt. On a
+// signal readiness starts failing and the server keeps serving for the drain period, so load
+// balancers notice before it stops accepting connections. Open requests then get up to timeout to
+// finish, and whatever time is left goes to the stop functions, which are run in order once the
+// server is stopped so work queued by the last requests is not lost.
+func serve(srv *http.Server, health web.HealthService, drain time.Duration, timeout time.Duration,
+	l *logging.Logger, stop ...func()) error {
+	errs := make(chan error, 1)
+	go func() {
+		l.Info("Listening", "addr", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigs)
+	select {
+	case e := <-errs:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		finish(ctx, stop, l)
+		return e
+	case sig := <-sigs:
+		l.Info("Shutting down", "signal", sig, "drain", drain)
+	}
+
+	health.Drain()
+	time.Sleep(drain)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if e := srv.Shutdown(ctx); e != nil {
+		return e
+	}
+	l.Info("Server stopped")
+	finish(ctx, stop, l)
+	return nil
+}
+
+// finish runs the stop functions in order until they are done or ctx expires
+func finish(ctx context.Context, stop []func(), l *logging.Logger) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, f := range stop {
+			f()
+		}
+	}()
+	select {
+	case <-done:
+		l.Info("Background work finished")
+	case <-ctx.Done():
+		l.Warn("Gave up waiting for background work", "err", ctx.Err())
+	}
+}
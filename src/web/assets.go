@@ -0,0 +1,289 @@
+package web
+
+import (
+	"bytes"
+	"chores-suck/i18n"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// baseTemplates are parsed with every page, which fills in the layout's body
+var baseTemplates = []string{"layout.html", "navbar.html"}
+
+// Assets holds the page templates and the static files served under /public. Templates are parsed
+// once for each language and static files are served under names carrying a hash of their contents so browsers can
+// cache them for good. In development both are read from disk, templates are parsed again when a
+// file changes and static files are served under their own names without caching.
+type Assets struct {
+	pages  *pageTemplates
+	static *staticFiles
+}
+
+// NewAssets parses the templates and hashes the static files. With dev set the file systems are
+// expected to be directories on disk that are edited while the server runs.
+func NewAssets(templates fs.FS, public fs.FS, dev bool) (*Assets, error) {
+	static, e := newStaticFiles(public, dev)
+	if e != nil {
+		return nil, e
+	}
+	funcs := template.FuncMap{"asset": static.URL}
+	pages, e := newPageTemplates(templates, funcs, dev)
+	if e != nil {
+		return nil, e
+	}
+	return &Assets{pages: pages, static: static}, nil
+}
+
+// render executes the page in its layout in the locale's language, showing times in the zone
+// loc. The page is written only once it has rendered completely so a failing template never
+// leaves half a page behind.
+func (a *Assets) render(wr http.ResponseWriter, model interface{}, page string, l *i18n.Locale,
+	loc *time.Location) error {
+	p, e := a.pages.lookup(page, l)
+	if e != nil {
+		return e
+	}
+	// The zone differs between viewers, so the date functions are bound to it for this execution
+	// only on a copy of the page
+	t, e := p.Clone()
+	if e != nil {
+		return e
+	}
+	t.Funcs(dateFuncs(l, loc))
+	var buf bytes.Buffer
+	if e := t.ExecuteTemplate(&buf, "layout", model); e != nil {
+		return e
+	}
+	wr.Header().Set("Content-Type", "text/html; charset=utf-8")
+	wr.Header().Set("Content-Language", l.Tag)
+	_, e = buf.WriteTo(wr)
+	return e
+}
+
+// pageTemplates keeps each page parsed together with the base templates. The templates translate
+// their text with functions bound to a locale, so they are parsed once for every locale and
+// pages holds them by locale tag and then by page. Those are never executed, since a template can
+// only be cloned before it runs; each render executes a clone of its own.
+type pageTemplates struct {
+	fs     fs.FS
+	funcs  template.FuncMap
+	reload bool
+
+	mu       sync.Mutex
+	pages    map[string]map[string]*template.Template
+	modified time.Time
+}
+
+func newPageTemplates(fsys fs.FS, funcs template.FuncMap, reload bool) (*pageTemplates, error) {
+	t := &pageTemplates{fs: fsys, funcs: funcs, reload: reload}
+	modified, e := t.lastModified()
+	if e != nil {
+		return nil, e
+	}
+	if t.pages, e = t.parse(); e != nil {
+		return nil, e
+	}
+	t.modified = modified
+	return t, nil
+}
+
+// lookup returns the page in the locale's language. It must be cloned rather than executed. When
+// reloading, the templates are parsed again first if any of them changed since they were last
+// parsed.
+func (t *pageTemplates) lookup(page string, l *i18n.Locale) (*template.Template, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reload {
+		modified, e := t.lastModified()
+		if e != nil {
+			return nil, e
+		}
+		if modified.After(t.modified) {
+			pages, e := t.parse()
+			if e != nil {
+				return nil, e
+			}
+			t.pages, t.modified = pages, modified
+		}
+	}
+	p, ok := t.pages[l.Tag][page]
+	if !ok {
+		return nil, fmt.Errorf("no page template named %q", page)
+	}
+	return p, nil
+}
+
+// parse parses the templates for every locale
+func (t *pageTemplates) parse() (map[string]map[string]*template.Template, error) {
+	names, e := fs.Glob(t.fs, "*.html")
+	if e != nil {
+		return nil, e
+	}
+	locales := make(map[string]map[string]*template.Template)
+	for _, l := range i18n.Locales() {
+		if locales[l.Tag], e = t.parseLocale(names, l); e != nil {
+			return nil, e
+		}
+	}
+	return locales, nil
+}
+
+// parseLocale parses the base templates once and clones them for each page
+func (t *pageTemplates) parseLocale(names []string, l *i18n.Locale) (map[string]*template.Template, error) {
+	base, e := template.New("").Funcs(t.funcs).Funcs(localeFuncs(l)).ParseFS(t.fs, baseTemplates...)
+	if e != nil {
+		return nil, e
+	}
+	pages := make(map[string]*template.Template)
+	for _, name := range names {
+		if isBaseTemplate(name) {
+			continue
+		}
+		p, e := base.Clone()
+		if e != nil {
+			return nil, e
+		}
+		if pages[name], e = p.ParseFS(t.fs, name); e != nil {
+			return nil, e
+		}
+	}
+	return pages, nil
+}
+
+// lastModified returns the time the most recently changed template was modified
+func (t *pageTemplates) lastModified() (time.Time, error) {
+	var latest time.Time
+	entries, e := fs.ReadDir(t.fs, ".")
+	if e != nil {
+		return latest, e
+	}
+	for _, entry := range entries {
+		info, e := entry.Info()
+		if e != nil {
+			return latest, e
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// localeFuncs are the template functions that translate text and format dates. The dates are
+// formatted in UTC until render binds them to the viewer's zone.
+//
+//	{{ t "Due: %s" (date .DateDue) }}
+func localeFuncs(l *i18n.Locale) template.FuncMap {
+	funcs := dateFuncs(l, time.UTC)
+	funcs["t"] = l.T
+	funcs["lang"] = func() string { return l.Tag }
+	return funcs
+}
+
+// dateFuncs format times in the zone loc the way the locale writes dates
+func dateFuncs(l *i18n.Locale, loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		"date":     func(t time.Time) string { return l.Date(t.In(loc)) },
+		"datetime": func(t time.Time) string { return l.DateTime(t.In(loc)) },
+	}
+}
+
+func isBaseTemplate(name string) bool {
+	for _, b := range baseTemplates {
+		if name == b {
+			return true
+		}
+	}
+	return false
+}
+
+// staticFiles serves the files under /public. Each file is also served under a name with a hash
+// of its contents before the extension, such as css/main.3f9a0c1d.css, which can be cached
+// forever because its contents never change.
+type staticFiles struct {
+	fs  fs.FS
+	dev bool
+	// hashed maps each file's name to its hashed name and originals maps back
+	hashed    map[string]string
+	originals map[string]string
+}
+
+func newStaticFiles(fsys fs.FS, dev bool) (*staticFiles, error) {
+	s := &staticFiles{fs: fsys, dev: dev, hashed: make(map[string]string), originals: make(map[string]string)}
+	if dev {
+		return s, nil
+	}
+	e := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, e error) error {
+		if e != nil || d.IsDir() {
+			return e
+		}
+		f, e := fsys.Open(name)
+		if e != nil {
+			return e
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, e := io.Copy(h, f); e != nil {
+			return e
+		}
+		ext := path.Ext(name)
+		hashed := fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), hex.EncodeToString(h.Sum(nil))[:8], ext)
+		s.hashed[name] = hashed
+		s.originals[hashed] = name
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return s, nil
+}
+
+// URL returns the address of a static file for use in templates
+func (s *staticFiles) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := s.hashed[name]; ok {
+		return "/public/" + hashed
+	}
+	return "/public/" + name
+}
+
+func (s *staticFiles) serve(wr http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	name := strings.TrimPrefix(ps.ByName("filepath"), "/")
+	switch original, ok := s.originals[name]; {
+	case ok:
+		name = original
+		wr.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	case s.dev:
+		wr.Header().Set("Cache-Control", "no-cache")
+	default:
+		wr.Header().Set("Cache-Control", "public, max-age=300")
+	}
+	f, e := s.fs.Open(name)
+	if e != nil {
+		http.NotFound(wr, req)
+		return
+	}
+	defer f.Close()
+	info, e := f.Stat()
+	if e != nil || info.IsDir() {
+		http.NotFound(wr, req)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		handleError(internalError(fmt.Errorf("static file %s cannot seek", name)), wr, req)
+		return
+	}
+	http.ServeContent(wr, req, name, info.ModTime(), content)
+}
@@ -1,20 +1,13 @@
 package web
 
 import (
-	"log"
 	"net/http"
-	"os"
 
 	"chores-suck/core"
 
 	"github.com/gorilla/sessions"
 )
 
-var (
-	// Name of the session cookie that will be sent to clients
-	SessionName = os.Getenv("SESSION_NAME")
-)
-
 // Service provides functionality for authentication and authorization
 type AuthService interface {
 	Login(http.ResponseWriter, *http.Request)
@@ -25,28 +18,29 @@ type AuthService interface {
 type authService struct {
 	users core.UserService
 	store sessions.Store
+	// name is the name of the session cookie sent to clients
+	name string
 }
 
 // NewService creates and returns a new auth Service
-func NewAuthService(us core.UserService, ses sessions.Store) AuthService {
+func NewAuthService(us core.UserService, ses sessions.Store, name string) AuthService {
 	return &authService{
 		users: us,
 		store: ses,
+		name:  name,
 	}
 }
 
 func (s *authService) Login(wr http.ResponseWriter, req *http.Request) {
 	authorized := false
-	ses, e := s.store.Get(req, SessionName)
+	ses, e := s.store.Get(req, s.name)
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 		return
 	} else if !ses.IsNew {
-		log.Print("Session not new")
 		e = getSessionValue("auth", &authorized, ses)
 		if e != nil {
-			log.Print("Existing session failure during login")
-			handleError(internalError(e), wr)
+			handleError(internalError(e), wr, req)
 			return
 		}
 	}
@@ -57,18 +51,19 @@ func (s *authService) Login(wr http.ResponseWriter, req *http.Request) {
 		u := core.User{Username: n, Password: p}
 		e = s.checkCredentials(&u)
 		if e == ErrNotAuthorized {
-			SetFlash(wr, "genError", []byte("Invalid username/password"))
+			SetFlash(wr, "genError", []byte(tr(req, "Invalid username/password")))
 			http.Redirect(wr, req, "/login", 302)
 			return
 		} else if e != nil {
-			handleError(internalError(e), wr)
+			handleError(internalError(e), wr, req)
 		}
 		ses.Values["userid"] = u.ID
 		ses.Values["auth"] = true
 		if e = ses.Save(req, wr); e != nil {
-			handleError(internalError(e), wr)
+			handleError(internalError(e), wr, req)
 			return
 		}
+		setLocaleCookie(wr, u.Locale)
 	}
 	// TODO: Check cookie for a redirect url
 	http.Redirect(wr, req, "/dashboard", 302)
@@ -78,7 +73,6 @@ func (s *authService) checkCredentials(u *core.User) error {
 	p := u.Password
 	e := s.users.GetUserByName(u)
 	if e != nil {
-		log.Print(e)
 		return ErrNotAuthorized
 	}
 	r := checkpword(p, u.Password)
@@ -89,16 +83,16 @@ func (s *authService) checkCredentials(u *core.User) error {
 }
 
 func (s *authService) Logout(wr http.ResponseWriter, req *http.Request) {
-	ses, e := s.store.Get(req, SessionName)
+	ses, e := s.store.Get(req, s.name)
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 	} else if !ses.IsNew {
 		var authorized bool
 		e = getSessionValue("auth", &authorized, ses)
 		if e != nil {
-			handleError(internalError(e), wr)
+			handleError(internalError(e), wr, req)
 		} else if !authorized {
-			handleError(authError(ErrNotAuthorized), wr)
+			handleError(authError(ErrNotAuthorized), wr, req)
 		}
 
 		ses.Values["auth"] = false
@@ -110,32 +104,32 @@ func (s *authService) Logout(wr http.ResponseWriter, req *http.Request) {
 }
 
 func (s *authService) Authorize(wr http.ResponseWriter, req *http.Request) (uint64, error) {
-	ses, e := s.store.Get(req, SessionName)
+	ses, e := s.store.Get(req, s.name)
 	if e != nil {
-		log.Printf("Get session: %s", e.Error())
+		logFor(req).Error("Failed to get session", "err", e)
 		return 0, internalError(e)
 	} else if ses.IsNew {
-		log.Print("New session not authorized")
+		logFor(req).Debug("New session not authorized")
 		return 0, authError(ErrNotAuthorized)
 	}
 
 	var authorized bool
 	if e = getSessionValue("auth", &authorized, ses); e != nil {
-		log.Printf("Auth value: %s", e.Error())
+		logFor(req).Error("Failed to read session auth value", "err", e)
 		return 0, internalError(e)
 	}
 	if !authorized {
-		log.Print("session not authorized")
+		logFor(req).Debug("Session not authorized")
 		return 0, authError(ErrNotAuthorized)
 	}
 
 	var uid uint64
 	if e = getSessionValue("userid", &uid, ses); e != nil {
-		log.Printf("UserID value: %s", e.Error())
+		logFor(req).Error("Failed to read session user id", "err", e)
 		return 0, internalError(e)
 	}
 	if uid == 0 {
-		log.Printf("Invalid user id: %v", uid)
+		logFor(req).Debug("Session has no user id")
 		return 0, authError(ErrNotAuthorized)
 	}
 
@@ -147,7 +141,7 @@ func (s *authService) Authorize(wr http.ResponseWriter, req *http.Request) (uint
 /////////////////////////////////////////////////////////////////
 
 func (s *authService) isLoggedIn(r *http.Request) bool {
-	ses, e := s.store.Get(r, SessionName)
+	ses, e := s.store.Get(r, s.name)
 	if e != nil {
 		return false
 	}
@@ -3,7 +3,6 @@ package web
 import (
 	"chores-suck/core"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 
@@ -13,7 +12,9 @@ import (
 type ChoreService interface {
 	Create(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
 	Update(http.ResponseWriter, *http.Request, *core.User, *core.Chore)
+	Complete(http.ResponseWriter, *http.Request, *core.User, *core.Chore)
 	ChoreMW(handler func(http.ResponseWriter, *http.Request, *core.User, *core.Chore)) authParamHandle
+	ChoreView(handler func(http.ResponseWriter, *http.Request, *core.User, *core.Chore)) authParamHandle
 }
 
 type choreService struct {
@@ -41,15 +42,21 @@ func (s *choreService) Create(wr http.ResponseWriter, req *http.Request,
 	choreName := req.PostFormValue("chore_name")
 	choreDesc := req.PostFormValue("chore_desc")
 	choreTime, e := strconv.Atoi(req.PostFormValue("chore_dur"))
-	chore := core.Chore{Group: group, Name: choreName, Description: choreDesc, Duration: choreTime}
 	if e != nil {
 		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	chorePoints, e := parsePoints(req.PostFormValue("chore_points"))
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	chore := core.Chore{Group: group, Name: choreName, Description: choreDesc, Duration: choreTime, Points: chorePoints,
+		Recurrence: core.Recurrence(req.PostFormValue("chore_recurrence"))}
 	if e := validateGroupName(choreName); e != nil {
-		msg = e.Error()
-	} else if e := s.cs.Create(&chore); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
+	} else if e := s.cs.Create(&chore, user); e != nil {
+		msg = errorMessage(req, e)
 	}
 	if msg != "" {
 		SetFlash(wr, "genError", []byte(msg))
@@ -65,14 +72,18 @@ func (s *choreService) Update(wr http.ResponseWriter, req *http.Request, us *cor
 		s.update(wr, req, us, ch)
 	} else if req.PostFormValue("submit_2") != "" {
 		s.delete(wr, req, us, ch)
+	} else if req.PostFormValue("submit_3") != "" {
+		s.addConstraint(wr, req, us, ch)
+	} else if req.PostFormValue("submit_4") != "" {
+		s.removeConstraint(wr, req, us, ch)
 	} else {
 		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 	}
 }
 
 func (s *choreService) delete(wr http.ResponseWriter, req *http.Request, us *core.User, ch *core.Chore) {
-	if e := s.cs.Delete(ch); e != nil {
-		SetFlash(wr, "genError", []byte(e.Error()))
+	if e := s.cs.Delete(ch, us); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
 		http.Redirect(wr, req, fmt.Sprintf("/chores/update/%v", ch.ID), 302)
 		return
 	}
@@ -84,21 +95,86 @@ func (s *choreService) update(wr http.ResponseWriter, req *http.Request, us *cor
 	choreName := req.PostFormValue("chore_name")
 	choreDesc := req.PostFormValue("chore_desc")
 	choreDur, e := strconv.Atoi(req.PostFormValue("chore_dur"))
-	newChore := core.Chore{ID: ch.ID, Name: choreName, Description: choreDesc, Duration: choreDur}
 	if e != nil {
 		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	chorePoints, e := parsePoints(req.PostFormValue("chore_points"))
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	newChore := core.Chore{ID: ch.ID, Name: choreName, Description: choreDesc, Duration: choreDur, Points: chorePoints,
+		Recurrence: core.Recurrence(req.PostFormValue("chore_recurrence"))}
 	if e := validateGroupName(choreName); e != nil {
-		msg = e.Error()
-	} else if e := s.cs.Update(ch, &newChore); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
+	} else if e := s.cs.Update(ch, &newChore, us); e != nil {
+		msg = errorMessage(req, e)
 	}
 	if msg != "" {
 		SetFlash(wr, "genError", []byte(msg))
 	}
 	http.Redirect(wr, req, fmt.Sprintf("/chores/update/%v", ch.ID), 302)
 }
+func (s *choreService) Complete(wr http.ResponseWriter, req *http.Request, us *core.User, ch *core.Chore) {
+	if e := s.cs.Complete(ch, us); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, "/dashboard", 302)
+}
+
+// parsePoints reads an optional points override, an empty value means no override
+func parsePoints(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func (s *choreService) addConstraint(wr http.ResponseWriter, req *http.Request, us *core.User, ch *core.Chore) {
+	var msg string
+	kind, e := strconv.Atoi(req.PostFormValue("constraint_kind"))
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	con := core.ChoreConstraint{Kind: core.ConstraintKind(kind)}
+	if con.IsRole() {
+		roleID, e := strconv.ParseUint(req.PostFormValue("role_id"), 10, 64)
+		if e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		con.Role = &core.Role{ID: roleID}
+	} else {
+		userID, e := strconv.ParseUint(req.PostFormValue("user_id"), 10, 64)
+		if e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		con.User = &core.User{ID: userID}
+	}
+	if e := s.cs.AddConstraint(ch, &con, us); e != nil {
+		msg = errorMessage(req, e)
+	}
+	if msg != "" {
+		SetFlash(wr, "genError", []byte(msg))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/chores/update/%v", ch.ID), 302)
+}
+
+func (s *choreService) removeConstraint(wr http.ResponseWriter, req *http.Request, us *core.User, ch *core.Chore) {
+	id, e := strconv.ParseUint(req.PostFormValue("constraint_id"), 10, 64)
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if e := s.cs.RemoveConstraint(ch, id, us); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/chores/update/%v", ch.ID), 302)
+}
+
 func (s *choreService) ChoreMW(handler func(http.ResponseWriter, *http.Request, *core.User, *core.Chore)) authParamHandle {
 	return func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, userID uint64) {
 		//Get Chore
@@ -109,9 +185,7 @@ func (s *choreService) ChoreMW(handler func(http.ResponseWriter, *http.Request,
 		}
 		chore := core.Chore{ID: choreID}
 		if e = s.cs.GetChore(&chore); e != nil {
-			//Internal server error
-			log.Printf("ChoreMW: Failed to grab chore: %s", e.Error())
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(e, wr, req)
 			return
 		} else if chore.Name == "" {
 			//Not found
@@ -120,43 +194,77 @@ func (s *choreService) ChoreMW(handler func(http.ResponseWriter, *http.Request,
 		}
 		//Get Group
 		if e = s.gs.GetGroup(chore.Group); e != nil {
-			//internal server error
-			log.Printf("ChoreMW: Failed to get group: %s", e.Error())
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(e, wr, req)
 			return
 		}
 		//Get Group memberships
 		if e = s.gs.GetMemberships(chore.Group); e != nil {
-			//internal server error
-			log.Printf("ChoreMW: Failed to get members: %s", e.Error())
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(e, wr, req)
 			return
 		}
 		//Get user membership
 		mem := chore.Group.FindMember(userID)
 		if mem == nil {
-			//Unauthorized
-			http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handleError(errNotMember, wr, req)
 			return
 		}
 		if e = s.gs.GetRoles(mem); e != nil {
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(internalError(e), wr, req)
 			return
 		}
 		//Check if member can edit chores
 		if !mem.SuperRole.Can(core.EditChores) {
-			//Unauthorized
-			http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handleError(&core.ForbiddenError{Msg: "You do not have permission to edit chores"}, wr, req)
 			return
 		}
 		//Get User
 		user := core.User{ID: userID}
 		if e = s.us.GetUserByID(&user); e != nil {
-			//Internal server error
-			log.Printf("ChoreMW: Failed to get user: %s", e.Error())
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(e, wr, req)
 			return
 		}
 		handler(wr, req, &user, &chore)
 	}
 }
+
+// ChoreView loads the chore named in the route along with its group's members and chores. Unlike
+// ChoreMW any member of the group may continue, for actions members take on their own chores.
+func (s *choreService) ChoreView(handler func(http.ResponseWriter, *http.Request, *core.User, *core.Chore)) authParamHandle {
+	return func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, userID uint64) {
+		choreID, e := strconv.ParseUint(ps.ByName("choreID"), 10, 64)
+		if e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		chore := core.Chore{ID: choreID}
+		if e = s.cs.GetChore(&chore); e != nil {
+			handleError(e, wr, req)
+			return
+		} else if chore.Name == "" {
+			http.Error(wr, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if e = s.gs.GetGroup(chore.Group); e != nil {
+			handleError(e, wr, req)
+			return
+		}
+		if e = s.gs.GetMemberships(chore.Group); e != nil {
+			handleError(e, wr, req)
+			return
+		}
+		if mem := chore.Group.FindMember(userID); mem == nil {
+			handleError(errNotMember, wr, req)
+			return
+		}
+		if e = s.gs.GetChores(chore.Group); e != nil {
+			handleError(e, wr, req)
+			return
+		}
+		user := core.User{ID: userID}
+		if e = s.us.GetUserByID(&user); e != nil {
+			handleError(e, wr, req)
+			return
+		}
+		handler(wr, req, &user, chore.Group.FindChore(chore.ID))
+	}
+}
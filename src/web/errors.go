@@ -1,8 +1,13 @@
 package web
 
 import (
+	"chores-suck/core"
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
+
+	storageErr "chores-suck/core/storage/errors"
 )
 
 var (
@@ -20,6 +25,9 @@ var (
 
 	// ErrValueName occurs when attempting to access an invalid session value
 	ErrValueName = errors.New("invalid session value name")
+
+	// errNotMember answers requests for a group from users who are not in it
+	errNotMember = &core.ForbiddenError{Msg: "You are not a member of this group"}
 )
 
 // Error Represents an http service error. Provides methods for the HTTP status code and embeds the
@@ -42,6 +50,10 @@ func (e StatusError) Error() string {
 	return e.Err.Error()
 }
 
+func (e StatusError) Unwrap() error {
+	return e.Err
+}
+
 // Status returns the HTTP status code for the error
 func (e StatusError) Status() int {
 	return e.Code
@@ -55,13 +67,86 @@ func authError(e error) StatusError {
 	return StatusError{Code: http.StatusUnauthorized, Err: e}
 }
 
-func handleError(err error, wr http.ResponseWriter) {
-	if err != nil {
-		switch e := err.(type) {
-		case HttpError:
-			http.Error(wr, e.Error(), e.Status())
-		default:
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		}
+// errorStatus maps an error to the status it is answered with. Errors from core say what kind of
+// failure they are. Errors from the web layer carry their status, and anything else is a server
+// error.
+func errorStatus(e error) int {
+	var (
+		nf *core.NotFoundError
+		cf *core.ConflictError
+		fb *core.ForbiddenError
+		ve *core.ValidationError
+		ie *core.InternalError
+		he HttpError
+	)
+	switch {
+	case errors.As(e, &ie):
+		return http.StatusInternalServerError
+	case errors.As(e, &nf), errors.Is(e, storageErr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(e, &cf):
+		return http.StatusConflict
+	case errors.As(e, &fb):
+		return http.StatusForbidden
+	case errors.As(e, &ve):
+		return http.StatusBadRequest
+	case errors.As(e, &he):
+		return he.Status()
+	}
+	return http.StatusInternalServerError
+}
+
+// errorMessage returns the message shown to the user for an error, such as in a flash message,
+// in the language of the request. Server errors only say that something went wrong, so their
+// cause is logged here under the request's id.
+func errorMessage(req *http.Request, e error) string {
+	if errorStatus(e) >= 500 {
+		logFor(req).Error("Request failed", "err", errorDetail(e))
+		return tr(req, msgInternal)
 	}
+	return tr(req, e.Error())
+}
+
+// msgInternal is the message of every server error, the same one core gives its internal errors
+var msgInternal = (&core.InternalError{}).Error()
+
+// errorBody is the JSON body of an error response
+type errorBody struct {
+	Error errorJSON `json:"error"`
+}
+
+type errorJSON struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	// Field is the form field a validation error is about
+	Field string `json:"field,omitempty"`
+}
+
+// handleError answers the request with the error's status and message, as JSON when the client
+// asks for it. Server errors are logged with their cause under the request's id.
+func handleError(err error, wr http.ResponseWriter, req *http.Request) {
+	if err == nil {
+		return
+	}
+	status := errorStatus(err)
+	msg := errorMessage(req, err)
+	if !wantsJSON(req) {
+		http.Error(wr, msg, status)
+		return
+	}
+	body := errorBody{Error: errorJSON{Status: status, Message: msg}}
+	var ve *core.ValidationError
+	if errors.As(err, &ve) {
+		body.Error.Field = ve.Field
+	}
+	wr.Header().Set("Content-Type", "application/json; charset=utf-8")
+	wr.Header().Set("X-Content-Type-Options", "nosniff")
+	wr.WriteHeader(status)
+	json.NewEncoder(wr).Encode(body)
+}
+
+// wantsJSON reports whether the client prefers JSON to the plain text error pages
+func wantsJSON(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "application/json") ||
+		strings.HasPrefix(req.Header.Get("Content-Type"), "application/json")
 }
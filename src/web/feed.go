@@ -0,0 +1,74 @@
+package web
+
+import (
+	"chores-suck/core"
+	"chores-suck/ical"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type FeedService interface {
+	// Serve writes the calendar feed named by the token in the URL. Calendar applications cannot
+	// log in, so the token is the only credential.
+	Serve(wr http.ResponseWriter, req *http.Request, ps httprouter.Params)
+	Update(wr http.ResponseWriter, req *http.Request, uid uint64)
+}
+
+type feedService struct {
+	fs core.FeedService
+}
+
+func NewFeedService(f core.FeedService) FeedService {
+	return &feedService{
+		fs: f,
+	}
+}
+
+func (s *feedService) Serve(wr http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	token := strings.TrimSuffix(ps.ByName("token"), ".ics")
+	feed, e := s.fs.Feed(token)
+	if e != nil && errorStatus(e) >= 500 {
+		handleError(e, wr, req)
+		return
+	} else if e != nil {
+		// Revoked tokens and feeds of groups the user left look the same as tokens that never existed
+		http.Error(wr, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	wr.Header().Set("Content-Type", ical.ContentType)
+	wr.Header().Set("Cache-Control", "no-cache")
+	if e := ical.Encode(wr, feed, time.Now().UTC()); e != nil {
+		logFor(req).Warn("Failed to write feed", "err", e)
+	}
+}
+
+func (s *feedService) Update(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	if submit := req.PostFormValue("submit_1"); submit != "" {
+		var group *core.Group
+		if v := req.PostFormValue("group_id"); v != "" {
+			id, e := strconv.ParseUint(v, 10, 64)
+			if e != nil {
+				http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			group = &core.Group{ID: id}
+		}
+		if _, e := s.fs.Create(&user, group); e != nil {
+			SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+		}
+	} else if submit := req.PostFormValue("submit_2"); submit != "" {
+		t := core.FeedToken{Token: req.PostFormValue("token")}
+		if e := s.fs.Revoke(&t, &user); e != nil {
+			SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+		}
+	} else {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(wr, req, "/calendar", 302)
+}
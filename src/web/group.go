@@ -4,13 +4,17 @@ import (
 	"chores-suck/core"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// dateLayout is the format of dates submitted by date inputs
+const dateLayout = "2006-01-02"
+
 var (
 	// ErrInvalidFormData occurs when invalid form data is received when submitting a post request for groups
 	ErrInvalidFormData = errors.New("Invalid form input")
@@ -18,8 +22,12 @@ var (
 
 type GroupService interface {
 	CreateGroup(wr http.ResponseWriter, req *http.Request, uid uint64)
+	DeleteTemplate(wr http.ResponseWriter, req *http.Request, uid uint64)
 	UpdateGroup(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
 	AddRole(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
+	UpdateAvailability(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
+	UpdateWebhooks(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
+	Export(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
 	GroupAccess(handler func(wr http.ResponseWriter, req *http.Request,
 		ps httprouter.Params, user *core.User, group *core.Group)) authParamHandle
 	GroupView(handler func(wr http.ResponseWriter, req *http.Request,
@@ -30,13 +38,22 @@ type groupService struct {
 	gs core.GroupService
 	us core.UserService
 	cs core.ChoreService
+	as core.AvailabilityService
+	ws core.WebhookService
+	xs core.ExportService
+	ts core.TemplateService
 }
 
-func NewGroupService(g core.GroupService, u core.UserService, c core.ChoreService) GroupService {
+func NewGroupService(g core.GroupService, u core.UserService, c core.ChoreService, a core.AvailabilityService,
+	w core.WebhookService, x core.ExportService, t core.TemplateService) GroupService {
 	return &groupService{
 		gs: g,
 		us: u,
 		cs: c,
+		as: a,
+		ws: w,
+		xs: x,
+		ts: t,
 	}
 }
 
@@ -45,22 +62,38 @@ func (s *groupService) CreateGroup(wr http.ResponseWriter, req *http.Request, ui
 	user := core.User{ID: uid}
 	e := s.us.GetUserByID(&user)
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 		return
 	}
 	if e = validateGroupName(groupName); e != nil {
-		SetFlash(wr, "nameError", []byte(e.Error()))
+		SetFlash(wr, "nameError", []byte(errorMessage(req, e)))
 		http.Redirect(wr, req, "/groups/create", 302)
 		return
 	}
-	e = s.gs.CreateGroup(groupName, &user)
+	var tmpl *core.GroupTemplate
+	if key := req.PostFormValue("template"); key != "" {
+		if tmpl, e = s.ts.GetTemplate(key, &user); e != nil {
+			SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+			http.Redirect(wr, req, "/groups/create", 302)
+			return
+		}
+	}
+	e = s.gs.CreateGroup(groupName, &user, tmpl)
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 		return
 	}
 	http.Redirect(wr, req, "/dashboard", 302)
 }
 
+func (s *groupService) DeleteTemplate(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	if e := s.ts.Delete(req.PostFormValue("template"), &user); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, "/groups/create", 302)
+}
+
 func (s *groupService) UpdateGroup(wr http.ResponseWriter, req *http.Request,
 	ps httprouter.Params, user *core.User, group *core.Group) {
 	if submit := req.PostFormValue("submit_1"); submit != "" {
@@ -73,8 +106,12 @@ func (s *groupService) UpdateGroup(wr http.ResponseWriter, req *http.Request,
 		s.random(wr, req, ps, user, group)
 	} else if submit := req.PostFormValue("submit_5"); submit != "" {
 		s.rotate(wr, req, ps, user, group)
+	} else if submit := req.PostFormValue("submit_6"); submit != "" {
+		s.updateSettings(wr, req, user, group)
+	} else if submit := req.PostFormValue("submit_7"); submit != "" {
+		s.saveTemplate(wr, req, user, group)
 	} else {
-		log.Print("Failed to find submit value")
+		logFor(req).Warn("UpdateGroup: Failed to find submit value", "group", group.ID)
 	}
 }
 
@@ -86,9 +123,10 @@ func (s *groupService) AddRole(wr http.ResponseWriter, req *http.Request,
 	editChores := req.PostFormValue("editchores")
 	editMembers := req.PostFormValue("editmembers")
 	editRoles := req.PostFormValue("editroles")
+	viewAudit := req.PostFormValue("viewaudit")
 	getsChores := req.PostFormValue("getsChores")
 	if e := validateGroupName(name); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
 	}
 	if msg == "" {
 		role := core.Role{Name: name, GetsChores: getsChores == "true", Group: group}
@@ -96,8 +134,9 @@ func (s *groupService) AddRole(wr http.ResponseWriter, req *http.Request,
 		role.Set(core.EditChores, editChores == "true")
 		role.Set(core.EditMembers, editMembers == "true")
 		role.Set(core.EditRoles, editRoles == "true")
+		role.Set(core.ViewAudit, viewAudit == "true")
 		if e := s.gs.AddRole(&role, user); e != nil {
-			msg = e.Error()
+			msg = errorMessage(req, e)
 		}
 	}
 	if msg != "" {
@@ -110,30 +149,185 @@ func (s *groupService) AddRole(wr http.ResponseWriter, req *http.Request,
 	}
 }
 
+func (s *groupService) UpdateAvailability(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	if submit := req.PostFormValue("submit_1"); submit != "" {
+		s.addAbsence(wr, req, user, group)
+	} else if submit := req.PostFormValue("submit_2"); submit != "" {
+		s.delAbsence(wr, req, user, group)
+	} else {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	}
+}
+
+func (s *groupService) addAbsence(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group) {
+	var msg string
+	// Absences start and end at midnight where the group keeps its chores
+	start, e1 := time.ParseInLocation(dateLayout, req.PostFormValue("start"), group.Location())
+	end, e2 := time.ParseInLocation(dateLayout, req.PostFormValue("end"), group.Location())
+	if e1 != nil || e2 != nil {
+		msg = tr(req, "Invalid date")
+	} else {
+		// The end date is inclusive, so the absence lasts until the start of the following day
+		a := core.Absence{
+			User:       user,
+			Group:      group,
+			Start:      start,
+			End:        end.AddDate(0, 0, 1),
+			Compensate: req.PostFormValue("compensate") == "true",
+		}
+		if e := s.as.AddAbsence(&a, user); e != nil {
+			msg = errorMessage(req, e)
+		}
+	}
+	if msg != "" {
+		SetFlash(wr, "genError", []byte(msg))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/groups/away/%v", group.ID), 302)
+}
+
+func (s *groupService) delAbsence(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group) {
+	id, e := strconv.ParseUint(req.PostFormValue("absence_id"), 10, 64)
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	a := core.Absence{ID: id, User: user, Group: group}
+	if e := s.as.RemoveAbsence(&a, user); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/groups/away/%v", group.ID), 302)
+}
+
+func (s *groupService) UpdateWebhooks(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	var msg string
+	if submit := req.PostFormValue("submit_1"); submit != "" {
+		if e := req.ParseForm(); e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		w := core.Webhook{Group: group, URL: strings.TrimSpace(req.PostFormValue("url"))}
+		for _, t := range req.PostForm["events"] {
+			w.Events = append(w.Events, core.EventType(t))
+		}
+		if e := s.ws.Create(&w, user); e != nil {
+			msg = errorMessage(req, e)
+		}
+	} else if submit := req.PostFormValue("submit_2"); submit != "" {
+		id, e := strconv.ParseUint(req.PostFormValue("webhook_id"), 10, 64)
+		if e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		w := core.Webhook{ID: id}
+		if e := s.ws.GetWebhook(&w); e != nil || w.Group.ID != group.ID {
+			http.Error(wr, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		w.Group = group
+		if e := s.ws.Delete(&w, user); e != nil {
+			msg = errorMessage(req, e)
+		}
+	} else {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if msg != "" {
+		SetFlash(wr, "genError", []byte(msg))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/groups/webhooks/%v", group.ID), 302)
+}
+
+// Export downloads the group's data. The format query parameter chooses between the full JSON
+// document (the default) and the "chores" and "history" CSV files.
+func (s *groupService) Export(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	format := req.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "chores" && format != "history" {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	x, e := s.xs.Export(group, user)
+	if e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+		http.Redirect(wr, req, fmt.Sprintf("/groups/update/%v", group.ID), 302)
+		return
+	}
+	name := fmt.Sprintf("group-%v-%s", group.ID, x.ExportedAt.Format(dateLayout))
+	switch format {
+	case "chores":
+		wr.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		wr.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-chores.csv"`, name))
+		e = x.WriteChoresCSV(wr)
+	case "history":
+		wr.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		wr.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-history.csv"`, name))
+		e = x.WriteHistoryCSV(wr)
+	default:
+		wr.Header().Set("Content-Type", "application/json")
+		wr.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+		e = x.WriteJSON(wr)
+	}
+	if e != nil {
+		logFor(req).Warn("Failed to write export", "group", group.ID, "err", e)
+	}
+}
+
 func (s *groupService) updateName(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group) {
 	groupName := req.PostFormValue("groupname")
 	if e := validateGroupName(groupName); e != nil {
-		SetFlash(wr, "nameError", []byte(e.Error()))
+		SetFlash(wr, "nameError", []byte(errorMessage(req, e)))
 	} else {
 		group.Name = groupName
 		e := s.gs.UpdateGroup(group, user)
 		if e != nil {
-			SetFlash(wr, "genError", []byte("An unexpected error occurred"))
+			SetFlash(wr, "genError", []byte(errorMessage(req, e)))
 		}
 	}
 	http.Redirect(wr, req, fmt.Sprintf("/groups/update/%v", group.ID), 302)
 }
 
+func (s *groupService) saveTemplate(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group) {
+	if e := s.ts.Save(group, req.PostFormValue("template_name"), user); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/groups/update/%v", group.ID), 302)
+}
+
+func (s *groupService) updateSettings(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group) {
+	var msg string
+	remind, e1 := strconv.Atoi(req.PostFormValue("remind_after"))
+	escalate, e2 := strconv.Atoi(req.PostFormValue("escalate_after"))
+	if e1 != nil || e2 != nil {
+		msg = tr(req, "Delays must be a whole number of hours")
+	} else {
+		group.Settings = core.GroupSettings{
+			MissedPolicy:  core.MissedPolicy(req.PostFormValue("missed_policy")),
+			RemindAfter:   remind,
+			EscalateAfter: escalate,
+			TimeZone:      strings.TrimSpace(req.PostFormValue("time_zone")),
+		}
+		if e := s.gs.UpdateGroup(group, user); e != nil {
+			msg = errorMessage(req, e)
+		}
+	}
+	if msg != "" {
+		SetFlash(wr, "settingsError", []byte(msg))
+	}
+	http.Redirect(wr, req, fmt.Sprintf("/groups/update/%v", group.ID), 302)
+}
+
 func (s *groupService) addMember(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group) {
 	msg := ""
 	uname := req.PostFormValue("username")
 	userNew := core.User{Username: uname}
 	if e := s.us.GetUserByName(&userNew); e != nil {
-		msg = "User not found"
+		msg = tr(req, "User not found")
 	} else {
 		memNew := core.Membership{User: &userNew, Group: group}
 		if e := s.gs.AddMember(&memNew, user); e != nil {
-			msg = e.Error()
+			msg = errorMessage(req, e)
 		}
 	}
 	if msg != "" {
@@ -147,12 +341,12 @@ func (s *groupService) delMember(wr http.ResponseWriter, req *http.Request, ps h
 	userID, e := strconv.ParseUint(req.PostFormValue("user_id"), 10, 64)
 	msg := ""
 	if e != nil {
-		msg = "Invalid request"
+		msg = tr(req, "Invalid request")
 	} else {
 		delUser := core.User{ID: userID}
 		delMem := core.Membership{User: &delUser, Group: group}
 		if e = s.gs.DeleteMember(&delMem, user); e != nil {
-			msg = e.Error()
+			msg = errorMessage(req, e)
 		}
 	}
 	if msg != "" {
@@ -164,9 +358,9 @@ func (s *groupService) delMember(wr http.ResponseWriter, req *http.Request, ps h
 func (s *groupService) random(wr http.ResponseWriter, req *http.Request, _ httprouter.Params, u *core.User, g *core.Group) {
 	var msg string
 	if e := s.gs.GetChores(g); e != nil {
-		msg = e.Error()
-	} else if e := s.cs.Randomize(g); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
+	} else if e := s.cs.Randomize(g, u); e != nil {
+		msg = errorMessage(req, e)
 	}
 	if msg != "" {
 		SetFlash(wr, "choreError", []byte(msg))
@@ -177,9 +371,9 @@ func (s *groupService) random(wr http.ResponseWriter, req *http.Request, _ httpr
 func (s *groupService) rotate(wr http.ResponseWriter, req *http.Request, _ httprouter.Params, u *core.User, g *core.Group) {
 	var msg string
 	if e := s.gs.GetChores(g); e != nil {
-		msg = e.Error()
-	} else if e := s.cs.Rotate(g); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
+	} else if e := s.cs.Rotate(g, u); e != nil {
+		msg = errorMessage(req, e)
 	}
 	if msg != "" {
 		SetFlash(wr, "choreError", []byte(msg))
@@ -192,27 +386,20 @@ func (s *groupService) GroupAccess(handler func(wr http.ResponseWriter, req *htt
 	return func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, uid uint64) {
 		u, g, e := s.groupMW(req, ps, uid)
 		if e != nil {
-			if se, ok := e.(*StatusError); ok {
-				log.Printf("GroupAccess: %s", se.Error())
-				http.Error(wr, se.Error(), se.Code)
-				return
-			}
+			handleError(e, wr, req)
+			return
 		}
 		mem := g.FindMember(u.ID)
+		if mem == nil {
+			handleError(errNotMember, wr, req)
+			return
+		}
 		if e := s.gs.GetRoles(mem); e != nil {
-			log.Printf("Web.GroupAccess: %s", e.Error())
-			http.Error(wr, "Internal server error", 500)
+			handleError(internalError(e), wr, req)
 			return
 		}
-		if mem == nil || !mem.SuperRole.CanEdit() {
-			var msg string
-			if mem == nil {
-				msg = "Member not found"
-			} else {
-				msg = "Member has insufficient privileges"
-			}
-			log.Printf("UserID: %v, GroupID: %v, GroupAccess: %s", u.ID, g.ID, msg)
-			http.Error(wr, "Unauthorized", http.StatusUnauthorized)
+		if !mem.SuperRole.CanEdit() {
+			handleError(&core.ForbiddenError{Msg: "You do not have permission to edit this group"}, wr, req)
 			return
 		}
 		handler(wr, req, ps, u, g)
@@ -224,17 +411,11 @@ func (s *groupService) GroupView(handler func(wr http.ResponseWriter, req *http.
 	return func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, uid uint64) {
 		u, g, e := s.groupMW(req, ps, uid)
 		if e != nil {
-			if se, ok := e.(*StatusError); ok {
-				log.Printf("GroupView: %s", se.Error())
-				http.Error(wr, http.StatusText(se.Code), se.Code)
-			}
-			http.Error(wr, "Internal server error", 500)
+			handleError(e, wr, req)
 			return
 		}
-		mem := g.FindMember(u.ID)
-		if mem == nil {
-			http.Error(wr, "unauthorized", http.StatusUnauthorized)
-			log.Printf("GroupView: User: %v, not member of group: %v", u.ID, g.ID)
+		if mem := g.FindMember(u.ID); mem == nil {
+			handleError(errNotMember, wr, req)
 			return
 		}
 		handler(wr, req, ps, u, g)
@@ -246,21 +427,21 @@ func (s *groupService) groupMW(req *http.Request, ps httprouter.Params, uid uint
 	groupID, e := strconv.ParseUint(ps.ByName("groupID"), 10, 64)
 	if e != nil {
 		if groupID, e = strconv.ParseUint(req.FormValue("group_id"), 10, 64); e != nil {
-			return nil, nil, &StatusError{Err: e, Code: http.StatusBadRequest}
+			return nil, nil, &core.ValidationError{Field: "group_id", Msg: "Invalid group"}
 		}
 	}
 	group := core.Group{ID: groupID}
 	e = s.gs.GetGroup(&group)
 	if e != nil {
-		return nil, nil, &StatusError{Err: e, Code: http.StatusInternalServerError}
+		return nil, nil, e
 	}
 	user := core.User{ID: uid}
 	e = s.us.GetUserByID(&user)
 	if e != nil {
-		return nil, nil, &StatusError{Err: e, Code: http.StatusInternalServerError}
+		return nil, nil, internalError(e)
 	}
 	if e := s.gs.GetMemberships(&group); e != nil {
-		return nil, nil, &StatusError{Err: e, Code: http.StatusInternalServerError}
+		return nil, nil, internalError(e)
 	}
 	return &user, &group, nil
 }
@@ -2,9 +2,9 @@ package web
 
 import (
 	"chores-suck/core"
-	"log"
+	"chores-suck/logging"
+	"chores-suck/metrics"
 	"net/http"
-	"os"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -20,10 +20,18 @@ type Services struct {
 	users  UserService
 	roles  RoleService
 	chores ChoreService
+	swaps  SwapService
+	notes  NotificationService
+	feeds  FeedService
+	live   LiveService
+	imp    ImportService
+	health HealthService
 }
 
 // NewServices creates a new Services object
-func NewServices(a AuthService, v ViewService, g GroupService, u UserService, r RoleService, c ChoreService) *Services {
+func NewServices(a AuthService, v ViewService, g GroupService, u UserService, r RoleService, c ChoreService,
+	sw SwapService, n NotificationService, f FeedService, l LiveService, i ImportService,
+	h HealthService) *Services {
 	return &Services{
 		auth:   a,
 		views:  v,
@@ -31,33 +39,68 @@ func NewServices(a AuthService, v ViewService, g GroupService, u UserService, r
 		users:  u,
 		roles:  r,
 		chores: c,
+		swaps:  sw,
+		notes:  n,
+		feeds:  f,
+		live:   l,
+		imp:    i,
+		health: h,
 	}
 }
 
-//Handler creates and returns a new http.Handler with the request handlers and functions pre-registered/routed
-func Handler(s *Services) http.Handler {
-	ro := httprouter.New()
+//Handler creates and returns a new http.Handler with the request handlers and functions pre-registered/routed.
+//Every request is logged with the logger. The metrics are only served to requests bearing metricsToken.
+func Handler(s *Services, a *Assets, metricsToken string, l *logging.Logger) http.Handler {
+	router := httprouter.New()
+	ro := routes{router}
 	ro.GET("/groups/update/:groupID", s.groupMW(s.views.EditGroupForm))
 	ro.GET("/roles/create/:groupID", s.groupMW(s.views.NewRoleForm))
 	ro.GET("/roles/update/:roleID", s.roleMW(s.views.UpdateRoleForm))
 	ro.GET("/chores/create/:groupID", s.groupMW(s.views.NewChoreForm))
 	ro.GET("/chores/update/:choreID", s.choreMW(s.views.UpdateChoreForm))
+	ro.GET("/groups/view/:groupID", s.groupView(s.views.GroupPage))
+	ro.GET("/groups/away/:groupID", s.groupView(s.views.AvailabilityForm))
+	ro.GET("/swaps/create/:choreID", s.choreView(s.views.SwapForm))
+	ro.GET("/groups/leaderboard/:groupID", s.groupView(s.views.Leaderboard))
+	ro.GET("/groups/webhooks/:groupID", s.groupMW(s.views.WebhooksForm))
+	ro.GET("/groups/events/:groupID", s.groupView(s.live.GroupEvents))
+	ro.GET("/groups/export/:groupID", s.groupMW(s.groups.Export))
+	ro.GET("/groups/import/:groupID", s.groupMW(s.views.ImportForm))
 	ro.POST("/groups/update/:groupID", s.groupMW(s.groups.UpdateGroup))
 	ro.POST("/roles/create/:groupID", s.groupMW(s.groups.AddRole))
 	ro.POST("/roles/update/:roleID", s.roleMW(s.roles.Update))
 	ro.POST("/chores/create/:groupID", s.groupMW(s.chores.Create))
 	ro.POST("/chores/update/:choreID", s.choreMW(s.chores.Update))
+	ro.POST("/groups/away/:groupID", s.groupView(s.groups.UpdateAvailability))
+	ro.POST("/groups/webhooks/:groupID", s.groupMW(s.groups.UpdateWebhooks))
+	ro.POST("/groups/import/:groupID", s.groupMW(s.imp.Import))
+	ro.POST("/swaps/create/:choreID", s.choreView(s.swaps.Create))
+	ro.POST("/chores/complete/:choreID", s.choreView(s.chores.Complete))
+	ro.POST("/swaps/respond/:swapID", s.authorizeParam(s.swaps.Respond))
+	ro.GET("/feeds/:token", s.feeds.Serve)
+	ro.HandlerFunc("GET", "/healthz", s.health.Healthz)
+	ro.HandlerFunc("GET", "/readyz", s.health.Readyz)
 	ro.HandlerFunc("GET", "/", s.views.Index)
 	ro.HandlerFunc("GET", "/login", s.views.LoginForm)
 	ro.HandlerFunc("GET", "/logout", s.auth.Logout)
 	ro.HandlerFunc("GET", "/dashboard", s.authorize(s.views.Dashboard))
 	ro.HandlerFunc("GET", "/register", s.views.RegisterForm)
 	ro.HandlerFunc("GET", "/groups/create", s.authorize(s.views.NewGroupForm))
+	ro.HandlerFunc("GET", "/notifications", s.authorize(s.views.NotificationsForm))
+	ro.HandlerFunc("GET", "/inbox", s.authorize(s.views.Inbox))
+	ro.HandlerFunc("GET", "/calendar", s.authorize(s.views.CalendarForm))
 	ro.HandlerFunc("POST", "/login", s.auth.Login)
 	ro.HandlerFunc("POST", "/register", s.users.CreateUser)
 	ro.HandlerFunc("POST", "/groups/create", s.authorize(s.groups.CreateGroup))
-	ro.ServeFiles("/public/*filepath", http.Dir(os.Getenv("CS_STATIC_PATH")))
-	return ro
+	ro.HandlerFunc("POST", "/notifications", s.authorize(s.notes.Update))
+	ro.HandlerFunc("POST", "/inbox", s.authorize(s.notes.MarkRead))
+	ro.HandlerFunc("POST", "/calendar", s.authorize(s.feeds.Update))
+	ro.HandlerFunc("POST", "/templates", s.authorize(s.groups.DeleteTemplate))
+	ro.GET("/public/*filepath", a.static.serve)
+	if metricsToken != "" {
+		router.Handler("GET", "/metrics", requireToken(metricsToken, metrics.Default.Handler()))
+	}
+	return requestLogger(localize(router), l)
 }
 
 /////////////////////////////////////////////////////////////////
@@ -68,12 +111,11 @@ func (s *Services) authorize(handler func(wr http.ResponseWriter, req *http.Requ
 		// TODO: Save the requested url in a cookie that can be redirected to after logging in successfully
 		uid, err := s.auth.Authorize(wr, req)
 		if err != nil {
-			log.Print(err)
 			http.Redirect(wr, req, "/login", 302)
 			return
 		}
 
-		handler(wr, req, uid)
+		handler(wr, withUser(req, uid), uid)
 	}
 }
 
@@ -81,12 +123,11 @@ func (s *Services) authorizeParam(handler func(wr http.ResponseWriter, req *http
 	return func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		uid, err := s.auth.Authorize(wr, req)
 		if err != nil {
-			log.Print(err)
 			http.Redirect(wr, req, "/login", 302)
 			return
 		}
 
-		handler(wr, req, ps, uid)
+		handler(wr, withUser(req, uid), ps, uid)
 	}
 }
 
@@ -102,6 +143,10 @@ func (s *Services) choreMW(handler func(wr http.ResponseWriter, req *http.Reques
 	return s.authorizeParam(s.chores.ChoreMW(handler))
 }
 
+func (s *Services) choreView(handler func(wr http.ResponseWriter, req *http.Request, us *core.User, ch *core.Chore)) httprouter.Handle {
+	return s.authorizeParam(s.chores.ChoreView(handler))
+}
+
 func (s *Services) groupView(handler func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, us *core.User, group *core.Group)) httprouter.Handle {
 	return s.authorizeParam(s.groups.GroupView(handler))
 }
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// pingTimeout is how long the readiness check waits for the database
+const pingTimeout = 2 * time.Second
+
+// Pinger is a dependency the server cannot serve requests without, such as the database
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type HealthService interface {
+	// Healthz reports that the process is up
+	Healthz(wr http.ResponseWriter, req *http.Request)
+	// Readyz reports whether the server can take requests: it is not shutting down and the
+	// database answers
+	Readyz(wr http.ResponseWriter, req *http.Request)
+	// Drain makes Readyz fail from now on so load balancers stop sending requests during shutdown
+	Drain()
+}
+
+type healthService struct {
+	db       Pinger
+	draining int32
+}
+
+func NewHealthService(db Pinger) HealthService {
+	return &healthService{
+		db: db,
+	}
+}
+
+func (s *healthService) Healthz(wr http.ResponseWriter, req *http.Request) {
+	wr.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	wr.Write([]byte("ok\n"))
+}
+
+func (s *healthService) Readyz(wr http.ResponseWriter, req *http.Request) {
+	wr.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	wr.Header().Set("Cache-Control", "no-store")
+	if atomic.LoadInt32(&s.draining) == 1 {
+		http.Error(wr, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), pingTimeout)
+	defer cancel()
+	if e := s.db.PingContext(ctx); e != nil {
+		logFor(req).Warn("Readyz: Database unavailable", "err", e)
+		http.Error(wr, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	wr.Write([]byte("ok\n"))
+}
+
+func (s *healthService) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"chores-suck/core"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxImportSize is the largest import file accepted, in bytes
+const maxImportSize = 1 << 20
+
+type ImportService interface {
+	// Import previews the uploaded file with any problems found in it. Confirming the preview
+	// submits the same data again with submit_2, which imports it.
+	Import(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
+}
+
+type importService struct {
+	is    core.ImportService
+	views ViewService
+}
+
+func NewImportService(i core.ImportService, v ViewService) ImportService {
+	return &importService{
+		is:    i,
+		views: v,
+	}
+}
+
+func (s *importService) Import(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	back := fmt.Sprintf("/groups/import/%v", group.ID)
+	data, format, e := readImport(wr, req)
+	if e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+		http.Redirect(wr, req, back, 302)
+		return
+	}
+	plan, e := s.is.Plan(group, user, format, strings.NewReader(data))
+	if e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+		http.Redirect(wr, req, back, 302)
+		return
+	}
+	if req.PostFormValue("submit_2") != "" && plan.Valid() {
+		if e := s.is.Apply(plan, user); e != nil {
+			SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+			http.Redirect(wr, req, back, 302)
+			return
+		}
+		http.Redirect(wr, req, fmt.Sprintf("/groups/update/%v", group.ID), 302)
+		return
+	}
+	s.views.ImportPreview(wr, req, user, group, plan, format, data)
+}
+
+// readImport returns the contents of the uploaded file or, when confirming a preview, the data
+// the preview was made from
+func readImport(wr http.ResponseWriter, req *http.Request) (string, core.ImportFormat, error) {
+	// Confirming a preview sends the file URL encoded, which can make it up to three times larger
+	req.Body = http.MaxBytesReader(wr, req.Body, 4*maxImportSize)
+	if e := req.ParseMultipartForm(maxImportSize); e != nil && e != http.ErrNotMultipart {
+		return "", "", ErrInvalidFormData
+	}
+	format := core.ImportFormat(req.PostFormValue("format"))
+	if req.PostFormValue("submit_2") != "" {
+		return req.PostFormValue("data"), format, nil
+	}
+	file, header, e := req.FormFile("import_file")
+	if e != nil {
+		return "", "", ErrInvalidFormData
+	}
+	defer file.Close()
+	b, e := ioutil.ReadAll(file)
+	if e != nil {
+		return "", "", ErrInvalidFormData
+	}
+	if strings.EqualFold(filepath.Ext(header.Filename), ".json") {
+		format = core.ImportJSON
+	} else if strings.EqualFold(filepath.Ext(header.Filename), ".csv") {
+		format = core.ImportCSV
+	}
+	return string(b), format, nil
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"chores-suck/core"
+	"chores-suck/live"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// keepAlive is how often a comment is sent on an idle stream so proxies do not close it
+const keepAlive = 30 * time.Second
+
+type LiveService interface {
+	// GroupEvents streams the group's events to the client as server-sent events
+	GroupEvents(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, user *core.User, group *core.Group)
+}
+
+type liveService struct {
+	hub *live.Hub
+	// limit is how long a stream stays open. Streams end before the server's write timeout would
+	// cut them off and the browser reconnects.
+	limit time.Duration
+}
+
+// NewLiveService creates the service for a server with the given write timeout, zero meaning none
+func NewLiveService(h *live.Hub, writeTimeout time.Duration) LiveService {
+	return &liveService{
+		hub:   h,
+		limit: writeTimeout - writeTimeout/10,
+	}
+}
+
+func (s *liveService) GroupEvents(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	flusher, ok := wr.(http.Flusher)
+	if !ok {
+		handleError(internalError(errors.New("streaming is not supported")), wr, req)
+		return
+	}
+	wr.Header().Set("Content-Type", "text/event-stream")
+	wr.Header().Set("Cache-Control", "no-cache")
+	wr.Header().Set("Connection", "keep-alive")
+	// Stop reverse proxies from buffering the stream
+	wr.Header().Set("X-Accel-Buffering", "no")
+
+	client := s.hub.Join(group.ID, user.ID)
+	defer s.hub.Leave(client)
+	fmt.Fprint(wr, "retry: 5000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	var expired <-chan time.Time
+	if s.limit > 0 {
+		timer := time.NewTimer(s.limit)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-expired:
+			return
+		case m, ok := <-client.Messages:
+			if !ok {
+				return
+			}
+			data, e := json.Marshal(m)
+			if e != nil {
+				logFor(req).Error("GroupEvents: Failed to encode message", "err", e)
+				continue
+			}
+			fmt.Fprintf(wr, "data: %s\n\n", data)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprint(wr, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
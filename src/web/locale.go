@@ -0,0 +1,92 @@
+package web
+
+import (
+	"chores-suck/core"
+	"chores-suck/i18n"
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// LocaleCookie remembers the language a user chose, so pages are shown in it before they sign in
+// and without loading the user on every request
+const LocaleCookie = "lang"
+
+// ZoneCookie holds the time zone the browser reports, which times are shown in for users who have
+// not chosen one. It is set by the site's script.
+const ZoneCookie = "tz"
+
+type localeKey struct{}
+
+type zoneKey struct{}
+
+// localize picks the language of each request from the user's choice, if they made one, or from
+// the languages their browser accepts, and the time zone from the one the browser reports
+func localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		var preferred string
+		if c, e := req.Cookie(LocaleCookie); e == nil {
+			preferred = c.Value
+		}
+		l := i18n.Negotiate(req.Header.Get("Accept-Language"), preferred)
+		wr.Header().Add("Vary", "Accept-Language")
+		ctx := context.WithValue(req.Context(), localeKey{}, l)
+		ctx = context.WithValue(ctx, zoneKey{}, browserZone(req))
+		next.ServeHTTP(wr, req.WithContext(ctx))
+	})
+}
+
+// browserZone returns the zone in the request's zone cookie, or UTC if it has none or it is
+// unknown
+func browserZone(req *http.Request) *time.Location {
+	c, e := req.Cookie(ZoneCookie)
+	if e != nil {
+		return time.UTC
+	}
+	name, e := url.QueryUnescape(c.Value)
+	if e != nil || name == "" {
+		return time.UTC
+	}
+	loc, e := core.LoadLocation(name)
+	if e != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// localeFor returns the locale of the request
+func localeFor(req *http.Request) *i18n.Locale {
+	if l, ok := req.Context().Value(localeKey{}).(*i18n.Locale); ok {
+		return l
+	}
+	return i18n.Get(i18n.Default)
+}
+
+// zoneFor returns the time zone times are shown in for the request. A zone the user chose wins
+// over the one their browser reports.
+func zoneFor(req *http.Request, user *core.User) *time.Location {
+	if user != nil && user.TimeZone != "" {
+		return user.Location()
+	}
+	if loc, ok := req.Context().Value(zoneKey{}).(*time.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// tr translates a message into the language of the request
+func tr(req *http.Request, msg string, args ...interface{}) string {
+	return localeFor(req).T(msg, args...)
+}
+
+// setLocaleCookie remembers the user's language, or forgets it when they have not chosen one so
+// their browser's languages are used
+func setLocaleCookie(wr http.ResponseWriter, tag string) {
+	if tag == "" {
+		http.SetCookie(wr, &http.Cookie{Name: LocaleCookie, Path: "/", MaxAge: -1, Expires: time.Unix(1, 0)})
+		return
+	}
+	http.SetCookie(wr, &http.Cookie{Name: LocaleCookie, Value: tag, Path: "/", MaxAge: 365 * 24 * 60 * 60,
+		HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
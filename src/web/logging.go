@@ -0,0 +1,91 @@
+package web
+
+import (
+	"chores-suck/core"
+	"chores-suck/logging"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader carries the id a request is logged under. An id sent by a proxy in front of the
+// server is kept so its logs and ours can be matched up.
+const RequestIDHeader = "X-Request-ID"
+
+// requestScope holds what handlers learn about a request that the access log line reports
+type requestScope struct {
+	user uint64
+}
+
+type scopeKey struct{}
+
+// requestLogger gives each request an id and a logger carrying it, and logs every request once it
+// has been served.
+func requestLogger(next http.Handler, l *logging.Logger) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		id := req.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		wr.Header().Set(RequestIDHeader, id)
+
+		rl := l.With("request_id", id)
+		scope := &requestScope{}
+		ctx := logging.NewContext(req.Context(), rl)
+		ctx = context.WithValue(ctx, scopeKey{}, scope)
+		rec := &statusRecorder{ResponseWriter: wr}
+		next.ServeHTTP(rec, req.WithContext(ctx))
+
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		// The cause of a server error has already been logged by the handler at error level
+		logf := rl.Info
+		if rec.code >= 500 {
+			logf = rl.Warn
+		}
+		kv := []interface{}{"method", req.Method, "path", req.URL.Path, "status", rec.code,
+			"duration", time.Since(start)}
+		if scope.user != 0 {
+			kv = append(kv, "user", scope.user)
+		}
+		logf("Request", kv...)
+	})
+}
+
+// validRequestID accepts short ids of printable characters so a client cannot break up log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' || c == '"' || c == '=' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// logFor returns the logger of the request
+func logFor(req *http.Request) *logging.Logger {
+	return logging.FromContext(req.Context())
+}
+
+// withUser records the signed in user for the access log and adds them to the request's logger
+func withUser(req *http.Request, uid uint64) *http.Request {
+	if scope, ok := req.Context().Value(scopeKey{}).(*requestScope); ok {
+		scope.user = uid
+	}
+	return req.WithContext(logging.NewContext(req.Context(), logFor(req).With("user", uid)))
+}
+
+// errorDetail describes an error for the log, including the cause of internal errors
+func errorDetail(e error) string {
+	var ie *core.InternalError
+	if errors.As(e, &ie) {
+		return ie.Detail()
+	}
+	return e.Error()
+}
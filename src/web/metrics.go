@@ -0,0 +1,93 @@
+package web
+
+import (
+	"chores-suck/metrics"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounter("chores_http_requests_total",
+		"HTTP requests by method, route and status code.", "method", "route", "code")
+	httpDuration = metrics.Default.NewHistogram("chores_http_request_duration_seconds",
+		"Time taken to serve HTTP requests by method and route.", metrics.DefaultBuckets, "method", "route")
+)
+
+// requireToken only passes on requests that send the token as a bearer token. The metrics reveal
+// how the site is used, so they are kept from anyone who can reach the server.
+func requireToken(token string, h http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
+		got := []byte(req.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			wr.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(wr, req)
+	})
+}
+
+// routes registers handlers on the router so that every request is counted and timed under the
+// pattern of its route rather than its path, which keeps ids out of the metrics
+type routes struct {
+	*httprouter.Router
+}
+
+func (r routes) GET(path string, h httprouter.Handle) {
+	r.Handle("GET", path, h)
+}
+
+func (r routes) POST(path string, h httprouter.Handle) {
+	r.Handle("POST", path, h)
+}
+
+func (r routes) HandlerFunc(method string, path string, h http.HandlerFunc) {
+	r.Handle(method, path, func(wr http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		h(wr, req)
+	})
+}
+
+func (r routes) Handle(method string, path string, h httprouter.Handle) {
+	r.Router.Handle(method, path, func(wr http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: wr}
+		h(rec, req, ps)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		httpRequests.Inc(method, path, strconv.Itoa(rec.code))
+		httpDuration.Observe(time.Since(start).Seconds(), method, path)
+	})
+}
+
+// statusRecorder remembers the status code written to the response. It is a Flusher so event
+// streams keep working through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"chores-suck/core"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type NotificationService interface {
+	Update(wr http.ResponseWriter, req *http.Request, uid uint64)
+	MarkRead(wr http.ResponseWriter, req *http.Request, uid uint64)
+}
+
+type notificationService struct {
+	ns core.NotificationService
+	ds core.DigestService
+	us core.UserService
+}
+
+func NewNotificationService(n core.NotificationService, d core.DigestService, u core.UserService) NotificationService {
+	return &notificationService{
+		ns: n,
+		ds: d,
+		us: u,
+	}
+}
+
+func (s *notificationService) Update(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	if e := s.us.GetUserByID(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if submit := req.PostFormValue("submit_1"); submit != "" {
+		s.updateChannels(wr, req, &user)
+	} else if submit := req.PostFormValue("submit_2"); submit != "" {
+		s.updateDigest(wr, req, &user)
+	} else if submit := req.PostFormValue("submit_3"); submit != "" {
+		s.updatePreferences(wr, req, &user)
+	} else {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(wr, req, "/notifications", 302)
+}
+
+func (s *notificationService) updateChannels(wr http.ResponseWriter, req *http.Request, user *core.User) {
+	for _, c := range core.Channels {
+		pref := core.ChannelPref{
+			User:    user,
+			Channel: c,
+			Enabled: req.PostFormValue(c) == "true",
+			Target:  strings.TrimSpace(req.PostFormValue(c + "_target")),
+		}
+		if e := s.ns.UpdateChannelPref(&pref, user); e != nil {
+			SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+			return
+		}
+	}
+}
+
+func (s *notificationService) updateDigest(wr http.ResponseWriter, req *http.Request, user *core.User) {
+	hour, e1 := strconv.Atoi(req.PostFormValue("hour"))
+	weekday, e2 := strconv.Atoi(req.PostFormValue("weekday"))
+	if e1 != nil || e2 != nil {
+		SetFlash(wr, "genError", []byte(tr(req, ErrInvalidFormData.Error())))
+		return
+	}
+	prefs := core.DigestPrefs{
+		Frequency: core.DigestFrequency(req.PostFormValue("frequency")),
+		Hour:      hour,
+		Weekday:   time.Weekday(weekday),
+		TimeZone:  strings.TrimSpace(req.PostFormValue("time_zone")),
+	}
+	if e := s.ds.UpdateDigestPrefs(user, prefs); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+}
+
+// updatePreferences saves the language and time zone the user chose and remembers the language
+// in their browser
+func (s *notificationService) updatePreferences(wr http.ResponseWriter, req *http.Request, user *core.User) {
+	user.Locale = req.PostFormValue("locale")
+	user.TimeZone = strings.TrimSpace(req.PostFormValue("user_time_zone"))
+	if e := s.us.UpdatePreferences(user); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+		return
+	}
+	setLocaleCookie(wr, user.Locale)
+}
+
+func (s *notificationService) MarkRead(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	ids := make([]uint64, 0)
+	if req.PostFormValue("submit_1") != "" {
+		if e := req.ParseForm(); e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		for _, v := range req.PostForm["notification_id"] {
+			id, e := strconv.ParseUint(v, 10, 64)
+			if e != nil {
+				http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			ids = append(ids, id)
+		}
+		// Nothing was selected, so there is nothing to mark
+		if len(ids) == 0 {
+			http.Redirect(wr, req, "/inbox", 302)
+			return
+		}
+	} else if req.PostFormValue("submit_2") == "" {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if e := s.ns.MarkRead(&user, ids); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, "/inbox", 302)
+}
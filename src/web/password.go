@@ -1,20 +1,16 @@
 package web
 
 import (
-	"log"
-
 	"golang.org/x/crypto/bcrypt"
 )
 
 func checkpword(plain string, hashed string) bool {
 	e := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
-	if e != nil {
-		log.Print(e)
-	}
 	return e == nil
 }
 
-func hashPassword(password string) (string, error) {
+// HashPassword hashes a password for storing with the user
+func HashPassword(password string) (string, error) {
 	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(hashed), err
 }
@@ -52,9 +52,10 @@ func (s *roleService) updateRole(wr http.ResponseWriter, req *http.Request,
 	editChores := req.PostFormValue("editchores") == "true"
 	editGroup := req.PostFormValue("editgroup") == "true"
 	editRoles := req.PostFormValue("editroles") == "true"
+	viewAudit := req.PostFormValue("viewaudit") == "true"
 	getsChores := req.PostFormValue("getschores") == "true"
 	if e := validateGroupName(name); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
 	} else {
 		newRole := core.Role{ID: role.ID, Group: role.Group}
 		newRole.Name = name
@@ -63,8 +64,9 @@ func (s *roleService) updateRole(wr http.ResponseWriter, req *http.Request,
 		newRole.Set(core.EditChores, editChores)
 		newRole.Set(core.EditGroup, editGroup)
 		newRole.Set(core.EditRoles, editRoles)
+		newRole.Set(core.ViewAudit, viewAudit)
 		if e := s.rs.Update(role, &newRole, user); e != nil {
-			msg = e.Error()
+			msg = errorMessage(req, e)
 		}
 	}
 	if msg != "" {
@@ -79,7 +81,7 @@ func (s *roleService) addMember(wr http.ResponseWriter, req *http.Request,
 	var msg string
 	username := req.PostFormValue("username")
 	if e := s.rs.AddMember(role, username, user); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
 	}
 	if msg != "" {
 		SetFlash(wr, "genError", []byte(msg))
@@ -93,10 +95,10 @@ func (s *roleService) delMember(wr http.ResponseWriter, req *http.Request,
 	var msg string
 	delID, e := strconv.ParseUint(req.PostFormValue("user_id"), 10, 64)
 	if e != nil {
-		msg = "Invalid request"
+		msg = tr(req, "Invalid request")
 	}
 	if e := s.rs.RemoveMember(role, delID, user); e != nil {
-		msg = e.Error()
+		msg = errorMessage(req, e)
 	}
 	if msg != "" {
 		SetFlash(wr, "genError", []byte(msg))
@@ -107,8 +109,8 @@ func (s *roleService) delMember(wr http.ResponseWriter, req *http.Request,
 
 func (s *roleService) delete(wr http.ResponseWriter, req *http.Request,
 	user *core.User, role *core.Role) {
-	if e := s.rs.Delete(role); e != nil {
-		SetFlash(wr, "genError", []byte(e.Error()))
+	if e := s.rs.Delete(role, user); e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
 		url := fmt.Sprintf("/roles/update/%v", role.ID)
 		http.Redirect(wr, req, url, 302)
 		return
@@ -131,7 +133,7 @@ func (s *roleService) RoleMW(handler func(wr http.ResponseWriter, req *http.Requ
 		}
 		role := core.Role{ID: roleID}
 		if e = s.rs.GetRole(&role); e != nil {
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(internalError(e), wr, req)
 			return
 		} else if role.Name == "" {
 			http.Error(wr, http.StatusText(http.StatusNotFound), http.StatusNotFound)
@@ -139,34 +141,34 @@ func (s *roleService) RoleMW(handler func(wr http.ResponseWriter, req *http.Requ
 		}
 		//Get the group
 		if e = s.gs.GetGroup(role.Group); e != nil {
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(e, wr, req)
 			return
 		}
 		//Get group members
 		if e = s.gs.GetMemberships(role.Group); e != nil {
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(internalError(e), wr, req)
 			return
 		}
 		//Check if user is a member
 		mem := role.Group.FindMember(uid)
 		if mem == nil {
-			http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handleError(errNotMember, wr, req)
 			return
 		}
 		//Get the user
 		user := core.User{ID: uid}
 		if e = s.us.GetUserByID(&user); e != nil {
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(internalError(e), wr, req)
 			return
 		}
 		//Get member roles
 		if e = s.gs.GetRoles(mem); e != nil {
-			http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			handleError(internalError(e), wr, req)
 			return
 		}
 		//Check if member has permission to update roles
 		if !mem.SuperRole.Can(core.EditRoles) {
-			http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			handleError(&core.ForbiddenError{Msg: "You do not have permission to edit roles"}, wr, req)
 			return
 		}
 		handler(wr, req, ps, &user, &role)
@@ -1,8 +1,7 @@
 package sessions
 
 import (
-	"errors"
-	"log"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -19,6 +18,7 @@ type Repository interface {
 	GetSession(ses *core.Session) error
 	DeleteSession(ID string) error
 	UpsertSession(ses *core.Session) error
+	DeleteSessions(createdBefore time.Time) (int64, error)
 }
 
 // Store defines properties of a session store
@@ -99,8 +99,7 @@ func (s *Store) Save(req *http.Request, w http.ResponseWriter, ses *sessions.Ses
 	var userID uint64
 	userID, ok := ses.Values["userid"].(uint64)
 	if !ok || userID == 0 {
-		log.Printf("Store.Save: user id failure. ok: %v, id: %v", ok, userID)
-		return errors.New("Store.Save: Failed to get user id from session")
+		return fmt.Errorf("Store.Save: Failed to get user id from session: ok: %v, id: %v", ok, userID)
 	}
 	ts.UserID = userID
 
@@ -130,3 +129,9 @@ func (s *Store) MaxAge(age int) {
 		}
 	}
 }
+
+// Purge removes the sessions created longer ago than the store's maximum age and returns how
+// many were removed
+func (s *Store) Purge() (int64, error) {
+	return s.repo.DeleteSessions(time.Now().UTC().Add(-time.Duration(s.opts.MaxAge) * time.Second))
+}
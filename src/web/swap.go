@@ -0,0 +1,86 @@
+package web
+
+import (
+	"chores-suck/core"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type SwapService interface {
+	Create(wr http.ResponseWriter, req *http.Request, user *core.User, chore *core.Chore)
+	Respond(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, uid uint64)
+}
+
+type swapService struct {
+	ss core.SwapService
+	us core.UserService
+}
+
+func NewSwapService(sw core.SwapService, u core.UserService) SwapService {
+	return &swapService{
+		ss: sw,
+		us: u,
+	}
+}
+
+func (s *swapService) Create(wr http.ResponseWriter, req *http.Request, user *core.User, chore *core.Chore) {
+	var msg string
+	toID, e := strconv.ParseUint(req.PostFormValue("to_id"), 10, 64)
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	r := core.SwapRequest{Group: chore.Group, From: user, To: &core.User{ID: toID}, Chore: chore}
+	if counter := req.PostFormValue("counter_id"); counter != "" {
+		counterID, e := strconv.ParseUint(counter, 10, 64)
+		if e != nil {
+			http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		r.Counter = &core.Chore{ID: counterID}
+	}
+	if e := s.ss.Propose(&r, user); e != nil {
+		msg = errorMessage(req, e)
+	}
+	if msg != "" {
+		SetFlash(wr, "genError", []byte(msg))
+		http.Redirect(wr, req, fmt.Sprintf("/swaps/create/%v", chore.ID), 302)
+		return
+	}
+	http.Redirect(wr, req, "/dashboard", 302)
+}
+
+func (s *swapService) Respond(wr http.ResponseWriter, req *http.Request, ps httprouter.Params, uid uint64) {
+	swapID, e := strconv.ParseUint(ps.ByName("swapID"), 10, 64)
+	if e != nil {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	r := core.SwapRequest{ID: swapID}
+	if e = s.ss.GetSwapRequest(&r); e != nil {
+		handleError(e, wr, req)
+		return
+	}
+	user := core.User{ID: uid}
+	if e = s.us.GetUserByID(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if req.PostFormValue("submit_1") != "" {
+		e = s.ss.Accept(&r, &user)
+	} else if req.PostFormValue("submit_2") != "" {
+		e = s.ss.Decline(&r, &user)
+	} else if req.PostFormValue("submit_3") != "" {
+		e = s.ss.Cancel(&r, &user)
+	} else {
+		http.Error(wr, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if e != nil {
+		SetFlash(wr, "genError", []byte(errorMessage(req, e)))
+	}
+	http.Redirect(wr, req, "/dashboard", 302)
+}
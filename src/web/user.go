@@ -1,6 +1,7 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 
 	"chores-suck/core"
@@ -31,39 +32,39 @@ func (s *userService) CreateUser(wr http.ResponseWriter, req *http.Request) {
 	ok := true
 	e := validateUsername(username)
 	if e != nil {
-		SetFlash(wr, "nameError", []byte(e.Error()))
+		SetFlash(wr, "nameError", []byte(errorMessage(req, e)))
 		ok = false
 	}
 	e = validateEmail(email)
 	if e != nil {
-		SetFlash(wr, "emailError", []byte(e.Error()))
+		SetFlash(wr, "emailError", []byte(errorMessage(req, e)))
 		ok = false
 	}
 	e = validatePassword(password, password2)
 	if e != nil {
-		SetFlash(wr, "passError", []byte(e.Error()))
+		SetFlash(wr, "passError", []byte(errorMessage(req, e)))
 		ok = false
 	}
 
 	if ok {
 		user := core.User{Username: username, Email: email}
 		var err error
-		user.Password, err = hashPassword(password)
+		user.Password, err = HashPassword(password)
 		if err != nil {
-			handleError(internalError(err), wr)
+			handleError(internalError(err), wr, req)
 			return
 		}
 		err = s.users.CreateUser(&user)
 		if err != nil {
-			switch err {
-			case core.ErrEmailExists:
-				SetFlash(wr, "emailError", []byte("Email already registered"))
+			switch {
+			case errors.Is(err, core.ErrEmailExists):
+				SetFlash(wr, "emailError", []byte(tr(req, "Email already registered")))
 				ok = false
-			case core.ErrNameExists:
-				SetFlash(wr, "nameError", []byte("Username already taken"))
+			case errors.Is(err, core.ErrNameExists):
+				SetFlash(wr, "nameError", []byte(tr(req, "Username already taken")))
 				ok = false
 			default:
-				handleError(internalError(err), wr)
+				handleError(internalError(err), wr, req)
 				return
 			}
 		}
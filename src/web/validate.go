@@ -1,29 +1,34 @@
 package web
 
 import (
-	"errors"
+	"chores-suck/core"
 	"regexp"
 	"strings"
 )
 
+// invalid reports a form value that failed validation, the same way core reports its own
+func invalid(field string, msg string) error {
+	return &core.ValidationError{Field: field, Msg: msg}
+}
+
 func validateGroupName(name string) error {
 	regName := regexp.MustCompile(`^(?:[0-9a-zA-Z]+-)*[0-9a-zA-Z]+$`)
 
 	if strings.TrimSpace(name) == "" {
-		return errors.New("Group name cannot be empty")
+		return invalid("groupname", "Group name cannot be empty")
 
 	} else if !regName.MatchString(name) {
-		return errors.New("Name must only consist of alphanumeric characters and hyphens and cannot start or end with a hyphen")
+		return invalid("groupname", "Name must only consist of alphanumeric characters and hyphens and cannot start or end with a hyphen")
 	}
 	return nil
 }
 
 func validatePassword(password string, confirm string) error {
 	if password != confirm {
-		return errors.New("Passwords don't match")
+		return invalid("pwordConf", "Passwords don't match")
 	}
 	if strings.TrimSpace(password) == "" {
-		return errors.New("Password cannot be empty")
+		return invalid("pword", "Password cannot be empty")
 	}
 	return nil
 }
@@ -31,10 +36,10 @@ func validatePassword(password string, confirm string) error {
 func validateUsername(username string) error {
 	regName := regexp.MustCompile(`^(?:[0-9a-zA-Z]+-)*[0-9a-zA-Z]+$`)
 	if strings.TrimSpace(username) == "" {
-		return errors.New("Username cannot be empty")
+		return invalid("username", "Username cannot be empty")
 	}
 	if !regName.MatchString(username) {
-		return errors.New("Username must only consist of alphanumeric characters and hyphens and cannot start or end with a hyphen")
+		return invalid("username", "Username must only consist of alphanumeric characters and hyphens and cannot start or end with a hyphen")
 	}
 	return nil
 }
@@ -42,10 +47,21 @@ func validateUsername(username string) error {
 func validateEmail(email string) error {
 	regEmail := regexp.MustCompile(`.+@.+\..+`)
 	if strings.TrimSpace(email) == "" {
-		return errors.New("Email cannot be empty")
+		return invalid("email", "Email cannot be empty")
 	}
 	if !regEmail.MatchString(email) {
-		return errors.New("Invalid email address")
+		return invalid("email", "Invalid email address")
 	}
 	return nil
 }
+
+// ValidateAccount checks the details of a new account the way the registration form does
+func ValidateAccount(username string, email string, password string) error {
+	if e := validateUsername(username); e != nil {
+		return e
+	}
+	if e := validateEmail(email); e != nil {
+		return e
+	}
+	return validatePassword(password, password)
+}
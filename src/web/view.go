@@ -2,15 +2,24 @@ package web
 
 import (
 	"chores-suck/core"
+	"chores-suck/i18n"
 	"chores-suck/web/messages"
 	"chores-suck/web/sessions"
-	"html/template"
-	"log"
 	"net/http"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// dashboardInboxSize is the number of recent notifications shown on the dashboard
+	dashboardInboxSize = 5
+	// inboxSize is the number of notifications shown in the inbox
+	inboxSize = 50
+	// deliveryLogSize is the number of webhook delivery attempts shown to admins
+	deliveryLogSize = 30
+)
+
 type RegisterFormData struct {
 	Username string
 	Email    string
@@ -25,32 +34,66 @@ type ViewService interface {
 	LoginForm(http.ResponseWriter, *http.Request)
 	NewGroupForm(http.ResponseWriter, *http.Request, uint64)
 	EditGroupForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
+	GroupPage(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
 	NewRoleForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
 	UpdateRoleForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Role)
 	NewChoreForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
 	UpdateChoreForm(http.ResponseWriter, *http.Request, *core.User, *core.Chore)
+	AvailabilityForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
+	SwapForm(http.ResponseWriter, *http.Request, *core.User, *core.Chore)
+	Leaderboard(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
+	NotificationsForm(http.ResponseWriter, *http.Request, uint64)
+	Inbox(http.ResponseWriter, *http.Request, uint64)
+	WebhooksForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
+	CalendarForm(http.ResponseWriter, *http.Request, uint64)
+	ImportForm(http.ResponseWriter, *http.Request, httprouter.Params, *core.User, *core.Group)
+	ImportPreview(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group, plan *core.ImportPlan,
+		format core.ImportFormat, data string)
 }
 
 type viewService struct {
-	store  *sessions.Store
-	users  core.UserService
-	groups core.GroupService
-	auth   AuthService
+	store     *sessions.Store
+	users     core.UserService
+	groups    core.GroupService
+	audit     core.AuditService
+	avail     core.AvailabilityService
+	swaps     core.SwapService
+	scores    core.ScoreService
+	notes     core.NotificationService
+	hooks     core.WebhookService
+	digests   core.DigestService
+	feeds     core.FeedService
+	templates core.TemplateService
+	auth      AuthService
+	assets    *Assets
 }
 
-func NewViewService(s *sessions.Store, u core.UserService, a AuthService, g core.GroupService) ViewService {
+func NewViewService(s *sessions.Store, u core.UserService, a AuthService, g core.GroupService,
+	au core.AuditService, av core.AvailabilityService, sw core.SwapService, sc core.ScoreService,
+	n core.NotificationService, w core.WebhookService, d core.DigestService, f core.FeedService,
+	t core.TemplateService, as *Assets) ViewService {
 	return &viewService{
-		store:  s,
-		users:  u,
-		auth:   a,
-		groups: g,
+		store:     s,
+		users:     u,
+		auth:      a,
+		groups:    g,
+		audit:     au,
+		avail:     av,
+		swaps:     sw,
+		scores:    sc,
+		notes:     n,
+		hooks:     w,
+		digests:   d,
+		feeds:     f,
+		templates: t,
+		assets:    as,
 	}
 }
 
 func (s *viewService) Index(wr http.ResponseWriter, req *http.Request) {
-	err := executeTemplate(wr, nil, "../html/index.html")
+	err := s.render(wr, req, nil, nil, "index.html")
 	if err != nil {
-		handleError(internalError(err), wr)
+		handleError(internalError(err), wr, req)
 		return
 	}
 }
@@ -60,29 +103,47 @@ func (s *viewService) Dashboard(wr http.ResponseWriter, req *http.Request, uid u
 	user.ID = uid
 	err := s.users.GetUserByID(&user)
 	if err != nil {
-		handleError(internalError(err), wr)
+		handleError(internalError(err), wr, req)
 		return
 	}
 
 	err = s.users.GetChores(&user)
 	if err != nil {
-		handleError(internalError(err), wr)
+		handleError(internalError(err), wr, req)
 		return
 	}
 
 	err = s.users.GetMemberships(&user)
 	if err != nil {
-		handleError(internalError(err), wr)
+		handleError(internalError(err), wr, req)
 		return
 	}
+
+	err = s.swaps.GetSwapRequests(&user)
+	if err != nil {
+		handleError(internalError(err), wr, req)
+		return
+	}
+
+	err = s.notes.GetInbox(&user, dashboardInboxSize)
+	if err != nil {
+		handleError(internalError(err), wr, req)
+		return
+	}
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
 	model := struct {
-		User *core.User
+		User  *core.User
+		Error string
 	}{
-		User: &user,
+		User:  &user,
+		Error: msg,
 	}
-	err = executeTemplate(wr, model, "../html/dashboard.html")
+	err = s.render(wr, req, &user, model, "dashboard.html")
 	if err != nil {
-		handleError(internalError(err), wr)
+		handleError(internalError(err), wr, req)
 		return
 	}
 }
@@ -115,9 +176,9 @@ func (s *viewService) RegisterForm(wr http.ResponseWriter, req *http.Request) {
 		EmailError: emailErr,
 		PassError:  passErr,
 	}
-	e := executeTemplate(wr, model, "../html/register.html")
+	e := s.render(wr, req, nil, model, "register.html")
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 	}
 }
 
@@ -137,9 +198,9 @@ func (s *viewService) LoginForm(wr http.ResponseWriter, req *http.Request) {
 		User:  nil,
 		Error: err,
 	}
-	e = executeTemplate(wr, model, "../html/login.html")
+	e = s.render(wr, req, nil, model, "login.html")
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 		return
 	}
 }
@@ -147,7 +208,7 @@ func (s *viewService) NewGroupForm(wr http.ResponseWriter, req *http.Request, ui
 	user := core.User{ID: uid}
 	e := s.users.GetUserByID(&user)
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 		return
 	}
 	var genErr string
@@ -158,6 +219,10 @@ func (s *viewService) NewGroupForm(wr http.ResponseWriter, req *http.Request, ui
 	if data, _ := GetFlash(wr, req, "nameError"); data != nil {
 		nameErr = string(data)
 	}
+	if e := s.templates.GetTemplates(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
 	model := struct {
 		User      *core.User
 		GenError  string
@@ -167,9 +232,9 @@ func (s *viewService) NewGroupForm(wr http.ResponseWriter, req *http.Request, ui
 		GenError:  genErr,
 		NameError: nameErr,
 	}
-	e = executeTemplate(wr, model, "../html/newgroup.html")
+	e = s.render(wr, req, &user, model, "newgroup.html")
 	if e != nil {
-		handleError(internalError(e), wr)
+		handleError(internalError(e), wr, req)
 		return
 	}
 }
@@ -177,16 +242,28 @@ func (s *viewService) NewGroupForm(wr http.ResponseWriter, req *http.Request, ui
 func (s *viewService) EditGroupForm(wr http.ResponseWriter, req *http.Request,
 	ps httprouter.Params, user *core.User, group *core.Group) {
 	if e := s.groups.GetRoles(group); e != nil {
-		log.Printf("UserID: %v, GroupID: %v, EditGroupForm: Error: %s", user.ID, group.ID, e.Error())
+		handleError(internalError(e), wr, req)
 		return
 	}
 	if e := s.groups.GetChores(group); e != nil {
-		log.Print("EditGroupForm: Failed to get chores")
+		handleError(internalError(e), wr, req)
 		return
 	}
+	mem := group.FindMember(user.ID)
+	canAudit := mem.SuperRole.Can(core.ViewAudit)
+	if canAudit {
+		if e := s.audit.GetAuditLog(group); e != nil {
+			logFor(req).Warn("EditGroupForm: Failed to get audit log", "group", group.ID, "err", errorDetail(e))
+		}
+	}
 	var nameErr string
 	var memErr string
 	var choreErr string
+	var settingsErr string
+	var genErr string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		genErr = string(data)
+	}
 	if data, _ := GetFlash(wr, req, "nameError"); data != nil {
 		nameErr = string(data)
 	}
@@ -196,22 +273,74 @@ func (s *viewService) EditGroupForm(wr http.ResponseWriter, req *http.Request,
 	if data, _ := GetFlash(wr, req, "choreError"); data != nil {
 		choreErr = string(data)
 	}
+	if data, _ := GetFlash(wr, req, "settingsError"); data != nil {
+		settingsErr = string(data)
+	}
 	model := struct {
-		User       *core.User
-		Group      *core.Group
-		NameError  string
-		MemError   string
-		ChoreError string
+		User          *core.User
+		Group         *core.Group
+		NameError     string
+		MemError      string
+		ChoreError    string
+		SettingsError string
+		GenError      string
+		CanAudit      bool
+		Policies      []core.MissedPolicy
 	}{
-		User:       user,
-		Group:      group,
-		NameError:  nameErr,
-		MemError:   memErr,
-		ChoreError: choreErr,
-	}
-	err := executeTemplate(wr, model, "../html/editgroup.html")
+		User:          user,
+		Group:         group,
+		GenError:      genErr,
+		NameError:     nameErr,
+		MemError:      memErr,
+		ChoreError:    choreErr,
+		SettingsError: settingsErr,
+		CanAudit:      canAudit,
+		Policies:      core.MissedPolicies,
+	}
+	err := s.render(wr, req, user, model, "editgroup.html")
 	if err != nil {
-		handleError(internalError(err), wr)
+		handleError(internalError(err), wr, req)
+	}
+}
+
+// GroupPage shows a group to any of its members: its chores with their assignees and due dates,
+// its members and the member's own score. Members who can edit the group get a link to do so.
+func (s *viewService) GroupPage(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	if e := s.groups.GetChores(group); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	mem := group.FindMember(user.ID)
+	if e := s.groups.GetRoles(mem); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	table, e := s.scores.Leaderboard(group, core.PeriodAll)
+	if e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	var stats core.Standing
+	for _, st := range table {
+		if st.User != nil && st.User.ID == user.ID {
+			stats = st
+			break
+		}
+	}
+	model := struct {
+		User    *core.User
+		Group   *core.Group
+		Stats   core.Standing
+		CanEdit bool
+	}{
+		User:    user,
+		Group:   group,
+		Stats:   stats,
+		CanEdit: mem.SuperRole.CanEdit(),
+	}
+	if e := s.render(wr, req, user, model, "group.html"); e != nil {
+		handleError(internalError(e), wr, req)
 	}
 }
 
@@ -219,11 +348,11 @@ func (s *viewService) NewRoleForm(wr http.ResponseWriter, req *http.Request,
 	ps httprouter.Params, user *core.User, group *core.Group) {
 	mem := group.FindMember(user.ID)
 	if e := s.groups.GetRoles(mem); e != nil {
-		http.Error(wr, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		handleError(internalError(e), wr, req)
 		return
 	}
 	if !mem.SuperRole.Can(core.EditRoles) {
-		http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		handleError(&core.ForbiddenError{Msg: "You do not have permission to add roles"}, wr, req)
 		return
 	}
 	var genErr string
@@ -239,7 +368,7 @@ func (s *viewService) NewRoleForm(wr http.ResponseWriter, req *http.Request,
 		Group: group,
 		Error: genErr,
 	}
-	executeTemplate(wr, model, "../html/addrole.html")
+	s.render(wr, req, user, model, "addrole.html")
 }
 
 func (s *viewService) UpdateRoleForm(wr http.ResponseWriter, req *http.Request,
@@ -249,7 +378,7 @@ func (s *viewService) UpdateRoleForm(wr http.ResponseWriter, req *http.Request,
 	if data, e := GetFlash(wr, req, "genError"); data != nil {
 		msg = string(data)
 	} else if e != nil {
-		log.Printf("UpdateRoleForm: Failed to get flash message: %s", e.Error())
+		logFor(req).Warn("UpdateRoleForm: Failed to get flash message", "err", e)
 	}
 	model := struct {
 		User  *core.User
@@ -262,7 +391,7 @@ func (s *viewService) UpdateRoleForm(wr http.ResponseWriter, req *http.Request,
 		Role:  role,
 		Error: msg,
 	}
-	executeTemplate(wr, model, "../html/editrole.html")
+	s.render(wr, req, user, model, "editrole.html")
 }
 
 func (s *viewService) NewChoreForm(wr http.ResponseWriter, req *http.Request,
@@ -273,17 +402,19 @@ func (s *viewService) NewChoreForm(wr http.ResponseWriter, req *http.Request,
 	}
 	d := getDurations()
 	model := struct {
-		Durations []int
-		Group     *core.Group
-		User      *core.User
-		Error     string
+		Durations   []int
+		Recurrences []core.Recurrence
+		Group       *core.Group
+		User        *core.User
+		Error       string
 	}{
-		Durations: d,
-		Group:     group,
-		User:      user,
-		Error:     msg,
+		Durations:   d,
+		Recurrences: core.Recurrences,
+		Group:       group,
+		User:        user,
+		Error:       msg,
 	}
-	executeTemplate(wr, model, "../html/newchore.html")
+	s.render(wr, req, user, model, "newchore.html")
 }
 
 func (s *viewService) UpdateChoreForm(wr http.ResponseWriter, req *http.Request,
@@ -292,31 +423,232 @@ func (s *viewService) UpdateChoreForm(wr http.ResponseWriter, req *http.Request,
 	if data, _ := GetFlash(wr, req, "genError"); data != nil {
 		msg = string(data)
 	}
+	if e := s.groups.GetRoles(chore.Group); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
 	d := getDurations()
 	model := struct {
-		Durations []int
-		Chore     *core.Chore
+		Durations   []int
+		Recurrences []core.Recurrence
+		Chore       *core.Chore
+		User        *core.User
+		Error       string
+	}{
+		Durations:   d,
+		Recurrences: core.Recurrences,
+		Chore:       chore,
+		User:        user,
+		Error:       msg,
+	}
+	s.render(wr, req, user, model, "updatechore.html")
+}
+
+func (s *viewService) AvailabilityForm(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	mem := group.FindMember(user.ID)
+	if e := s.avail.GetAbsences(mem); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	model := struct {
+		User   *core.User
+		Group  *core.Group
+		Member *core.Membership
+		Error  string
+	}{
+		User:   user,
+		Group:  group,
+		Member: mem,
+		Error:  msg,
+	}
+	s.render(wr, req, user, model, "away.html")
+}
+
+func (s *viewService) SwapForm(wr http.ResponseWriter, req *http.Request,
+	user *core.User, chore *core.Chore) {
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	model := struct {
+		User  *core.User
+		Chore *core.Chore
+		Group *core.Group
+		Mine  bool
+		Error string
+	}{
+		User:  user,
+		Chore: chore,
+		Group: chore.Group,
+		Mine:  chore.Assignment != nil && chore.Assignment.User.ID == user.ID,
+		Error: msg,
+	}
+	s.render(wr, req, user, model, "swap.html")
+}
+
+func (s *viewService) Leaderboard(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	period := core.Period(req.FormValue("period"))
+	if period == "" {
+		period = core.PeriodWeek
+	}
+	table, e := s.scores.Leaderboard(group, period)
+	if e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	model := struct {
 		User      *core.User
-		Error     string
+		Group     *core.Group
+		Period    core.Period
+		Periods   []core.Period
+		Standings []core.Standing
 	}{
-		Durations: d,
-		Chore:     chore,
 		User:      user,
-		Error:     msg,
+		Group:     group,
+		Period:    period,
+		Periods:   core.Periods,
+		Standings: table,
 	}
-	executeTemplate(wr, model, "../html/updatechore.html")
+	s.render(wr, req, user, model, "leaderboard.html")
 }
 
-func executeTemplate(wr http.ResponseWriter, model interface{}, files ...string) error {
-	common := []string{"../html/layout.html", "../html/navbar.html"}
-	files = append(files, common...)
-	var t *template.Template
-	t, err := template.ParseFiles(files...)
-	if err == nil {
-		err = t.ExecuteTemplate(wr, "layout", model)
+func (s *viewService) Inbox(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	if e := s.users.GetUserByID(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if e := s.notes.GetInbox(&user, inboxSize); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	model := struct {
+		User  *core.User
+		Error string
+	}{
+		User:  &user,
+		Error: msg,
+	}
+	if e := s.render(wr, req, &user, model, "inbox.html"); e != nil {
+		handleError(internalError(e), wr, req)
+	}
+}
+
+func (s *viewService) WebhooksForm(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	mem := group.FindMember(user.ID)
+	if e := s.groups.GetRoles(mem); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if !mem.SuperRole.Can(core.EditGroup) {
+		http.Error(wr, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
 	}
+	if e := s.hooks.GetWebhooks(group); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if e := s.hooks.GetDeliveries(group, deliveryLogSize); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	model := struct {
+		User   *core.User
+		Group  *core.Group
+		Events []core.EventType
+		Error  string
+	}{
+		User:   user,
+		Group:  group,
+		Events: core.WebhookEvents,
+		Error:  msg,
+	}
+	if e := s.render(wr, req, user, model, "webhooks.html"); e != nil {
+		handleError(internalError(e), wr, req)
+	}
+}
+
+func (s *viewService) ImportForm(wr http.ResponseWriter, req *http.Request,
+	ps httprouter.Params, user *core.User, group *core.Group) {
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	s.renderImport(wr, req, user, group, nil, core.ImportCSV, "", msg)
+}
+
+func (s *viewService) ImportPreview(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group,
+	plan *core.ImportPlan, format core.ImportFormat, data string) {
+	s.renderImport(wr, req, user, group, plan, format, data, "")
+}
+
+func (s *viewService) renderImport(wr http.ResponseWriter, req *http.Request, user *core.User, group *core.Group,
+	plan *core.ImportPlan, format core.ImportFormat, data string, msg string) {
+	model := struct {
+		User    *core.User
+		Group   *core.Group
+		Plan    *core.ImportPlan
+		Formats []core.ImportFormat
+		Format  core.ImportFormat
+		Data    string
+		Error   string
+	}{
+		User:    user,
+		Group:   group,
+		Plan:    plan,
+		Formats: []core.ImportFormat{core.ImportCSV, core.ImportJSON},
+		Format:  format,
+		Data:    data,
+		Error:   msg,
+	}
+	if e := s.render(wr, req, user, model, "importgroup.html"); e != nil {
+		handleError(internalError(e), wr, req)
+	}
+}
+
+// page is the model every template is executed with. The navbar is rendered from Nav and the page
+// itself from Body.
+type page struct {
+	Nav  navModel
+	Body interface{}
+}
+
+type navModel struct {
+	User   *core.User
+	Unread int
+}
+
+// render executes a page template for the user, who is nil when nobody is logged in
+func (s *viewService) render(wr http.ResponseWriter, req *http.Request, user *core.User, model interface{}, file string) error {
+	nav := navModel{User: user}
+	if user != nil {
+		if e := s.notes.CountUnread(user); e != nil {
+			logFor(req).Warn("Failed to count unread notifications", "err", errorDetail(e))
+		}
+		nav.Unread = user.Unread
+	}
+	l := localeFor(req)
+	if user != nil && user.Locale != "" {
+		l = i18n.Get(user.Locale)
+	}
+	err := s.assets.render(wr, page{Nav: nav, Body: model}, file, l, zoneFor(req, user))
 	if err != nil {
-		log.Print(err)
+		logFor(req).Error("Failed to render page", "page", file, "err", err)
 	}
 	return err
 }
@@ -346,3 +678,82 @@ func getDurations() []int {
 	}
 	return d
 }
+
+func (s *viewService) NotificationsForm(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	if e := s.users.GetUserByID(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if e := s.notes.GetChannelPrefs(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if e := s.digests.GetDigestPrefs(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	hours := make([]int, 24)
+	for i := range hours {
+		hours[i] = i
+	}
+	model := struct {
+		User        *core.User
+		Frequencies []core.DigestFrequency
+		Hours       []int
+		Weekdays    []time.Weekday
+		Locales     []*i18n.Locale
+		Error       string
+	}{
+		User:        &user,
+		Frequencies: core.DigestFrequencies,
+		Hours:       hours,
+		Weekdays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+			time.Friday, time.Saturday},
+		Locales: i18n.Locales(),
+		Error:   msg,
+	}
+	if e := s.render(wr, req, &user, model, "notifications.html"); e != nil {
+		handleError(internalError(e), wr, req)
+	}
+}
+
+func (s *viewService) CalendarForm(wr http.ResponseWriter, req *http.Request, uid uint64) {
+	user := core.User{ID: uid}
+	if e := s.users.GetUserByID(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if e := s.users.GetMemberships(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	if e := s.feeds.GetFeedTokens(&user); e != nil {
+		handleError(internalError(e), wr, req)
+		return
+	}
+	var msg string
+	if data, _ := GetFlash(wr, req, "genError"); data != nil {
+		msg = string(data)
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	model := struct {
+		User    *core.User
+		FeedURL string
+		Error   string
+	}{
+		User:    &user,
+		FeedURL: scheme + "://" + req.Host + "/feeds/",
+		Error:   msg,
+	}
+	if e := s.render(wr, req, &user, model, "calendar.html"); e != nil {
+		handleError(internalError(e), wr, req)
+	}
+}